@@ -56,7 +56,12 @@ func runBootchart(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	flight, err := kola.NewFlight(kolaPlatform)
+	journal, err := kola.OpenResourceJournal(outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	flight, err := kola.NewFlight(kolaPlatform, journal)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Flight failed: %v\n", err)
 		os.Exit(1)
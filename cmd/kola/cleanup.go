@@ -0,0 +1,54 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/kola"
+)
+
+var cmdCleanup = &cobra.Command{
+	Run:    runCleanup,
+	PreRun: preRun,
+	Use:    "cleanup <output-dir>",
+	Short:  "Delete resources leaked by an interrupted kola run",
+	Long: `Delete the platform resources recorded in the resource journal of
+a kola output directory.
+
+Flights record each instance, key and resource group they create in
+the journal, and remove the entry once it has been deleted.  If kola
+dies before destroying its flight, the journal lists exactly what
+leaked.  Platform credentials are taken from the usual kola flags.
+`}
+
+func init() {
+	root.AddCommand(cmdCleanup)
+}
+
+func runCleanup(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: kola cleanup <output-dir>\n")
+		os.Exit(2)
+	}
+
+	if err := kola.CleanupResources(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Cleanup failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/harness/reporters"
+)
+
+var (
+	cmdCompareMetrics = &cobra.Command{
+		Use:   "compare-metrics old.json new.json",
+		Short: "Compare the metrics of two kola runs",
+		Long: `Compare the metrics reported by tests in two report.json files from
+kola run, and flag those which regressed by more than the threshold.
+
+Metrics in rates, with a unit such as MB/s, are better when higher;
+all others, such as durations, are better when lower.  A metric
+missing from new.json, or reported in a different unit, also counts
+as a regression.  Exits with status 1 if any metric regressed.
+`,
+		Run: runCompareMetrics,
+	}
+
+	compareThreshold float64
+)
+
+func init() {
+	root.AddCommand(cmdCompareMetrics)
+
+	cmdCompareMetrics.Flags().Float64Var(&compareThreshold, "threshold", 10, "percentage change beyond which a metric regressed")
+}
+
+type metricsReport struct {
+	Tests []struct {
+		Name    string             `json:"name"`
+		Metrics []reporters.Metric `json:"metrics"`
+	} `json:"tests"`
+}
+
+// readMetrics returns the metrics in a report.json keyed by test and
+// metric name.
+func readMetrics(path string) (map[string]reporters.Metric, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var report metricsReport
+	if err := json.NewDecoder(f).Decode(&report); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	metrics := make(map[string]reporters.Metric)
+	for _, t := range report.Tests {
+		for _, m := range t.Metrics {
+			metrics[t.Name+"\t"+m.Name] = m
+		}
+	}
+	return metrics, nil
+}
+
+func higherIsBetter(unit string) bool {
+	return strings.Contains(unit, "/")
+}
+
+// metricComparison is a metric of a test in the old and new runs.
+type metricComparison struct {
+	Key       string
+	Old, New  *reporters.Metric
+	Change    float64 // percentage change from Old to New
+	Status    string
+	Regressed bool
+}
+
+// compareMetrics compares the metrics of two runs, keyed by test and
+// metric name, and flags those which got worse by more than threshold
+// percent.  A metric missing from the new run, or whose unit changed,
+// counts as a regression.
+func compareMetrics(oldMetrics, newMetrics map[string]reporters.Metric, threshold float64) []metricComparison {
+	keys := make(map[string]bool)
+	for key := range oldMetrics {
+		keys[key] = true
+	}
+	for key := range newMetrics {
+		keys[key] = true
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var comparisons []metricComparison
+	for _, key := range sorted {
+		c := metricComparison{Key: key}
+		if old, ok := oldMetrics[key]; ok {
+			c.Old = &old
+		}
+		if cur, ok := newMetrics[key]; ok {
+			c.New = &cur
+		}
+		switch {
+		case c.New == nil:
+			c.Status = "missing"
+			c.Regressed = true
+		case c.Old == nil:
+			c.Status = "new"
+		case c.Old.Unit != c.New.Unit:
+			c.Status = fmt.Sprintf("unit changed from %s to %s", c.Old.Unit, c.New.Unit)
+			c.Regressed = true
+		default:
+			switch {
+			case c.Old.Value == c.New.Value:
+				c.Change = 0
+			case c.Old.Value == 0:
+				// any change from nothing is infinitely large
+				c.Change = math.Inf(1)
+				if c.New.Value < 0 {
+					c.Change = math.Inf(-1)
+				}
+			default:
+				c.Change = (c.New.Value - c.Old.Value) / math.Abs(c.Old.Value) * 100
+			}
+			worse := c.Change
+			if higherIsBetter(c.New.Unit) {
+				worse = -worse
+			}
+			c.Status = "ok"
+			if worse > threshold {
+				c.Status = "REGRESSED"
+				c.Regressed = true
+			} else if -worse > threshold {
+				c.Status = "improved"
+			}
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
+
+func formatMetric(m *reporters.Metric) string {
+	if m == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.3g %s", m.Value, m.Unit)
+}
+
+func runCompareMetrics(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: kola compare-metrics old.json new.json\n")
+		os.Exit(2)
+	}
+	oldMetrics, err := readMetrics(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	newMetrics, err := readMetrics(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	regressed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "Test Name\tMetric\tOld\tNew\tChange\tStatus")
+	for _, c := range compareMetrics(oldMetrics, newMetrics, compareThreshold) {
+		change := "-"
+		if c.Old != nil && c.New != nil && c.Old.Unit == c.New.Unit {
+			change = fmt.Sprintf("%+.1f%%", c.Change)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Key, formatMetric(c.Old), formatMetric(c.New), change, c.Status)
+		if c.Regressed {
+			regressed = true
+		}
+	}
+	w.Flush()
+
+	if regressed {
+		os.Exit(1)
+	}
+}
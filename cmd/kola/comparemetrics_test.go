@@ -0,0 +1,90 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/coreos/mantle/harness/reporters"
+)
+
+func TestCompareMetrics(t *testing.T) {
+	metric := func(value float64, unit string) reporters.Metric {
+		return reporters.Metric{Value: value, Unit: unit}
+	}
+	oldMetrics := map[string]reporters.Metric{
+		"boot\tslower":      metric(10, "s"),
+		"boot\tfaster":      metric(10, "s"),
+		"boot\tsame":        metric(10, "s"),
+		"boot\tnoise":       metric(10, "s"),
+		"boot\tfrom zero":   metric(0, "s"),
+		"boot\tzero":        metric(0, "s"),
+		"disk\tslower":      metric(100, "MB/s"),
+		"disk\tfaster":      metric(100, "MB/s"),
+		"disk\tfrom zero":   metric(0, "MB/s"),
+		"disk\tunit":        metric(100, "MB/s"),
+		"disk\tdropped":     metric(100, "MB/s"),
+		"temp\tnegative":    metric(-10, "C"),
+		"temp\tless signed": metric(-10, "C"),
+	}
+	newMetrics := map[string]reporters.Metric{
+		"boot\tslower":      metric(12, "s"),
+		"boot\tfaster":      metric(8, "s"),
+		"boot\tsame":        metric(10, "s"),
+		"boot\tnoise":       metric(10.5, "s"),
+		"boot\tfrom zero":   metric(1, "s"),
+		"boot\tzero":        metric(0, "s"),
+		"disk\tslower":      metric(80, "MB/s"),
+		"disk\tfaster":      metric(120, "MB/s"),
+		"disk\tfrom zero":   metric(50, "MB/s"),
+		"disk\tunit":        metric(100, "GB/s"),
+		"disk\tadded":       metric(100, "MB/s"),
+		"temp\tnegative":    metric(-5, "C"),
+		"temp\tless signed": metric(-15, "C"),
+	}
+	expected := map[string]string{
+		"boot\tslower":      "REGRESSED",
+		"boot\tfaster":      "improved",
+		"boot\tsame":        "ok",
+		"boot\tnoise":       "ok",
+		"boot\tfrom zero":   "REGRESSED",
+		"boot\tzero":        "ok",
+		"disk\tslower":      "REGRESSED",
+		"disk\tfaster":      "improved",
+		"disk\tfrom zero":   "improved",
+		"disk\tunit":        "unit changed from MB/s to GB/s",
+		"disk\tdropped":     "missing",
+		"disk\tadded":       "new",
+		"temp\tnegative":    "REGRESSED",
+		"temp\tless signed": "improved",
+	}
+
+	comparisons := compareMetrics(oldMetrics, newMetrics, 10)
+	if len(comparisons) != len(expected) {
+		t.Errorf("got %d comparisons, expected %d", len(comparisons), len(expected))
+	}
+	for i, c := range comparisons {
+		if i > 0 && comparisons[i-1].Key >= c.Key {
+			t.Errorf("%q: not sorted", c.Key)
+		}
+		if c.Status != expected[c.Key] {
+			t.Errorf("%q: got status %q, expected %q", c.Key, c.Status, expected[c.Key])
+		}
+		regressed := c.Status != "ok" && c.Status != "improved" && c.Status != "new"
+		if c.Regressed != regressed {
+			t.Errorf("%q: got regressed %v", c.Key, c.Regressed)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/kola"
+)
+
+var (
+	cmdExplain = &cobra.Command{
+		Use:   "explain [glob pattern...]",
+		Short: "Explain which kola tests would run and why",
+		Long: `Show whether each test matching the glob patterns would run on the
+selected platform, distro and board, and which rule excludes it if not.
+
+Tests outside their supported version range are only excluded when
+--os-version is given, since kola run checks the version of a booted
+machine.
+`,
+		Run:    runExplain,
+		PreRun: preRun,
+	}
+
+	explainJSON    bool
+	explainVersion string
+)
+
+func init() {
+	root.AddCommand(cmdExplain)
+
+	cmdExplain.Flags().BoolVar(&explainJSON, "json", false, "format output in JSON")
+	cmdExplain.Flags().StringVar(&explainVersion, "os-version", "", "OS version to check the tests' version ranges against")
+}
+
+func runExplain(cmd *cobra.Command, args []string) {
+	patterns := args
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+
+	var version semver.Version
+	if explainVersion != "" {
+		v, err := semver.NewVersion(explainVersion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid OS version: %v\n", err)
+			os.Exit(2)
+		}
+		version = *v
+	}
+
+	decisions, err := kola.ExplainTests(patterns, kolaPlatform, version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if explainJSON {
+		out, err := json.MarshalIndent(decisions, "", "\t")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshalling decisions: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "Test Name\tDecision\tReason")
+	for _, d := range decisions {
+		decision := "run"
+		if !d.Included {
+			decision = "skip"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Name, decision, d.Reason)
+	}
+	w.Flush()
+}
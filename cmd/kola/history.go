@@ -0,0 +1,201 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/kola/history"
+)
+
+var (
+	cmdHistory = &cobra.Command{
+		Use:   "history",
+		Short: "Query the results of past kola runs",
+		Long: `Record the results of kola runs in a local database, and query them
+across runs.
+
+Runs are recorded by kola history add, or by kola run --record.  Each
+is identified by its output directory and start time, so adding a run
+again replaces its earlier record, while later runs reusing the same
+output directory are recorded separately.
+`,
+	}
+
+	cmdHistoryAdd = &cobra.Command{
+		Use:   "add output-dir...",
+		Short: "Record the results of kola runs",
+		Run:   runHistoryAdd,
+	}
+
+	cmdHistoryPassRate = &cobra.Command{
+		Use:   "pass-rate",
+		Short: "Show the pass rate of each test by platform and version",
+		Run:   runHistoryPassRate,
+	}
+
+	cmdHistoryFlakes = &cobra.Command{
+		Use:   "flakes",
+		Short: "Show tests which recently both passed and failed",
+		Long: `Show the tests which both passed and failed on the same platform and
+version within the --since window.
+`,
+		Run: runHistoryFlakes,
+	}
+
+	cmdHistoryFirstFailure = &cobra.Command{
+		Use:   "first-failure",
+		Short: "Show the version where each failing test started failing",
+		Long: `Show the tests whose latest result on a platform is a failure, with
+the first version of their current run of failures and the last
+version where they passed.  Runs which didn't record an OS version
+are ignored.
+`,
+		Run: runHistoryFirstFailure,
+	}
+
+	cmdHistorySlowest = &cobra.Command{
+		Use:   "slowest",
+		Short: "Show the tests with the longest mean passing duration",
+		Run:   runHistorySlowest,
+	}
+
+	historyJSON   bool
+	historySince  time.Duration
+	historyLimit  int
+	historyRecord bool
+)
+
+func init() {
+	root.AddCommand(cmdHistory)
+	cmdHistory.AddCommand(cmdHistoryAdd)
+	cmdHistory.AddCommand(cmdHistoryPassRate)
+	cmdHistory.AddCommand(cmdHistoryFlakes)
+	cmdHistory.AddCommand(cmdHistoryFirstFailure)
+	cmdHistory.AddCommand(cmdHistorySlowest)
+
+	cmdHistory.PersistentFlags().BoolVar(&historyJSON, "json", false, "format output in JSON")
+	cmdHistoryFlakes.Flags().DurationVar(&historySince, "since", 7*24*time.Hour, "how far back to look for flakes")
+	cmdHistorySlowest.Flags().IntVar(&historyLimit, "limit", 20, "number of tests to show, or 0 for all")
+
+	cmdRun.Flags().BoolVar(&historyRecord, "record", false, "record the results in the history database")
+}
+
+// recordRun adds the results of the run in outputDir to the history
+// database.
+func recordRun(outputDir string) error {
+	run, err := history.ReadRun(outputDir)
+	if err != nil {
+		return err
+	}
+	db, err := history.Open(historyDB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Add(run)
+}
+
+func runHistoryAdd(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	for _, dir := range args {
+		if err := recordRun(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Recording %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func historyRuns() []history.Run {
+	db, err := history.Open(historyDB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	runs, err := db.Runs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	return runs
+}
+
+// printHistory prints v as JSON if --json was given, and otherwise
+// calls table to print it as a table.
+func printHistory(v interface{}, table func(w *tabwriter.Writer)) {
+	if historyJSON {
+		out, err := json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshalling results: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	table(w)
+	w.Flush()
+}
+
+func runHistoryPassRate(cmd *cobra.Command, args []string) {
+	rates := history.PassRates(historyRuns())
+	printHistory(rates, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "Test Name\tPlatform\tVersion\tPassed\tFailed\tSkipped\tPass Rate")
+		for _, r := range rates {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%.0f%%\n", r.Test, r.Platform, r.Version, r.Passed, r.Failed, r.Skipped, r.Rate*100)
+		}
+	})
+}
+
+func runHistoryFlakes(cmd *cobra.Command, args []string) {
+	flakes := history.Flakes(historyRuns(), time.Now().Add(-historySince))
+	printHistory(flakes, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "Test Name\tPlatform\tVersion\tPassed\tFailed\tLast Failure")
+		for _, f := range flakes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", f.Test, f.Platform, f.Version, f.Passed, f.Failed, f.LastFailure.Format(time.RFC3339))
+		}
+	})
+}
+
+func runHistoryFirstFailure(cmd *cobra.Command, args []string) {
+	failures := history.FirstFailures(historyRuns())
+	printHistory(failures, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "Test Name\tPlatform\tFirst Failing\tLast Passing")
+		for _, f := range failures {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Test, f.Platform, f.FirstFailingVersion, f.LastPassingVersion)
+		}
+	})
+}
+
+func runHistorySlowest(cmd *cobra.Command, args []string) {
+	slowest := history.Slowest(historyRuns(), historyLimit)
+	printHistory(slowest, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "Test Name\tPlatform\tRuns\tMean\tMax")
+		for _, d := range slowest {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%v\n", d.Test, d.Platform, d.Runs, d.Mean.Round(time.Second), d.Max.Round(time.Second))
+		}
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"sort"
 	"text/tabwriter"
+	"time"
 
 	"github.com/coreos/pkg/capnslog"
 	"github.com/spf13/cobra"
@@ -43,11 +44,14 @@ var (
 	}
 
 	cmdRun = &cobra.Command{
-		Use:   "run [glob pattern]",
+		Use:   "run [glob pattern...]",
 		Short: "Run kola tests by category",
 		Long: `Run all kola tests (default) or related groups.
 
-If the glob pattern is exactly equal to the name of a single test, any
+Tests matching any of the glob patterns are run, and can be narrowed
+further by their tags with --tags, e.g. --tags 'smoke && !slow'.
+
+If a glob pattern is exactly equal to the name of a single test, any
 restrictions on the versions of Container Linux supported by that test
 will be ignored.
 `,
@@ -92,15 +96,9 @@ func preRun(cmd *cobra.Command, args []string) {
 }
 
 func runRun(cmd *cobra.Command, args []string) {
-	if len(args) > 1 {
-		fmt.Fprintf(os.Stderr, "Extra arguments specified. Usage: 'kola run [glob pattern]'\n")
-		os.Exit(2)
-	}
-	var pattern string
-	if len(args) == 1 {
-		pattern = args[0]
-	} else {
-		pattern = "*" // run all tests by default
+	patterns := args
+	if len(patterns) == 0 {
+		patterns = []string{"*"} // run all tests by default
 	}
 
 	var err error
@@ -110,21 +108,31 @@ func runRun(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	runErr := kola.RunTests(pattern, kolaPlatform, outputDir)
+	start := time.Now()
+	runErr := kola.RunTests(patterns, kolaPlatform, outputDir)
 
 	// needs to be after RunTests() because harness empties the directory
-	if err := writeProps(); err != nil {
+	if err := writeProps(start); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
+	if historyRecord {
+		if err := recordRun(outputDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Recording results: %v\n", err)
+			if runErr == nil {
+				os.Exit(1)
+			}
+		}
+	}
+
 	if runErr != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", runErr)
 		os.Exit(1)
 	}
 }
 
-func writeProps() error {
+func writeProps(start time.Time) error {
 	f, err := os.OpenFile(filepath.Join(outputDir, "properties.json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	if err != nil {
 		return err
@@ -178,6 +186,7 @@ func writeProps() error {
 	}
 	return enc.Encode(&struct {
 		Cmdline         []string  `json:"cmdline"`
+		Start           time.Time `json:"start"`
 		Platform        string    `json:"platform"`
 		Distro          string    `json:"distro"`
 		IgnitionVersion string    `json:"ignitionversion"`
@@ -193,6 +202,7 @@ func writeProps() error {
 		QEMU            QEMU      `json:"qemu"`
 	}{
 		Cmdline:         os.Args,
+		Start:           start,
 		Platform:        kolaPlatform,
 		Distro:          kola.Options.Distribution,
 		IgnitionVersion: kola.Options.IgnitionVersion,
@@ -244,15 +254,25 @@ func writeProps() error {
 }
 
 func runList(cmd *cobra.Command, args []string) {
+	tags, err := register.ParseTagExpr(kola.Tags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
 	var testlist []*item
 	for name, test := range register.Tests {
+		if !test.MatchesTags(tags) {
+			continue
+		}
 		item := &item{
 			name,
 			test.Platforms,
 			test.ExcludePlatforms,
 			test.Architectures,
 			test.Distros,
-			test.ExcludeDistros}
+			test.ExcludeDistros,
+			test.Tags}
 		item.updateValues()
 		testlist = append(testlist, item)
 	}
@@ -264,7 +284,7 @@ func runList(cmd *cobra.Command, args []string) {
 	if !listJSON {
 		var w = tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
 
-		fmt.Fprintln(w, "Test Name\tPlatforms\tArchitectures\tDistributions")
+		fmt.Fprintln(w, "Test Name\tPlatforms\tArchitectures\tDistributions\tTags")
 		fmt.Fprintln(w, "\t")
 		for _, item := range testlist {
 			fmt.Fprintf(w, "%v\n", item)
@@ -287,6 +307,7 @@ type item struct {
 	Architectures    []string
 	Distros          []string
 	ExcludeDistros   []string `json:"-"`
+	Tags             []string
 }
 
 func (i *item) updateValues() {
@@ -327,5 +348,5 @@ func (i *item) updateValues() {
 }
 
 func (i item) String() string {
-	return fmt.Sprintf("%v\t%v\t%v\t%v", i.Name, i.Platforms, i.Architectures, i.Distros)
+	return fmt.Sprintf("%v\t%v\t%v\t%v\t%v", i.Name, i.Platforms, i.Architectures, i.Distros, i.Tags)
 }
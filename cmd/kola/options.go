@@ -21,17 +21,22 @@ import (
 
 	"github.com/coreos/mantle/auth"
 	"github.com/coreos/mantle/kola"
+	"github.com/coreos/mantle/kola/history"
 	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/sdk"
 )
 
 var (
 	outputDir          string
+	historyDB          string
 	kolaPlatform       string
 	defaultTargetBoard = sdk.DefaultBoard()
-	kolaArchitectures  = []string{"amd64"}
-	kolaPlatforms      = []string{"aws", "azure", "do", "esx", "gce", "openstack", "packet", "qemu", "qemu-unpriv"}
+	kolaArchitectures  = []string{"amd64", "arm64", "s390x"}
+	kolaPlatforms      = []string{"aws", "azure", "do", "esx", "gce", "libvirt", "openstack", "packet", "qemu", "qemu-unpriv"}
 	kolaDistros        = []string{"cl", "fcos", "rhcos"}
+	kolaQuotaChecks    = []string{kola.QuotaCheckOff, kola.QuotaCheckWarn, kola.QuotaCheckFail}
+	kolaFirmwares      = []string{platform.FirmwareBIOS, platform.FirmwareUEFI, platform.FirmwareUEFISecure}
+	kolaBootModes      = []string{"disk", platform.BootPXE, platform.BootPXEInstall}
 	kolaDefaultImages  = map[string]string{
 		"amd64-usr": sdk.BuildRoot() + "/images/amd64-usr/latest/coreos_production_image.bin",
 		"arm64-usr": sdk.BuildRoot() + "/images/arm64-usr/latest/coreos_production_image.bin",
@@ -60,12 +65,16 @@ func init() {
 	root.PersistentFlags().StringVarP(&kolaPlatform, "platform", "p", "qemu", "VM platform: "+strings.Join(kolaPlatforms, ", "))
 	root.PersistentFlags().StringVarP(&kola.Options.Distribution, "distro", "b", "cl", "Distribution: "+strings.Join(kolaDistros, ", "))
 	root.PersistentFlags().IntVarP(&kola.TestParallelism, "parallel", "j", 1, "number of tests to run in parallel")
+	sv(&kola.QuotaCheck, "quota-check", kola.QuotaCheckWarn, "action when a run may exceed the platform's instance quota: "+strings.Join(kolaQuotaChecks, ", "))
 	sv(&kola.TAPFile, "tapfile", "", "file to write TAP results to")
+	sv(&historyDB, "history-db", history.DefaultPath(), "database of past run results for kola history and kola run --record")
+	sv(&kola.EventStream, "event-stream", "", "file, unix:PATH or tcp:HOST:PORT to stream test events to as JSON lines")
 	sv(&kola.Options.BaseName, "basename", "kola", "Cluster name prefix")
 	ss("debug-systemd-unit", []string{}, "full-unit-name.service to enable SYSTEMD_LOG_LEVEL=debug on. Specify multiple times for multiple units.")
 	sv(&kola.UpdatePayloadFile, "update-payload", "", "Path to an update payload that should be made available to tests")
 	sv(&kola.Options.IgnitionVersion, "ignition-version", "", "Ignition version override: v2, v3")
 	ssv(&kola.BlacklistedTests, "blacklist-test", []string{}, "List of tests to blacklist")
+	sv(&kola.Tags, "tags", "", "run only tests whose tags match an expression, e.g. 'smoke && !slow'; benchmarks only run if it names benchmark")
 	// rhcos-specific options
 	sv(&kola.Options.OSContainer, "oscontainer", "", "oscontainer image pullspec for pivot (RHCOS only)")
 
@@ -117,6 +126,12 @@ func init() {
 	bv(&kola.GCEOptions.ServiceAuth, "gce-service-auth", false, "for non-interactive auth when running within GCE")
 	sv(&kola.GCEOptions.JSONKeyFile, "gce-json-key", "", "use a service account's JSON key for authentication")
 
+	// libvirt-specific options
+	sv(&kola.LibvirtOptions.URI, "libvirt-uri", "qemu:///session", "libvirt connection URI")
+	sv(&kola.LibvirtOptions.DiskImage, "libvirt-image", "", "path to CoreOS disk image (default the QEMU image)")
+	root.PersistentFlags().IntVar(&kola.LibvirtOptions.Memory, "libvirt-memory", 1024, "libvirt machine memory in MiB")
+	sv(&kola.LibvirtOptions.Network, "libvirt-network", "auto", "libvirt networking: auto, nat (per-flight network, needs qemu:///system), user (per-flight multicast segment for Ignition machines)")
+
 	// openstack-specific options
 	sv(&kola.OpenStackOptions.ConfigPath, "openstack-config-file", "", "OpenStack config file (default \"~/"+auth.OpenStackConfigPath+"\")")
 	sv(&kola.OpenStackOptions.Profile, "openstack-profile", "", "OpenStack profile (default \"default\")")
@@ -143,6 +158,17 @@ func init() {
 	sv(&kola.QEMUOptions.DiskImage, "qemu-image", "", "path to CoreOS disk image")
 	sv(&kola.QEMUOptions.BIOSImage, "qemu-bios", "", "BIOS to use for QEMU vm")
 	bv(&kola.QEMUOptions.UseVanillaImage, "qemu-skip-mangle", false, "don't modify CL disk image to capture console log")
+	sv(&kola.QEMUOptions.Firmware, "qemu-firmware", platform.FirmwareBIOS, "QEMU firmware: "+strings.Join(kolaFirmwares, ", "))
+	sv(&kola.QEMUOptions.OVMFCode, "qemu-ovmf-code", "", "OVMF firmware image for UEFI (default: search)")
+	sv(&kola.QEMUOptions.OVMFVars, "qemu-ovmf-vars", "", "OVMF variable store template for UEFI (default: search)")
+	bv(&kola.QEMUOptions.SWTPM, "qemu-swtpm", false, "give QEMU machines a TPM 2.0 emulated by swtpm")
+	sv(&kola.QEMUOptions.WarmBootCache, "qemu-warm-cache", "", "directory of warm-boot images to boot QEMU machines from (default: cold boot)")
+	bv(&kola.QEMUOptions.Emulate, "qemu-emulate", false, "emulate QEMU machines with TCG even if KVM is available")
+	sv(&kola.QEMUOptions.Boot, "qemu-boot", "disk", "QEMU boot mode: "+strings.Join(kolaBootModes, ", "))
+	sv(&kola.QEMUOptions.PXEKernel, "qemu-pxe-kernel", "", "kernel for QEMU network boot")
+	sv(&kola.QEMUOptions.PXEInitrd, "qemu-pxe-initrd", "", "initramfs for QEMU network boot")
+	sv(&kola.QEMUOptions.PXERootfs, "qemu-pxe-rootfs", "", "rootfs image for QEMU network boot (optional)")
+	sv(&kola.QEMUOptions.PXEAppend, "qemu-pxe-append", "", "extra kernel arguments for QEMU network boot")
 }
 
 // Sync up the command line options if there is dependency
@@ -167,6 +193,21 @@ func syncOptions() error {
 		return err
 	}
 
+	if err := validateOption("quota check", kola.QuotaCheck, kolaQuotaChecks); err != nil {
+		return err
+	}
+
+	if err := validateOption("QEMU firmware", kola.QEMUOptions.Firmware, kolaFirmwares); err != nil {
+		return err
+	}
+
+	if err := validateOption("QEMU boot mode", kola.QEMUOptions.Boot, kolaBootModes); err != nil {
+		return err
+	}
+	if kola.QEMUOptions.Boot == "disk" {
+		kola.QEMUOptions.Boot = platform.BootDisk
+	}
+
 	image, ok := kolaDefaultImages[kola.QEMUOptions.Board]
 	if kola.QEMUOptions.Distribution == "cl" && !ok {
 		return fmt.Errorf("unsupport board %q", kola.QEMUOptions.Board)
@@ -176,6 +217,11 @@ func syncOptions() error {
 		kola.QEMUOptions.DiskImage = image
 	}
 
+	kola.LibvirtOptions.Board = kola.QEMUOptions.Board
+	if kola.LibvirtOptions.DiskImage == "" {
+		kola.LibvirtOptions.DiskImage = kola.QEMUOptions.DiskImage
+	}
+
 	if kola.QEMUOptions.BIOSImage == "" {
 		kola.QEMUOptions.BIOSImage = kolaDefaultBIOS[kola.QEMUOptions.Board]
 	}
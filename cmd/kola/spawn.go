@@ -15,9 +15,11 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
@@ -28,11 +30,13 @@ import (
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/coreos/mantle/kola"
 	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 	"github.com/coreos/mantle/platform/machine/qemu"
+	"github.com/coreos/mantle/platform/machine/unprivqemu"
 	"github.com/coreos/mantle/sdk"
 	"github.com/coreos/mantle/sdk/omaha"
 )
@@ -50,11 +54,13 @@ var (
 	spawnDetach         bool
 	spawnOmahaPackage   string
 	spawnShell          bool
+	spawnConsole        bool
 	spawnRemove         bool
 	spawnVerbose        bool
 	spawnMachineOptions string
 	spawnSetSSHKeys     bool
 	spawnSSHKeys        []string
+	spawnShares         []string
 )
 
 func init() {
@@ -63,10 +69,12 @@ func init() {
 	cmdSpawn.Flags().BoolVarP(&spawnDetach, "detach", "t", false, "-kv --shell=false --remove=false")
 	cmdSpawn.Flags().StringVar(&spawnOmahaPackage, "omaha-package", "", "add an update payload to the Omaha server, referenced by image version (e.g. 'latest')")
 	cmdSpawn.Flags().BoolVarP(&spawnShell, "shell", "s", true, "spawn a shell in an instance before exiting")
+	cmdSpawn.Flags().BoolVar(&spawnConsole, "console", false, "attach to the serial console of an instance instead of spawning a shell")
 	cmdSpawn.Flags().BoolVarP(&spawnRemove, "remove", "r", true, "remove instances after shell exits")
 	cmdSpawn.Flags().BoolVarP(&spawnVerbose, "verbose", "v", false, "output information about spawned instances")
 	cmdSpawn.Flags().StringVar(&spawnMachineOptions, "qemu-options", "", "experimental: path to QEMU machine options json")
 	cmdSpawn.Flags().BoolVarP(&spawnSetSSHKeys, "keys", "k", false, "add SSH keys from --key options")
+	cmdSpawn.Flags().StringSliceVar(&spawnShares, "share", nil, "share a host directory with QEMU instances, as host:guest[:ro|:virtiofs]")
 	cmdSpawn.Flags().StringSliceVar(&spawnSSHKeys, "key", nil, "path to SSH public key (default: SSH agent + ~/.ssh/id_{rsa,dsa,ecdsa,ed25519}.pub)")
 	root.AddCommand(cmdSpawn)
 }
@@ -118,7 +126,11 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Setup failed: %v", err)
 	}
 
-	flight, err := kola.NewFlight(kolaPlatform)
+	journal, err := kola.OpenResourceJournal(outputDir)
+	if err != nil {
+		return err
+	}
+	flight, err := kola.NewFlight(kolaPlatform, journal)
 	if err != nil {
 		return fmt.Errorf("Flight failed: %v", err)
 	}
@@ -160,6 +172,29 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 		updateConf = strings.NewReader(fmt.Sprintf("GROUP=developer\nSERVER=http://%s/v1/update/\n", hostport))
 	}
 
+	var machineOpts platform.MachineOptions
+	useMachineOpts := false
+	if kolaPlatform == "qemu" && spawnMachineOptions != "" {
+		b, err := ioutil.ReadFile(spawnMachineOptions)
+		if err != nil {
+			return fmt.Errorf("Could not read machine options: %v", err)
+		}
+
+		err = json.Unmarshal(b, &machineOpts)
+		if err != nil {
+			return fmt.Errorf("Could not unmarshal machine options: %v", err)
+		}
+		useMachineOpts = true
+	}
+	for _, spec := range spawnShares {
+		share, err := platform.ParseSharedDir(spec)
+		if err != nil {
+			return err
+		}
+		machineOpts.SharedDirs = append(machineOpts.SharedDirs, share)
+		useMachineOpts = true
+	}
+
 	var someMach platform.Machine
 	for i := 0; i < spawnNodeCount; i++ {
 		var mach platform.Machine
@@ -167,20 +202,15 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 		if spawnVerbose {
 			fmt.Println("Spawning machine...")
 		}
-		if kolaPlatform == "qemu" && spawnMachineOptions != "" {
-			var b []byte
-			b, err = ioutil.ReadFile(spawnMachineOptions)
-			if err != nil {
-				return fmt.Errorf("Could not read machine options: %v", err)
+		if useMachineOpts {
+			switch qc := cluster.(type) {
+			case *qemu.Cluster:
+				mach, err = qc.NewMachineWithOptions(userdata, machineOpts)
+			case *unprivqemu.Cluster:
+				mach, err = qc.NewMachineWithOptions(userdata, machineOpts)
+			default:
+				return errors.New("--share is only supported on qemu")
 			}
-
-			var machineOpts platform.MachineOptions
-			err = json.Unmarshal(b, &machineOpts)
-			if err != nil {
-				return fmt.Errorf("Could not unmarshal machine options: %v", err)
-			}
-
-			mach, err = cluster.(*qemu.Cluster).NewMachineWithOptions(userdata, machineOpts)
 		} else {
 			mach, err = cluster.NewMachine(userdata)
 		}
@@ -200,7 +230,11 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 		someMach = mach
 	}
 
-	if spawnShell {
+	if spawnConsole {
+		if err := attachConsole(someMach); err != nil {
+			return fmt.Errorf("Console failed: %v", err)
+		}
+	} else if spawnShell {
 		if spawnRemove {
 			reader := strings.NewReader(`PS1="\[\033[0;31m\][bound]\[\033[0m\] $PS1"` + "\n")
 			if err := platform.InstallFile(reader, someMach, "/etc/profile.d/kola-spawn-bound.sh"); err != nil {
@@ -214,6 +248,46 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// attachConsole connects the terminal to the serial console of m until
+// Ctrl-] is typed.
+func attachConsole(m platform.Machine) error {
+	qm, ok := m.(platform.QEMUMachine)
+	if !ok {
+		return errors.New("--console is only supported on qemu")
+	}
+	console := qm.Console()
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		tstate, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer terminal.Restore(fd, tstate)
+	}
+
+	fmt.Fprint(os.Stderr, "Attached to console; type Ctrl-] to detach\r\n")
+	detach := console.Attach(os.Stdout)
+	defer detach()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if i := bytes.IndexByte(buf[:n], 0x1d); i >= 0 {
+			_, err := console.Write(buf[:i])
+			return err
+		}
+		if _, err := console.Write(buf[:n]); err != nil {
+			return err
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
 func addSSHKeys(userdata *conf.UserData) (*conf.UserData, error) {
 	// if no keys specified, use keys from agent plus ~/.ssh/id_{rsa,dsa,ecdsa,ed25519}.pub
 	if len(spawnSSHKeys) == 0 {
@@ -137,7 +137,7 @@ func runUpdateTest() error {
 		os.Exit(1)
 	}
 
-	flight, err := qemu.NewFlight(&kola.QEMUOptions)
+	flight, err := qemu.NewFlight(&kola.QEMUOptions, nil)
 	if err != nil {
 		return fmt.Errorf("new flight: %v", err)
 	}
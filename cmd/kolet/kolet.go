@@ -19,8 +19,11 @@ import (
 
 	"github.com/coreos/pkg/capnslog"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 
 	"github.com/coreos/mantle/cli"
+	"github.com/coreos/mantle/kola/agent"
+	"github.com/coreos/mantle/kola/native"
 	"github.com/coreos/mantle/kola/register"
 
 	// Register any tests that we may wish to execute in kolet.
@@ -41,6 +44,12 @@ var (
 		Short: "Run a given test's native function",
 		Run:   run,
 	}
+
+	cmdAgent = &cobra.Command{
+		Use:   "agent",
+		Short: "Serve requests from kola on stdin and stdout",
+		Run:   runAgent,
+	}
 )
 
 func run(cmd *cobra.Command, args []string) {
@@ -48,9 +57,35 @@ func run(cmd *cobra.Command, args []string) {
 	os.Exit(2)
 }
 
+func runAgent(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if err := agent.Serve(os.Stdin, os.Stdout); err != nil {
+		plog.Fatal(err)
+	}
+}
+
+// nativeOutput returns kolet's stdout for the native function protocol,
+// and points stdout at stderr so that nothing else the function or its
+// children print can get mixed into the protocol.
+func nativeOutput() (*os.File, error) {
+	fd, err := unix.Dup(1)
+	if err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(fd)
+	if err := unix.Dup3(2, 1, 0); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "native"), nil
+}
+
 func main() {
 	for testName, testObj := range register.Tests {
-		if len(testObj.NativeFuncs) == 0 {
+		if len(testObj.NativeFuncs) == 0 && len(testObj.NativeJSONFuncs) == 0 {
 			continue
 		}
 		testCmd := &cobra.Command{
@@ -76,9 +111,33 @@ func main() {
 			}
 			testCmd.AddCommand(nativeCmd)
 		}
+		for nativeName := range testObj.NativeJSONFuncs {
+			nativeFunc := testObj.NativeJSONFuncs[nativeName]
+			nativeRun := func(cmd *cobra.Command, args []string) {
+				if len(args) != 0 {
+					cmd.Usage()
+					os.Exit(2)
+				}
+				out, err := nativeOutput()
+				if err != nil {
+					plog.Fatal(err)
+				}
+				// the error has been sent to kola with the output
+				if err := native.Serve(nativeFunc, os.Stdin, out); err != nil {
+					os.Exit(1)
+				}
+				os.Exit(0)
+			}
+			nativeCmd := &cobra.Command{
+				Use: nativeName,
+				Run: nativeRun,
+			}
+			testCmd.AddCommand(nativeCmd)
+		}
 		cmdRun.AddCommand(testCmd)
 	}
 	root.AddCommand(cmdRun)
+	root.AddCommand(cmdAgent)
 
 	cli.Execute(root)
 }
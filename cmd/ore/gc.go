@@ -0,0 +1,379 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/coreos/pkg/capnslog"
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/auth"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/api/aws"
+	"github.com/coreos/mantle/platform/api/azure"
+	"github.com/coreos/mantle/platform/api/do"
+	"github.com/coreos/mantle/platform/api/esx"
+	"github.com/coreos/mantle/platform/api/gcloud"
+	"github.com/coreos/mantle/platform/api/libvirt"
+	"github.com/coreos/mantle/platform/api/openstack"
+	"github.com/coreos/mantle/platform/api/packet"
+)
+
+var (
+	plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "ore")
+
+	cmdGC = &cobra.Command{
+		Use:   "gc",
+		Short: "GC resources on all configured platforms",
+		Long: `Delete resources created by mantle tools over the given duration ago
+on every selected platform, and write a JSON report of what was deleted.
+
+Platforms without credentials or configuration are skipped; any other
+error fails the command.  Google Compute Engine and
+Packet are only considered if Google credentials are given with
+--gce-json-key or --gce-service-auth.
+
+Images on Google Compute Engine and DigitalOcean carry no record of
+who created them, so they are only collected with --name-prefix or
+--tag.
+
+Only instances are collected unless other resource types are requested
+with --type.`,
+		RunE: runGC,
+	}
+
+	gcAll       bool
+	gcPlatforms []string
+	gcOutput    string
+	gcOpts      platform.GCOptions
+
+	gcAWSOptions       = aws.Options{Options: &platform.Options{}}
+	gcAzureOptions     = azure.Options{Options: &platform.Options{}}
+	gcDOOptions        = do.Options{Options: &platform.Options{}}
+	gcESXOptions       = esx.Options{Options: &platform.Options{}}
+	gcGCEOptions       = gcloud.Options{Options: &platform.Options{}}
+	gcLibvirtOptions   = libvirt.Options{Options: &platform.Options{}}
+	gcOpenStackOptions = openstack.Options{Options: &platform.Options{}}
+	gcPacketOptions    = packet.Options{Options: &platform.Options{}}
+
+	// gcCollectors maps platform names to functions which collect
+	// garbage on that platform.  They return a gcUnconfigured error if
+	// the platform has no credentials or configuration.
+	gcCollectors = map[string]func() (*platform.GCReport, error){
+		"aws":       gcAWS,
+		"azure":     gcAzure,
+		"do":        gcDO,
+		"esx":       gcESX,
+		"gce":       gcGCE,
+		"libvirt":   gcLibvirt,
+		"openstack": gcOpenStack,
+		"packet":    gcPacket,
+	}
+)
+
+func init() {
+	root.AddCommand(cmdGC)
+
+	bv := cmdGC.Flags().BoolVar
+	sv := cmdGC.Flags().StringVar
+	ssv := cmdGC.Flags().StringSliceVar
+
+	bv(&gcAll, "all", false, "collect on all platforms")
+	ssv(&gcPlatforms, "platform", nil, "platforms to collect on: "+strings.Join(gcPlatformNames(), ", "))
+	sv(&gcOutput, "output", "-", "file for the JSON report, or - for stdout")
+	cmdGC.Flags().DurationVar(&gcOpts.GracePeriod, "duration", 5*time.Hour, "how old resources must be before they're considered garbage")
+	bv(&gcOpts.DryRun, "dry-run", false, "list candidates without deleting them")
+	sv(&gcOpts.NamePrefix, "name-prefix", "", "only consider resources whose name has this prefix")
+	sv(&gcOpts.Tag, "tag", "", "only consider resources with this tag (key or key=value on platforms with key/value tags)")
+	ssv(&gcOpts.Types, "type", nil, "resource types to collect: "+strings.Join(platform.GCTypes, ", ")+" (default instance)")
+
+	// AWS
+	sv(&gcAWSOptions.Region, "aws-region", "us-west-2", "AWS region")
+	sv(&gcAWSOptions.CredentialsFile, "aws-credentials-file", "", "AWS credentials file")
+	sv(&gcAWSOptions.Profile, "aws-profile", "", "AWS profile name")
+
+	// Azure
+	sv(&gcAzureOptions.AzureProfile, "azure-profile", "", "Azure profile json file")
+	sv(&gcAzureOptions.AzureAuthLocation, "azure-auth", "", "Azure auth location")
+	sv(&gcAzureOptions.AzureSubscription, "azure-subscription", "", "Azure subscription name. If unset, the first is used.")
+	sv(&gcAzureOptions.Location, "azure-location", "westus", "Azure location")
+
+	// DigitalOcean
+	sv(&gcDOOptions.ConfigPath, "do-config-file", "", "DigitalOcean config file")
+	sv(&gcDOOptions.Profile, "do-profile", "", "DigitalOcean profile")
+
+	// ESX
+	sv(&gcESXOptions.ConfigPath, "esx-config-file", "", "ESX config file")
+	sv(&gcESXOptions.Profile, "esx-profile", "", "ESX profile")
+	sv(&gcESXOptions.BaseVMName, "esx-base-vm", "", "ESX base VM name, which is never collected")
+
+	// GCE
+	sv(&gcGCEOptions.Project, "gce-project", "coreos-gce-testing", "GCE project")
+	sv(&gcGCEOptions.Zone, "gce-zone", "us-central1-a", "GCE zone")
+	sv(&gcGCEOptions.JSONKeyFile, "gce-json-key", "", "use a service account's JSON key for authentication")
+	bv(&gcGCEOptions.ServiceAuth, "gce-service-auth", false, "use non-interactive auth when running within GCE")
+
+	// libvirt
+	sv(&gcLibvirtOptions.URI, "libvirt-uri", "qemu:///session", "libvirt connection URI")
+	sv(&gcLibvirtOptions.Network, "libvirt-network", "auto", "libvirt networking used by kola: auto, nat, user")
+
+	// OpenStack
+	sv(&gcOpenStackOptions.ConfigPath, "openstack-config-file", "", "OpenStack config file")
+	sv(&gcOpenStackOptions.Profile, "openstack-profile", "", "OpenStack profile")
+
+	// Packet
+	sv(&gcPacketOptions.ConfigPath, "packet-config-file", "", "Packet config file")
+	sv(&gcPacketOptions.Profile, "packet-profile", "", "Packet profile")
+	sv(&gcPacketOptions.Project, "packet-project", "", "Packet project UUID (overrides config file)")
+}
+
+// gcUnconfigured is returned by collectors for platforms which have no
+// credentials or configuration, and so are skipped rather than failed.
+type gcUnconfigured struct {
+	err error
+}
+
+func (e gcUnconfigured) Error() string {
+	return e.err.Error()
+}
+
+// gcConfigFile returns a gcUnconfigured error if the config file at path,
+// or at defaultPath under the home directory if path is empty, is missing.
+func gcConfigFile(path, defaultPath string) error {
+	if path == "" {
+		user, err := user.Current()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(user.HomeDir, defaultPath)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return gcUnconfigured{err}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func gcPlatformNames() []string {
+	var names []string
+	for name := range gcCollectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Unrecognized args in ore gc cmd: %v", args)
+	}
+
+	platforms := gcPlatforms
+	if gcAll {
+		platforms = gcPlatformNames()
+	}
+	if len(platforms) == 0 {
+		return fmt.Errorf("Specify --all or at least one --platform")
+	}
+	for _, name := range platforms {
+		if _, ok := gcCollectors[name]; !ok {
+			return fmt.Errorf("Unknown platform %q", name)
+		}
+	}
+	for _, typ := range gcOpts.Types {
+		known := false
+		for _, t := range platform.GCTypes {
+			known = known || t == typ
+		}
+		if !known {
+			return fmt.Errorf("Unknown resource type %q", typ)
+		}
+	}
+
+	report := platform.NewGCReport(&gcOpts)
+	var wg sync.WaitGroup
+	for _, name := range platforms {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			r, err := gcCollectors[name]()
+			if _, ok := err.(gcUnconfigured); ok {
+				plog.Noticef("Skipping %s: %v", name, err)
+				report.Skip(name, err.Error())
+				return
+			} else if err != nil {
+				report.Fail(name, "", err)
+				return
+			}
+			report.Merge(r)
+		}(name)
+	}
+	wg.Wait()
+
+	var out io.Writer = os.Stdout
+	if gcOutput != "-" {
+		f, err := os.Create(gcOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("writing report: %v", err)
+	}
+
+	if err := report.Err(); err != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("Couldn't gc: %v", err)
+	}
+	return nil
+}
+
+func gcAWS() (*platform.GCReport, error) {
+	api, err := aws.New(&gcAWSOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.PreflightCheck(); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			switch awsErr.Code() {
+			case "NoCredentialProviders", "SharedCredsLoad":
+				return nil, gcUnconfigured{err}
+			}
+		}
+		return nil, err
+	}
+	return api.GCWithOptions(&gcOpts), nil
+}
+
+func gcAzure() (*platform.GCReport, error) {
+	if err := gcConfigFile(gcAzureOptions.AzureProfile, auth.AzureProfilePath); err != nil {
+		return nil, err
+	}
+	api, err := azure.New(&gcAzureOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.SetupClients(); err != nil {
+		return nil, err
+	}
+	return api.GCWithOptions(&gcOpts), nil
+}
+
+func gcDO() (*platform.GCReport, error) {
+	if err := gcConfigFile(gcDOOptions.ConfigPath, auth.DOConfigPath); err != nil {
+		return nil, err
+	}
+	api, err := do.New(&gcDOOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.PreflightCheck(context.Background()); err != nil {
+		return nil, err
+	}
+	return api.GCWithOptions(context.Background(), &gcOpts), nil
+}
+
+func gcESX() (*platform.GCReport, error) {
+	if err := gcConfigFile(gcESXOptions.ConfigPath, auth.ESXConfigPath); err != nil {
+		return nil, err
+	}
+	api, err := esx.New(&gcESXOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.PreflightCheck(); err != nil {
+		return nil, err
+	}
+	return api.GCWithOptions(&gcOpts), nil
+}
+
+func gcGCE() (*platform.GCReport, error) {
+	if gcGCEOptions.JSONKeyFile == "" && !gcGCEOptions.ServiceAuth {
+		return nil, gcUnconfigured{fmt.Errorf("no Google credentials given")}
+	}
+	// gcloud.New rewrites the options, and packet shares them
+	gceOptions := gcGCEOptions
+	api, err := gcloud.New(&gceOptions)
+	if err != nil {
+		return nil, err
+	}
+	return api.GCWithOptions(&gcOpts), nil
+}
+
+func gcLibvirt() (*platform.GCReport, error) {
+	if _, err := exec.LookPath("virsh"); err != nil {
+		return nil, gcUnconfigured{err}
+	}
+	api, err := libvirt.New(&gcLibvirtOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.PreflightCheck(); err != nil {
+		return nil, err
+	}
+	return api.GCWithOptions(&gcOpts), nil
+}
+
+func gcOpenStack() (*platform.GCReport, error) {
+	if err := gcConfigFile(gcOpenStackOptions.ConfigPath, auth.OpenStackConfigPath); err != nil {
+		return nil, err
+	}
+	api, err := openstack.New(&gcOpenStackOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.PreflightCheck(); err != nil {
+		return nil, err
+	}
+	return api.GCWithOptions(&gcOpts), nil
+}
+
+func gcPacket() (*platform.GCReport, error) {
+	if gcGCEOptions.JSONKeyFile == "" && !gcGCEOptions.ServiceAuth {
+		return nil, gcUnconfigured{fmt.Errorf("no Google credentials given")}
+	}
+	if err := gcConfigFile(gcPacketOptions.ConfigPath, auth.PacketConfigPath); err != nil {
+		return nil, err
+	}
+	gsOptions := gcGCEOptions
+	gcPacketOptions.GSOptions = &gsOptions
+	gcPacketOptions.Board = "amd64-usr"
+	api, err := packet.New(&gcPacketOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.PreflightCheck(); err != nil {
+		return nil, err
+	}
+	return api.GCWithOptions(&gcOpts), nil
+}
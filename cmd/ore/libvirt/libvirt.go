@@ -0,0 +1,62 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/cli"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/api/libvirt"
+)
+
+var (
+	plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "ore/libvirt")
+
+	Libvirt = &cobra.Command{
+		Use:   "libvirt [command]",
+		Short: "libvirt machine utilities",
+	}
+
+	API     *libvirt.API
+	options = libvirt.Options{Options: &platform.Options{}}
+)
+
+func init() {
+	Libvirt.PersistentFlags().StringVar(&options.URI, "uri", "qemu:///session", "libvirt connection URI")
+	Libvirt.PersistentFlags().StringVar(&options.Network, "network", "auto", "networking used by kola: auto, nat, user")
+	cli.WrapPreRun(Libvirt, preflightCheck)
+}
+
+func preflightCheck(cmd *cobra.Command, args []string) error {
+	plog.Debugf("Running libvirt preflight check.")
+	api, err := libvirt.New(&options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not create libvirt client: %v\n", err)
+		os.Exit(1)
+	}
+	if err = api.PreflightCheck(); err != nil {
+		fmt.Fprintf(os.Stderr, "could not complete libvirt preflight check: %v\n", err)
+		os.Exit(1)
+	}
+
+	plog.Debugf("Preflight check success; we have liftoff")
+	API = api
+	return nil
+}
@@ -10,7 +10,7 @@ require (
 	github.com/alecthomas/units v0.0.0-20151022065526-2efee857e7cf // indirect
 	github.com/aws/aws-sdk-go v1.19.11
 	github.com/beorn7/perks v1.0.0 // indirect
-	github.com/coreos/bbolt v1.3.1-coreos.6 // indirect
+	github.com/coreos/bbolt v1.3.1-coreos.6
 	github.com/coreos/container-linux-config-transpiler v0.8.0
 	github.com/coreos/coreos-cloudinit v1.11.0
 	github.com/coreos/etcd v3.3.9+incompatible
@@ -45,7 +45,7 @@ import (
 // The other reporting methods, such as the variations of Log and Error,
 // may be called simultaneously from multiple goroutines.
 type H struct {
-	mu       sync.RWMutex // guards output, failed, and done.
+	mu       sync.RWMutex // guards output, failed, done, artifacts, and metrics.
 	output   bytes.Buffer // Output generated by test.
 	w        io.Writer    // For flushToParent.
 	tap      io.Writer    // Optional TAP log of test results.
@@ -70,8 +70,11 @@ type H struct {
 	sub      []*H      // Queue of subtests to be run in parallel.
 
 	isParallel bool
+	artifacts  []reporters.Artifact
+	metrics    []*metric
 
 	reporters reporters.Reporters
+	events    reporters.EventSinks
 }
 
 func (c *H) parentContext() context.Context {
@@ -116,6 +119,9 @@ func (c *H) flushToParent(format string, args ...interface{}) {
 		} else {
 			fmt.Fprintf(p.tap, "ok - %s\n", name)
 		}
+		for _, a := range c.Artifacts() {
+			fmt.Fprintf(p.tap, "# artifact: %s\n", a.Path)
+		}
 	}
 
 	c.mu.Lock()
@@ -231,8 +237,19 @@ func (c *H) FailNow() {
 // log generates the output. It's always at the same stack depth.
 func (c *H) log(s string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.logger.Output(3, s)
+	c.mu.Unlock()
+	c.event(reporters.EventLog, strings.TrimSuffix(s, "\n"))
+}
+
+// event sends an event for the test to the suite's event sinks.
+func (c *H) event(typ reporters.EventType, message string) {
+	c.events.Event(reporters.Event{
+		Time:    time.Now(),
+		Type:    typ,
+		Test:    c.name,
+		Message: message,
+	})
 }
 
 // Log formats its arguments using default formatting, analogous to Println,
@@ -359,6 +376,166 @@ func (h *H) TempFile(prefix string) *os.File {
 	return tmp
 }
 
+// artifactPath returns where the artifact called name is stored, and
+// creates its directory.
+func (h *H) artifactPath(name string) (string, error) {
+	clean := filepath.Clean(name)
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid artifact name %q", name)
+	}
+	dir, err := h.mkOutputDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "artifacts", clean)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return "", fmt.Errorf("Failed to create artifact dir: %v", err)
+	}
+	return path, nil
+}
+
+// addArtifact lists the artifact stored at path in the test's reports,
+// replacing any earlier artifact with the same name.
+func (h *H) addArtifact(name, path, contentType string) {
+	rel, err := filepath.Rel(h.suite.opts.OutputDir, path)
+	if err != nil {
+		rel = path
+	}
+	a := reporters.Artifact{
+		Name:        filepath.Clean(name),
+		Path:        rel,
+		ContentType: contentType,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.artifacts {
+		if h.artifacts[i].Name == a.Name {
+			h.artifacts[i] = a
+			return
+		}
+	}
+	h.artifacts = append(h.artifacts, a)
+}
+
+// Artifact attaches the contents of r to the test as name, which may
+// contain slashes.  Artifacts are stored in the "artifacts" directory
+// under OutputDir and listed in the suite's reports.
+func (h *H) Artifact(name string, r io.Reader, contentType string) error {
+	path, err := h.artifactPath(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	h.addArtifact(name, path, contentType)
+	return nil
+}
+
+// ArtifactFile attaches the file at path to the test as name, like
+// Artifact.  The file is hard linked if possible rather than copied.
+func (h *H) ArtifactFile(name, path, contentType string) error {
+	dst, err := h.artifactPath(name)
+	if err != nil {
+		return err
+	}
+	os.Remove(dst)
+	if err := os.Link(path, dst); err == nil {
+		h.addArtifact(name, dst, contentType)
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return h.Artifact(name, f, contentType)
+}
+
+// Artifacts returns the artifacts attached to the test.
+func (h *H) Artifacts() []reporters.Artifact {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]reporters.Artifact(nil), h.artifacts...)
+}
+
+// metric holds the samples of a measurement reported by a test.
+type metric struct {
+	name    string
+	unit    string
+	samples []float64
+}
+
+func (m *metric) summary() reporters.Metric {
+	r := reporters.Metric{
+		Name:    m.name,
+		Unit:    m.unit,
+		Min:     m.samples[0],
+		Max:     m.samples[0],
+		Samples: len(m.samples),
+	}
+	var sum float64
+	for _, v := range m.samples {
+		sum += v
+		if v < r.Min {
+			r.Min = v
+		}
+		if v > r.Max {
+			r.Max = v
+		}
+	}
+	r.Value = sum / float64(len(m.samples))
+	return r
+}
+
+// ReportMetric records a sample of the measurement name, in unit, such
+// as ("boot", 12.5, "s").  A test reporting name several times, once per
+// iteration of a benchmark, is reported with the mean, minimum and
+// maximum of its samples.  Reporting a measurement in a different unit
+// than before fails the test, and the sample is dropped.
+func (h *H) ReportMetric(name string, value float64, unit string) {
+	h.mu.Lock()
+	for _, m := range h.metrics {
+		if m.name == name {
+			if m.unit != unit {
+				h.mu.Unlock()
+				h.Errorf("metric %s reported in %s and %s", name, m.unit, unit)
+				return
+			}
+			m.samples = append(m.samples, value)
+			h.mu.Unlock()
+			return
+		}
+	}
+	h.metrics = append(h.metrics, &metric{
+		name:    name,
+		unit:    unit,
+		samples: []float64{value},
+	})
+	h.mu.Unlock()
+}
+
+// Metrics returns the measurements reported by the test.
+func (h *H) Metrics() []reporters.Metric {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var metrics []reporters.Metric
+	for _, m := range h.metrics {
+		metrics = append(metrics, m.summary())
+	}
+	return metrics
+}
+
 // Parallel signals that this test is to be run in parallel with (and only with)
 // other parallel tests.
 func (t *H) Parallel() {
@@ -381,6 +558,22 @@ func (t *H) Parallel() {
 	t.start = time.Now()
 }
 
+// Block calls wait, which blocks until something outside the test
+// happens, such as another test finishing.  A parallel test gives up its
+// slot while it waits so that the suite can run other tests meanwhile.
+// Like Parallel, Block must be called from the goroutine running the test.
+func (t *H) Block(wait func()) {
+	if !t.isParallel {
+		wait()
+		return
+	}
+	t.duration += time.Since(t.start)
+	t.suite.release()
+	wait()
+	t.suite.waitParallel()
+	t.start = time.Now()
+}
+
 func tRunner(t *H, fn func(t *H)) {
 	t.ctx, t.cancel = context.WithCancel(t.parentContext())
 	defer t.cancel()
@@ -453,6 +646,7 @@ func (t *H) Run(name string, f func(t *H)) bool {
 		parent:    t,
 		level:     t.level + 1,
 		reporters: t.reporters,
+		events:    t.events,
 	}
 	t.w = indenter{t}
 	// Indent logs 8 spaces to distinguish them from sub-test headers.
@@ -466,6 +660,7 @@ func (t *H) Run(name string, f func(t *H)) bool {
 		}
 		fmt.Fprintf(root.w, "=== RUN   %s\n", t.name)
 	}
+	t.event(reporters.EventStart, "")
 	// Instead of reducing the running count of this test before calling the
 	// tRunner and increasing it afterwards, we rely on tRunner keeping the
 	// count correct. This ensures that a sequence of sequential tests runs
@@ -500,7 +695,20 @@ func (t *H) report() {
 	// could also write verbosely to the 'reporter sink'.  I'm fine with
 	// this being a TODO if you don't want to tackle it in this initial
 	// PR.
-	t.reporters.ReportTest(t.name, status, t.duration, t.output.Bytes())
+	t.reporters.ReportTest(reporters.TestReport{
+		Name:      t.name,
+		Result:    status,
+		Duration:  t.duration,
+		Output:    t.output.Bytes(),
+		Artifacts: t.Artifacts(),
+		Metrics:   t.Metrics(),
+	})
+	t.events.Event(reporters.Event{
+		Time:     time.Now(),
+		Type:     reporters.ResultEvent(status),
+		Test:     t.name,
+		Duration: t.duration,
+	})
 }
 
 // CleanOutputDir creates/empties an output directory and returns the cleaned path.
@@ -0,0 +1,207 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+type EventType string
+
+const (
+	EventStart EventType = "start"
+	EventLog   EventType = "log"
+	EventSkip  EventType = "skip"
+	EventFail  EventType = "fail"
+	EventPass  EventType = "pass"
+)
+
+// ResultEvent returns the event ending a test with result.
+func ResultEvent(result testresult.TestResult) EventType {
+	switch result {
+	case testresult.Fail:
+		return EventFail
+	case testresult.Skip:
+		return EventSkip
+	default:
+		return EventPass
+	}
+}
+
+// Event is something that happened to a test or subtest while the
+// suite ran.
+type Event struct {
+	Time time.Time `json:"time"`
+	Type EventType `json:"type"`
+	Test string    `json:"test"`
+
+	// Message is the text of a log event.
+	Message string `json:"message,omitempty"`
+	// Duration is the run time of a test, in its result event.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// EventSink receives events as they happen, unlike a Reporter which
+// only writes its report once the suite completes.  Event may be called
+// from several goroutines at once.
+type EventSink interface {
+	Event(Event)
+	Close() error
+}
+
+type EventSinks []EventSink
+
+func (sinks EventSinks) Event(e Event) {
+	for _, s := range sinks {
+		s.Event(e)
+	}
+}
+
+func (sinks EventSinks) Close() error {
+	var err error
+	for _, s := range sinks {
+		if err2 := s.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// eventBuffer is how many events a sink holds for a slow reader
+// before it starts dropping them.
+const eventBuffer = 4096
+
+// closeTimeout is how long Close waits for a stalled reader to take
+// the remaining events.
+var closeTimeout = 10 * time.Second
+
+type jsonLinesSink struct {
+	dropped uint64 // first for alignment; accessed atomically
+
+	w      io.WriteCloser
+	events chan Event
+	done   chan struct{}
+
+	lock   sync.Mutex
+	closed bool
+	err    error // set by the writer before done is closed
+}
+
+// NewJSONLinesSink writes each event to w as a line of JSON.  Events
+// are written in the background so a slow reader doesn't hold up the
+// tests; if the reader falls too far behind, events are dropped and a
+// log event records how many.
+func NewJSONLinesSink(w io.WriteCloser) EventSink {
+	s := &jsonLinesSink{
+		w:      w,
+		events: make(chan Event, eventBuffer),
+		done:   make(chan struct{}),
+	}
+	go s.write()
+	return s
+}
+
+// NewEventStream writes events as lines of JSON to target, which is a
+// file, "unix:PATH" to connect to a Unix socket, or "tcp:HOST:PORT" to
+// connect to a TCP socket.
+func NewEventStream(target string) (EventSink, error) {
+	var w io.WriteCloser
+	var err error
+	switch {
+	case strings.HasPrefix(target, "unix:"):
+		w, err = net.Dial("unix", strings.TrimPrefix(target, "unix:"))
+	case strings.HasPrefix(target, "tcp:"):
+		w, err = net.Dial("tcp", strings.TrimPrefix(target, "tcp:"))
+	default:
+		w, err = os.Create(target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesSink(w), nil
+}
+
+func (s *jsonLinesSink) write() {
+	defer close(s.done)
+	enc := json.NewEncoder(s.w)
+	for e := range s.events {
+		// a reader going away mustn't stop the suite, so keep the
+		// first error for Close and discard the rest
+		if s.err != nil {
+			continue
+		}
+		if n := atomic.SwapUint64(&s.dropped, 0); n > 0 {
+			s.err = enc.Encode(Event{
+				Time:    e.Time,
+				Type:    EventLog,
+				Message: fmt.Sprintf("harness: dropped %d events for a slow reader", n),
+			})
+			if s.err != nil {
+				continue
+			}
+		}
+		s.err = enc.Encode(e)
+	}
+}
+
+func (s *jsonLinesSink) Event(e Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+func (s *jsonLinesSink) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.events)
+	s.lock.Unlock()
+
+	// closing w unblocks a writer stuck on a stalled reader
+	select {
+	case <-s.done:
+	case <-time.After(closeTimeout):
+	}
+	err := s.w.Close()
+	<-s.done
+	if s.err != nil {
+		return s.err
+	}
+	if n := atomic.LoadUint64(&s.dropped); n > 0 {
+		return fmt.Errorf("dropped %d events for a slow reader", n)
+	}
+	return err
+}
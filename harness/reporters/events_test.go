@@ -0,0 +1,81 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLinesSink(t *testing.T) {
+	r, w := io.Pipe()
+	sink := NewJSONLinesSink(w)
+	lines := make(chan []string)
+	go func() {
+		var got []string
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var e Event
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				t.Error(err)
+			}
+			got = append(got, e.Test+" "+string(e.Type))
+		}
+		lines <- got
+	}()
+
+	sink.Event(Event{Type: EventStart, Test: "a"})
+	sink.Event(Event{Type: EventPass, Test: "a"})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sink.Event(Event{Type: EventStart, Test: "b"})
+
+	expected := "a start,a pass"
+	if got := strings.Join(<-lines, ","); got != expected {
+		t.Errorf("got events %q, expected %q", got, expected)
+	}
+}
+
+func TestJSONLinesSinkStalled(t *testing.T) {
+	defer func(timeout time.Duration) {
+		closeTimeout = timeout
+	}(closeTimeout)
+	closeTimeout = 10 * time.Millisecond
+
+	// nothing reads the pipe, so the writer blocks on the first event
+	_, w := io.Pipe()
+	sink := NewJSONLinesSink(w)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*eventBuffer; i++ {
+			sink.Event(Event{Type: EventLog, Test: "a"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Event blocked on a stalled reader")
+	}
+
+	if err := sink.Close(); err == nil {
+		t.Error("Close didn't report the stalled reader")
+	}
+}
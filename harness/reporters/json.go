@@ -34,10 +34,12 @@ type jsonReporter struct {
 }
 
 type jsonTest struct {
-	Name     string                `json:"name"`
-	Result   testresult.TestResult `json:"result"`
-	Duration time.Duration         `json:"duration"`
-	Output   string                `json:"output"`
+	Name      string                `json:"name"`
+	Result    testresult.TestResult `json:"result"`
+	Duration  time.Duration         `json:"duration"`
+	Output    string                `json:"output"`
+	Artifacts []Artifact            `json:"artifacts,omitempty"`
+	Metrics   []Metric              `json:"metrics,omitempty"`
 }
 
 func NewJSONReporter(filename, platform, version string) *jsonReporter {
@@ -48,12 +50,14 @@ func NewJSONReporter(filename, platform, version string) *jsonReporter {
 	}
 }
 
-func (r *jsonReporter) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte) {
+func (r *jsonReporter) ReportTest(report TestReport) {
 	r.Tests = append(r.Tests, jsonTest{
-		Name:     name,
-		Result:   result,
-		Duration: duration,
-		Output:   string(b),
+		Name:      report.Name,
+		Result:    report.Result,
+		Duration:  report.Duration,
+		Output:    string(report.Output),
+		Artifacts: report.Artifacts,
+		Metrics:   report.Metrics,
 	})
 }
 
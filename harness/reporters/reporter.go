@@ -22,9 +22,9 @@ import (
 
 type Reporters []Reporter
 
-func (reps Reporters) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte) {
+func (reps Reporters) ReportTest(report TestReport) {
 	for _, r := range reps {
-		r.ReportTest(name, result, duration, b)
+		r.ReportTest(report)
 	}
 }
 
@@ -44,8 +44,35 @@ func (reps Reporters) SetResult(s testresult.TestResult) {
 	}
 }
 
+// TestReport is the outcome of a single test.
+type TestReport struct {
+	Name      string
+	Result    testresult.TestResult
+	Duration  time.Duration
+	Output    []byte
+	Artifacts []Artifact
+	Metrics   []Metric
+}
+
+// Artifact is a file attached to a test.
+type Artifact struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"` // relative to the suite's output directory
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// Metric summarizes the values a test reported for a measurement.
+type Metric struct {
+	Name    string  `json:"name"`
+	Unit    string  `json:"unit"`
+	Value   float64 `json:"value"` // mean of the samples
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Samples int     `json:"samples"`
+}
+
 type Reporter interface {
-	ReportTest(string, testresult.TestResult, time.Duration, []byte)
+	ReportTest(TestReport)
 	Output(string) error
 	SetResult(testresult.TestResult)
 }
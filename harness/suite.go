@@ -75,6 +75,10 @@ type Options struct {
 	Parallel int
 
 	Reporters reporters.Reporters
+
+	// Events receive each test's start, logs and result as they happen.
+	// They are closed when the suite completes.
+	Events reporters.EventSinks
 }
 
 // FlagSet can be used to setup options via command line flags.
@@ -193,6 +197,12 @@ func (s *Suite) Run() (err error) {
 		f.Close()
 	}
 
+	defer func() {
+		if eventsErr := s.opts.Events.Close(); eventsErr != nil && err == nil {
+			err = fmt.Errorf("harness: can't write events: %v", eventsErr)
+		}
+	}()
+
 	outputDir, err := CleanOutputDir(s.opts.OutputDir)
 	if err != nil {
 		return err
@@ -282,6 +292,7 @@ func (s *Suite) runTests(out, tap io.Writer) error {
 		tap:       tap,
 		suite:     s,
 		reporters: s.opts.Reporters,
+		events:    s.opts.Events,
 	}
 	tRunner(t, func(t *H) {
 		for name, test := range s.tests {
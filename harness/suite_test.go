@@ -16,7 +16,16 @@
 package harness
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/coreos/mantle/harness/reporters"
+	"github.com/coreos/mantle/harness/testresult"
 )
 
 func TestSuiteParallelism(t *testing.T) {
@@ -111,3 +120,204 @@ func TestSuiteParallelism(t *testing.T) {
 		}
 	}
 }
+
+type recordingSink struct {
+	lock   sync.Mutex
+	events []reporters.Event
+	closed bool
+}
+
+func (r *recordingSink) Event(e reporters.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recordingSink) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestSuiteEvents(t *testing.T) {
+	sink := &recordingSink{}
+	suite := NewSuite(Options{
+		OutputDir: "_test_temp",
+		Parallel:  1,
+		Events:    reporters.EventSinks{sink},
+	}, Tests{
+		"Events": func(h *H) {
+			h.Run("pass", func(h *H) {
+				h.Log("hello")
+			})
+			h.Run("skip", func(h *H) {
+				h.Skip("skipping")
+			})
+			h.Run("fail", func(h *H) {
+				h.Fatal("failing")
+			})
+		},
+	})
+	defer os.RemoveAll("_test_temp")
+	if err := suite.Run(); err != SuiteFailed {
+		t.Fatalf("expected SuiteFailed, got %v", err)
+	}
+	if !sink.closed {
+		t.Error("event sink not closed")
+	}
+
+	type event struct {
+		typ     reporters.EventType
+		test    string
+		message string
+	}
+	expected := []event{
+		{reporters.EventStart, "Events", ""},
+		{reporters.EventStart, "Events/pass", ""},
+		{reporters.EventLog, "Events/pass", "hello"},
+		{reporters.EventPass, "Events/pass", ""},
+		{reporters.EventStart, "Events/skip", ""},
+		{reporters.EventLog, "Events/skip", "skipping"},
+		{reporters.EventSkip, "Events/skip", ""},
+		{reporters.EventStart, "Events/fail", ""},
+		{reporters.EventLog, "Events/fail", "failing"},
+		{reporters.EventFail, "Events/fail", ""},
+		{reporters.EventFail, "Events", ""},
+	}
+	var got []event
+	for _, e := range sink.events {
+		got = append(got, event{e.Type, e.Test, e.Message})
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got events %v, expected %v", got, expected)
+	}
+}
+
+type recordingReporter struct {
+	lock      sync.Mutex
+	artifacts map[string][]reporters.Artifact
+	metrics   map[string][]reporters.Metric
+}
+
+func newRecordingReporter() *recordingReporter {
+	return &recordingReporter{
+		artifacts: make(map[string][]reporters.Artifact),
+		metrics:   make(map[string][]reporters.Metric),
+	}
+}
+
+func (r *recordingReporter) ReportTest(report reporters.TestReport) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.artifacts[report.Name] = report.Artifacts
+	r.metrics[report.Name] = report.Metrics
+}
+
+func (r *recordingReporter) Output(path string) error { return nil }
+
+func (r *recordingReporter) SetResult(result testresult.TestResult) {}
+
+func TestSuiteArtifacts(t *testing.T) {
+	rep := newRecordingReporter()
+	suite := NewSuite(Options{
+		OutputDir: "_test_temp",
+		Reporters: reporters.Reporters{rep},
+	}, Tests{
+		"Artifacts": func(h *H) {
+			if err := h.Artifact("logs/hello.txt", strings.NewReader("hello"), "text/plain"); err != nil {
+				h.Fatal(err)
+			}
+			src := filepath.Join(h.TempDir("src"), "world.txt")
+			if err := ioutil.WriteFile(src, []byte("world"), 0644); err != nil {
+				h.Fatal(err)
+			}
+			if err := h.ArtifactFile("world.txt", src, ""); err != nil {
+				h.Fatal(err)
+			}
+			if err := h.Artifact("../escape", strings.NewReader(""), ""); err == nil {
+				h.Error("artifact outside the output directory accepted")
+			}
+		},
+	})
+	defer os.RemoveAll("_test_temp")
+	if err := suite.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []reporters.Artifact{
+		{Name: "logs/hello.txt", Path: "Artifacts/artifacts/logs/hello.txt", ContentType: "text/plain"},
+		{Name: "world.txt", Path: "Artifacts/artifacts/world.txt"},
+	}
+	if got := rep.artifacts["Artifacts"]; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got artifacts %v, expected %v", got, expected)
+	}
+	for _, a := range expected {
+		data, err := ioutil.ReadFile(filepath.Join("_test_temp", a.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := strings.TrimSuffix(filepath.Base(a.Name), ".txt"); string(data) != expected {
+			t.Errorf("artifact %s contains %q, expected %q", a.Name, data, expected)
+		}
+	}
+}
+
+func TestSuiteBlock(t *testing.T) {
+	done := make(chan struct{})
+	suite := NewSuite(Options{
+		OutputDir: "_test_temp",
+		Parallel:  1,
+	}, Tests{
+		"Block": func(h *H) {
+			// the waiting test mustn't starve the one it waits for
+			h.Run("waiter", func(h *H) {
+				h.Parallel()
+				h.Block(func() { <-done })
+			})
+			h.Run("waitee", func(h *H) {
+				h.Parallel()
+				close(done)
+			})
+		},
+	})
+	defer os.RemoveAll("_test_temp")
+	if err := suite.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuiteMetrics(t *testing.T) {
+	rep := newRecordingReporter()
+	suite := NewSuite(Options{
+		OutputDir: "_test_temp",
+		Reporters: reporters.Reporters{rep},
+	}, Tests{
+		"Metrics": func(h *H) {
+			for _, v := range []float64{3, 1, 2} {
+				h.ReportMetric("boot", v, "s")
+			}
+			h.ReportMetric("throughput", 100, "MB/s")
+		},
+		"UnitChange": func(h *H) {
+			h.ReportMetric("boot", 1, "s")
+			h.ReportMetric("boot", 1000, "ms")
+		},
+	})
+	defer os.RemoveAll("_test_temp")
+	if err := suite.Run(); err != SuiteFailed {
+		t.Fatalf("got %v, expected the unit change to fail", err)
+	}
+
+	expected := []reporters.Metric{
+		{Name: "boot", Unit: "s", Value: 2, Min: 1, Max: 3, Samples: 3},
+		{Name: "throughput", Unit: "MB/s", Value: 100, Min: 100, Max: 100, Samples: 1},
+	}
+	if got := rep.metrics["Metrics"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got metrics %v, expected %v", got, expected)
+	}
+	expected = []reporters.Metric{
+		{Name: "boot", Unit: "s", Value: 1, Min: 1, Max: 1, Samples: 1},
+	}
+	if got := rep.metrics["UnitChange"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got metrics %v after a unit change, expected %v", got, expected)
+	}
+}
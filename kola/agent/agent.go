@@ -0,0 +1,322 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agent is a JSON-RPC service which kolet runs on a machine for
+// the lifetime of a test, so that kola can make many requests of the
+// machine over one connection instead of a new SSH session each.
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ServiceName is the name of the service's methods, as in
+// "Agent.Exec".
+const ServiceName = "Agent"
+
+type ExecArgs struct {
+	Command string // run with sh -c
+	Stdin   []byte
+	Timeout time.Duration // kill the command after this long, if set
+}
+
+type ExecResult struct {
+	Stdout     []byte
+	Stderr     []byte
+	ExitStatus int
+}
+
+type WriteFileArgs struct {
+	Path string
+	Data []byte
+	Mode os.FileMode
+}
+
+type FileInfo struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	IsDir   bool
+}
+
+type UnitStatus struct {
+	LoadState   string
+	ActiveState string
+	SubState    string
+}
+
+type WaitForUnitArgs struct {
+	Unit    string
+	State   string // ActiveState to wait for; "active" if empty
+	Timeout time.Duration
+}
+
+type JournalArgs struct {
+	Cursor string // return entries after this cursor, or all if empty
+	Unit   string // only return entries of this unit, if set
+}
+
+type JournalEntry struct {
+	Cursor  string
+	Unit    string
+	Message string
+}
+
+// Agent implements the service.  Its methods are called through
+// net/rpc rather than directly.
+type Agent struct{}
+
+// Serve serves requests read from in, writing responses to out, until
+// in is closed.
+func Serve(in io.Reader, out io.Writer) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, &Agent{}); err != nil {
+		return err
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(&stdioConn{in, out}))
+	return nil
+}
+
+type stdioConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (c *stdioConn) Close() error {
+	return nil
+}
+
+func (a *Agent) Exec(args *ExecArgs, result *ExecResult) error {
+	ctx := context.Background()
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", args.Command)
+	cmd.Stdin = bytes.NewReader(args.Stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// run the command in its own process group so that its children
+	// are killed with it, rather than holding stdout open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+	err := cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%q timed out after %v", args.Command, args.Timeout)
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			result.ExitStatus = status.ExitStatus()
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+	return nil
+}
+
+func (a *Agent) ReadFile(path *string, data *[]byte) (err error) {
+	*data, err = ioutil.ReadFile(*path)
+	return
+}
+
+func (a *Agent) WriteFile(args *WriteFileArgs, _ *struct{}) error {
+	return ioutil.WriteFile(args.Path, args.Data, args.Mode)
+}
+
+func (a *Agent) Stat(path *string, result *FileInfo) error {
+	info, err := os.Stat(*path)
+	if err != nil {
+		return err
+	}
+	*result = FileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+	return nil
+}
+
+func unitStatus(unit string) (*UnitStatus, error) {
+	out, err := exec.Command("systemctl", "show", "--property=LoadState,ActiveState,SubState", unit).Output()
+	if err != nil {
+		return nil, fmt.Errorf("systemctl show %s: %v", unit, err)
+	}
+	status := &UnitStatus{}
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "LoadState":
+			status.LoadState = parts[1]
+		case "ActiveState":
+			status.ActiveState = parts[1]
+		case "SubState":
+			status.SubState = parts[1]
+		}
+	}
+	return status, nil
+}
+
+func (a *Agent) UnitStatus(unit *string, result *UnitStatus) error {
+	status, err := unitStatus(*unit)
+	if err != nil {
+		return err
+	}
+	*result = *status
+	return nil
+}
+
+// WaitForUnit waits for a unit to reach the state, failing early if it
+// fails instead.
+func (a *Agent) WaitForUnit(args *WaitForUnitArgs, result *UnitStatus) error {
+	state := args.State
+	if state == "" {
+		state = "active"
+	}
+	deadline := time.Now().Add(args.Timeout)
+	for {
+		status, err := unitStatus(args.Unit)
+		if err != nil {
+			return err
+		}
+		*result = *status
+		if status.ActiveState == state {
+			return nil
+		}
+		if status.ActiveState == "failed" {
+			return fmt.Errorf("%s failed", args.Unit)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s is %s after %v, not %s", args.Unit, status.ActiveState, args.Timeout, state)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// journalField returns a journal field from journalctl's JSON output,
+// where fields which aren't valid UTF-8 are arrays of bytes.
+func journalField(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []interface{}:
+		b := make([]byte, 0, len(v))
+		for _, c := range v {
+			if n, ok := c.(float64); ok {
+				b = append(b, byte(n))
+			}
+		}
+		return string(b)
+	default:
+		return ""
+	}
+}
+
+func journal(args ...string) ([]JournalEntry, error) {
+	cmd := exec.Command("journalctl", append([]string{"--output=json", "--no-pager"}, args...)...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, fmt.Errorf("parsing journal: %v", err)
+		}
+		entries = append(entries, JournalEntry{
+			Cursor:  journalField(fields["__CURSOR"]),
+			Unit:    journalField(fields["_SYSTEMD_UNIT"]),
+			Message: journalField(fields["MESSAGE"]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("journalctl: %v", err)
+	}
+	return entries, nil
+}
+
+// JournalCursor returns the cursor of the last journal entry, so that a
+// later Journal call returns the entries written since.
+func (a *Agent) JournalCursor(_ *struct{}, cursor *string) error {
+	entries, err := journal("--lines=1")
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		*cursor = entries[len(entries)-1].Cursor
+	}
+	return nil
+}
+
+func (a *Agent) Journal(args *JournalArgs, entries *[]JournalEntry) (err error) {
+	var jargs []string
+	if args.Cursor != "" {
+		jargs = append(jargs, "--after-cursor="+args.Cursor)
+	}
+	if args.Unit != "" {
+		jargs = append(jargs, "--unit="+args.Unit)
+	}
+	*entries, err = journal(jargs...)
+	return
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// newTestClient returns a client connected to an agent in this process.
+func newTestClient(t *testing.T) *Client {
+	requests, requestsW := io.Pipe()
+	responsesR, responses := io.Pipe()
+	go func() {
+		if err := Serve(requests, responses); err != nil {
+			t.Error(err)
+		}
+		responses.Close()
+	}()
+	return NewClient(pipeConn{responsesR, requestsW})
+}
+
+func TestExec(t *testing.T) {
+	c := newTestClient(t)
+	defer c.Close()
+
+	result, err := c.Exec("cat; echo oops >&2; exit 3", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Stdout) != "hello" {
+		t.Errorf("got stdout %q, expected \"hello\"", result.Stdout)
+	}
+	if string(result.Stderr) != "oops\n" {
+		t.Errorf("got stderr %q, expected \"oops\\n\"", result.Stderr)
+	}
+	if result.ExitStatus != 3 {
+		t.Errorf("got exit status %d, expected 3", result.ExitStatus)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	c := newTestClient(t)
+	defer c.Close()
+
+	start := time.Now()
+	// the background child would hold stdout open if only sh were killed
+	_, err := c.ExecTimeout("sleep 30 & sleep 30", nil, 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got error %v, expected a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("timed out command took %v to return", elapsed)
+	}
+}
+
+// blockingConn accepts requests but never replies.
+type blockingConn struct {
+	io.Reader
+}
+
+func (blockingConn) Write(b []byte) (int, error) { return len(b), nil }
+func (blockingConn) Close() error                { return nil }
+
+func TestCallTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	c := NewClient(blockingConn{r})
+	defer c.Close()
+
+	var result ExecResult
+	err := c.call("Exec", &ExecArgs{Command: "true"}, &result, 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "no reply") {
+		t.Errorf("got error %v, expected no reply", err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	c := newTestClient(t)
+	defer c.Close()
+
+	dir, err := ioutil.TempDir("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+
+	if err := c.WriteFile(path, []byte("contents"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "contents" {
+		t.Errorf("read %q, expected \"contents\"", data)
+	}
+
+	info, err := c.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "file" || info.Size != 8 || info.Mode != 0600 || info.IsDir {
+		t.Errorf("unexpected stat result %+v", info)
+	}
+	if info, err := c.Stat(dir); err != nil || !info.IsDir {
+		t.Errorf("stat of directory: %+v, %v", info, err)
+	}
+
+	if _, err := c.ReadFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("reading a missing file succeeded")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultExecTimeout bounds commands run with Exec.
+	DefaultExecTimeout = 10 * time.Minute
+
+	// callTimeout bounds calls which don't run for a requested time.
+	callTimeout = 2 * time.Minute
+)
+
+// Client calls an agent's methods.  It is safe for concurrent use.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// NewClient returns a client which sends requests to an agent over conn.
+// Closing the client closes conn.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{rpc: jsonrpc.NewClient(conn)}
+}
+
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// call calls a method, giving up if the agent hasn't replied within
+// timeout.
+func (c *Client) call(method string, args, reply interface{}, timeout time.Duration) error {
+	call := c.rpc.Go(ServiceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-time.After(timeout):
+		return fmt.Errorf("agent %s: no reply after %v", method, timeout)
+	}
+}
+
+// Exec runs a command with sh -c, killing it after DefaultExecTimeout.
+// A non-zero exit status is returned in the result rather than as an
+// error.
+func (c *Client) Exec(command string, stdin []byte) (*ExecResult, error) {
+	return c.ExecTimeout(command, stdin, DefaultExecTimeout)
+}
+
+// ExecTimeout is like Exec, but kills the command after timeout.
+func (c *Client) ExecTimeout(command string, stdin []byte, timeout time.Duration) (*ExecResult, error) {
+	var result ExecResult
+	args := &ExecArgs{Command: command, Stdin: stdin, Timeout: timeout}
+	if err := c.call("Exec", args, &result, timeout+callTimeout); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ReadFile(path string) ([]byte, error) {
+	var data []byte
+	if err := c.call("ReadFile", &path, &data, callTimeout); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *Client) WriteFile(path string, data []byte, mode os.FileMode) error {
+	return c.call("WriteFile", &WriteFileArgs{Path: path, Data: data, Mode: mode}, &struct{}{}, callTimeout)
+}
+
+func (c *Client) Stat(path string) (*FileInfo, error) {
+	var info FileInfo
+	if err := c.call("Stat", &path, &info, callTimeout); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (c *Client) UnitStatus(unit string) (*UnitStatus, error) {
+	var status UnitStatus
+	if err := c.call("UnitStatus", &unit, &status, callTimeout); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// WaitForUnit waits up to timeout for a unit's ActiveState to become
+// state, or "active" if state is empty.
+func (c *Client) WaitForUnit(unit, state string, timeout time.Duration) (*UnitStatus, error) {
+	var status UnitStatus
+	args := &WaitForUnitArgs{Unit: unit, State: state, Timeout: timeout}
+	if err := c.call("WaitForUnit", args, &status, timeout+callTimeout); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// JournalCursor returns the cursor of the machine's last journal entry.
+func (c *Client) JournalCursor() (string, error) {
+	var cursor string
+	if err := c.call("JournalCursor", &struct{}{}, &cursor, callTimeout); err != nil {
+		return "", err
+	}
+	return cursor, nil
+}
+
+// Journal returns the journal entries after cursor, or all entries if
+// cursor is empty, limited to unit if it is set.
+func (c *Client) Journal(cursor, unit string) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	if err := c.call("Journal", &JournalArgs{Cursor: cursor, Unit: unit}, &entries, callTimeout); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
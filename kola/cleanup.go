@@ -0,0 +1,306 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/coreos/mantle/platform"
+	awsapi "github.com/coreos/mantle/platform/api/aws"
+	azureapi "github.com/coreos/mantle/platform/api/azure"
+	doapi "github.com/coreos/mantle/platform/api/do"
+	esxapi "github.com/coreos/mantle/platform/api/esx"
+	gcloudapi "github.com/coreos/mantle/platform/api/gcloud"
+	libvirtapi "github.com/coreos/mantle/platform/api/libvirt"
+	openstackapi "github.com/coreos/mantle/platform/api/openstack"
+	packetapi "github.com/coreos/mantle/platform/api/packet"
+	"github.com/coreos/mantle/platform/machine/aws"
+	"github.com/coreos/mantle/platform/machine/azure"
+	"github.com/coreos/mantle/platform/machine/do"
+	"github.com/coreos/mantle/platform/machine/esx"
+	"github.com/coreos/mantle/platform/machine/gcloud"
+	"github.com/coreos/mantle/platform/machine/libvirt"
+	"github.com/coreos/mantle/platform/machine/openstack"
+	"github.com/coreos/mantle/platform/machine/packet"
+)
+
+// ResourceJournalPath returns the path of the resource journal kept by
+// flights writing to outputDir.
+func ResourceJournalPath(outputDir string) string {
+	return filepath.Join(outputDir, platform.ResourceJournalName)
+}
+
+// OpenResourceJournal opens the resource journal kept by flights writing
+// to outputDir.
+func OpenResourceJournal(outputDir string) (*platform.ResourceJournal, error) {
+	journal, err := platform.OpenResourceJournal(ResourceJournalPath(outputDir))
+	if err != nil {
+		return nil, fmt.Errorf("opening resource journal: %v", err)
+	}
+	return journal, nil
+}
+
+// CleanupResources deletes the resources listed in the resource journal
+// in outputDir, using the platform options configured in this package
+// for credentials.  Deleted resources are removed from the journal; the
+// rest are left for a later attempt.
+func CleanupResources(outputDir string) error {
+	path := ResourceJournalPath(outputDir)
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	journal, err := OpenResourceJournal(outputDir)
+	if err != nil {
+		return err
+	}
+	resources, err := journal.Resources()
+	if err != nil {
+		return err
+	}
+	// security groups can't be deleted while instances use them
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].Type != platform.GCSecurityGroup && resources[j].Type == platform.GCSecurityGroup
+	})
+
+	c := cleaner{apis: make(map[string]interface{})}
+	var failed int
+	for _, r := range resources {
+		if err := c.delete(r); err != nil {
+			plog.Errorf("Couldn't delete %s %s %s: %v", r.Platform, r.Type, r.ID, err)
+			failed++
+			continue
+		}
+		plog.Noticef("Deleted %s %s %s", r.Platform, r.Type, r.ID)
+		if err := journal.Remove(r.Platform, r.Type, r.ID); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d resources could not be deleted", failed)
+	}
+	return nil
+}
+
+// cleaner creates platform API clients on demand and deletes resources
+// with them.
+type cleaner struct {
+	// API clients keyed by platform and any context which affects the
+	// client, such as the region
+	apis map[string]interface{}
+}
+
+func (c *cleaner) api(key string, create func() (interface{}, error)) (interface{}, error) {
+	if api, ok := c.apis[key]; ok {
+		return api, nil
+	}
+	api, err := create()
+	if err != nil {
+		return nil, err
+	}
+	c.apis[key] = api
+	return api, nil
+}
+
+func (c *cleaner) delete(r platform.Resource) error {
+	switch r.Platform {
+	case aws.Platform:
+		return c.deleteAWS(r)
+	case azure.Platform:
+		return c.deleteAzure(r)
+	case do.Platform:
+		return c.deleteDO(r)
+	case esx.Platform:
+		return c.deleteESX(r)
+	case gcloud.Platform:
+		return c.deleteGCE(r)
+	case libvirt.Platform:
+		return c.deleteLibvirt(r)
+	case openstack.Platform:
+		return c.deleteOpenStack(r)
+	case packet.Platform:
+		return c.deletePacket(r)
+	}
+	return fmt.Errorf("unsupported platform")
+}
+
+func unsupportedType(r platform.Resource) error {
+	return fmt.Errorf("unsupported resource type %q", r.Type)
+}
+
+func (c *cleaner) deleteAWS(r platform.Resource) error {
+	opts := AWSOptions
+	if region := r.Context["region"]; region != "" {
+		opts.Region = region
+	}
+	api, err := c.api("aws/"+opts.Region, func() (interface{}, error) {
+		return awsapi.New(&opts)
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*awsapi.API)
+	switch r.Type {
+	case platform.GCInstance:
+		return a.TerminateInstances([]string{r.ID})
+	case platform.GCKey:
+		return a.DeleteKey(r.ID)
+	case platform.GCSecurityGroup:
+		return a.DeleteSecurityGroup(r.ID)
+	}
+	return unsupportedType(r)
+}
+
+func (c *cleaner) deleteAzure(r platform.Resource) error {
+	api, err := c.api("azure", func() (interface{}, error) {
+		a, err := azureapi.New(&AzureOptions)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.SetupClients(); err != nil {
+			return nil, fmt.Errorf("setting up clients: %v", err)
+		}
+		return a, nil
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*azureapi.API)
+	switch r.Type {
+	case platform.GCResourceGroup:
+		return a.TerminateResourceGroup(r.ID)
+	}
+	return unsupportedType(r)
+}
+
+func (c *cleaner) deleteDO(r platform.Resource) error {
+	api, err := c.api("do", func() (interface{}, error) {
+		return doapi.New(&DOOptions)
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*doapi.API)
+	id, err := strconv.Atoi(r.ID)
+	if err != nil {
+		return fmt.Errorf("parsing ID: %v", err)
+	}
+	switch r.Type {
+	case platform.GCInstance:
+		return a.DeleteDroplet(context.TODO(), id)
+	case platform.GCKey:
+		return a.DeleteKey(context.TODO(), id)
+	}
+	return unsupportedType(r)
+}
+
+func (c *cleaner) deleteESX(r platform.Resource) error {
+	api, err := c.api("esx", func() (interface{}, error) {
+		return esxapi.New(&ESXOptions)
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*esxapi.API)
+	switch r.Type {
+	case platform.GCInstance:
+		if err := a.TerminateDevice(r.ID); err != nil {
+			return err
+		}
+		return a.CleanupDevice(r.ID)
+	}
+	return unsupportedType(r)
+}
+
+func (c *cleaner) deleteGCE(r platform.Resource) error {
+	opts := GCEOptions
+	if project := r.Context["project"]; project != "" {
+		opts.Project = project
+	}
+	if zone := r.Context["zone"]; zone != "" {
+		opts.Zone = zone
+	}
+	api, err := c.api("gce/"+opts.Project+"/"+opts.Zone, func() (interface{}, error) {
+		return gcloudapi.New(&opts)
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*gcloudapi.API)
+	switch r.Type {
+	case platform.GCInstance:
+		return a.TerminateInstance(r.ID)
+	}
+	return unsupportedType(r)
+}
+
+func (c *cleaner) deleteLibvirt(r platform.Resource) error {
+	api, err := c.api("libvirt", func() (interface{}, error) {
+		// the disk image is only needed to create machines
+		opts := LibvirtOptions
+		opts.DiskImage = ""
+		return libvirtapi.New(&opts)
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*libvirtapi.API)
+	switch r.Type {
+	case platform.GCInstance:
+		return a.DestroyDomain(r.ID)
+	case platform.GCNetwork:
+		return a.DeleteNetwork(r.ID)
+	}
+	return unsupportedType(r)
+}
+
+func (c *cleaner) deleteOpenStack(r platform.Resource) error {
+	api, err := c.api("openstack", func() (interface{}, error) {
+		return openstackapi.New(&OpenStackOptions)
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*openstackapi.API)
+	switch r.Type {
+	case platform.GCInstance:
+		return a.DeleteServer(r.ID)
+	case platform.GCKey:
+		return a.DeleteKey(r.ID)
+	}
+	return unsupportedType(r)
+}
+
+func (c *cleaner) deletePacket(r platform.Resource) error {
+	api, err := c.api("packet", func() (interface{}, error) {
+		return packetapi.New(&PacketOptions)
+	})
+	if err != nil {
+		return err
+	}
+	a := api.(*packetapi.API)
+	switch r.Type {
+	case platform.GCInstance:
+		return a.DeleteDevice(r.ID)
+	case platform.GCKey:
+		return a.DeleteKey(r.ID)
+	}
+	return unsupportedType(r)
+}
@@ -0,0 +1,119 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/coreos/mantle/kola/agent"
+	"github.com/coreos/mantle/platform"
+)
+
+// Agent is a connection to a kolet agent running as root on a machine.
+// Close it when the test is done with it.
+type Agent struct {
+	*agent.Client
+	client  *ssh.Client
+	session *ssh.Session
+	stderr  bytes.Buffer
+}
+
+// sessionConn carries the agent protocol over an SSH session's stdin
+// and stdout.
+type sessionConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// Agent starts a kolet agent on the machine, copying kolet to it first
+// if needed, and returns a client for it.  The test fails if the agent
+// can't be started.
+func (t *TestCluster) Agent(m platform.Machine) *Agent {
+	if _, _, err := m.SSH("test -x kolet"); err != nil {
+		if t.KoletPath == "" {
+			t.Fatalf("Unable to locate kolet binary for the agent")
+		}
+		in, err := os.Open(t.KoletPath)
+		if err != nil {
+			t.Fatalf("opening kolet: %v", err)
+		}
+		defer in.Close()
+		if err := platform.InstallFile(in, m, "kolet"); err != nil {
+			t.Fatalf("dropping kolet binary: %v", err)
+		}
+	}
+
+	client, err := m.SSHClient()
+	if err != nil {
+		t.Fatalf("kolet agent SSH client: %v", err)
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		t.Fatalf("kolet agent SSH session: %v", err)
+	}
+	a := &Agent{client: client, session: session}
+	session.Stderr = &a.stderr
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		a.close()
+		t.Fatalf("kolet agent SSH session: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		a.close()
+		t.Fatalf("kolet agent SSH session: %v", err)
+	}
+	if err := session.Start("sudo ./kolet agent"); err != nil {
+		a.close()
+		t.Fatalf("starting kolet agent: %v", err)
+	}
+	a.Client = agent.NewClient(sessionConn{stdout, stdin})
+	return a
+}
+
+func (a *Agent) close() {
+	a.session.Close()
+	a.client.Close()
+}
+
+// Close stops the agent and closes its connection.
+func (a *Agent) Close() error {
+	defer a.close()
+	// closing stdin makes the agent exit
+	a.Client.Close()
+	if err := a.session.Wait(); err != nil {
+		return fmt.Errorf("kolet agent: %v: %s", err, bytes.TrimSpace(a.stderr.Bytes()))
+	}
+	return nil
+}
+
+// MustExec runs a command through the agent, failing the test if it
+// can't be run or exits unsuccessfully, and returns its stdout.
+func (t *TestCluster) MustExec(a *Agent, command string) []byte {
+	result, err := a.Exec(command, nil)
+	if err != nil {
+		t.Fatalf("%q failed: %v", command, err)
+	}
+	if result.ExitStatus != 0 {
+		t.Fatalf("%q failed: status %d, stderr %s", command, result.ExitStatus, bytes.TrimSpace(result.Stderr))
+	}
+	return result.Stdout
+}
@@ -16,12 +16,16 @@ package cluster
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/coreos/mantle/harness"
+	"github.com/coreos/mantle/kola/native"
 	"github.com/coreos/mantle/platform"
 )
 
@@ -32,6 +36,10 @@ type TestCluster struct {
 	platform.Cluster
 	NativeFuncs []string
 
+	// KoletPath is the local kolet binary for the machines'
+	// architecture, or "" if none was found.
+	KoletPath string
+
 	// If set to true and a sub-test fails all future sub-tests will be skipped
 	FailFast   bool
 	hasFailure bool
@@ -43,11 +51,11 @@ func (t *TestCluster) Run(name string, f func(c TestCluster)) bool {
 		return t.H.Run(name, func(h *harness.H) {
 			func(c TestCluster) {
 				c.Skip("A previous test has already failed")
-			}(TestCluster{H: h, Cluster: t.Cluster})
+			}(TestCluster{H: h, Cluster: t.Cluster, KoletPath: t.KoletPath})
 		})
 	}
 	t.hasFailure = !t.H.Run(name, func(h *harness.H) {
-		f(TestCluster{H: h, Cluster: t.Cluster})
+		f(TestCluster{H: h, Cluster: t.Cluster, KoletPath: t.KoletPath})
 	})
 	return !t.hasFailure
 
@@ -80,8 +88,60 @@ func (t *TestCluster) RunNative(funcName string, m platform.Machine) bool {
 	})
 }
 
+// RunNativeJSON runs a registered NativeJSONFunc on a remote machine,
+// passing it args encoded as JSON and decoding its result into result
+// unless result is nil.  The function's log messages are logged in the
+// subtest as they arrive.
+func (t *TestCluster) RunNativeJSON(funcName string, m platform.Machine, args, result interface{}) bool {
+	command := fmt.Sprintf("./kolet run %q %q", t.H.Name(), funcName)
+	return t.Run(funcName, func(c TestCluster) {
+		data, err := json.Marshal(args)
+		if err != nil {
+			c.Fatalf("encoding kolet arguments: %v", err)
+		}
+
+		client, err := m.SSHClient()
+		if err != nil {
+			c.Fatalf("kolet SSH client: %v", err)
+		}
+		defer client.Close()
+
+		session, err := client.NewSession()
+		if err != nil {
+			c.Fatalf("kolet SSH session: %v", err)
+		}
+		defer session.Close()
+
+		var stderr bytes.Buffer
+		session.Stdin = bytes.NewReader(data)
+		session.Stderr = &stderr
+		stdout, err := session.StdoutPipe()
+		if err != nil {
+			c.Fatalf("kolet SSH session: %v", err)
+		}
+		if err := session.Start(command); err != nil {
+			c.Fatalf("kolet: %v", err)
+		}
+
+		err = native.Decode(stdout, func(line string) {
+			c.Logf("kolet: %s", line)
+		}, result)
+		if err != nil {
+			c.Errorf("kolet: %v", err)
+		}
+		// drain any output after the result so kolet can exit
+		io.Copy(ioutil.Discard, stdout)
+		if err := session.Wait(); err != nil && !c.Failed() {
+			c.Errorf("kolet: %v", err)
+		}
+		if b := bytes.TrimSpace(stderr.Bytes()); len(b) > 0 {
+			c.Logf("kolet:\n%s", b)
+		}
+	})
+}
+
 // ListNativeFunctions returns a slice of function names that can be executed
-// directly on machines in the cluster.
+// directly on machines in the cluster with RunNative.
 func (t *TestCluster) ListNativeFunctions() []string {
 	return t.NativeFuncs
 }
@@ -0,0 +1,63 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/coreos/mantle/platform"
+)
+
+// Console drives a machine's serial console.  Each WaitFor searches
+// the output after the previous match, so a test can step through a
+// dialogue with GRUB, the initramfs or a shell.
+type Console struct {
+	console *platform.Console
+	offset  int
+}
+
+// Console returns the serial console of m, failing the test if m's
+// console can't be used while it runs.
+func (t *TestCluster) Console(m platform.Machine) *Console {
+	qm, ok := m.(platform.QEMUMachine)
+	if !ok {
+		t.Fatalf("machine %v has no interactive console", m.ID())
+	}
+	return &Console{console: qm.Console()}
+}
+
+// WaitFor waits for the console to print a match for the regular
+// expression pattern, and returns the match and its subexpressions.
+func (c *Console) WaitFor(pattern string, timeout time.Duration) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	match, offset, err := c.console.Expect(re, c.offset, timeout)
+	c.offset = offset
+	return match, err
+}
+
+// Send types line into the console, followed by Enter.
+func (c *Console) Send(line string) error {
+	_, err := c.console.Write([]byte(line + "\r"))
+	return err
+}
+
+// Output returns everything the machine has printed.
+func (c *Console) Output() string {
+	return string(c.console.Output())
+}
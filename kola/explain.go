@@ -0,0 +1,192 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+)
+
+// TestDecision records whether a test matching the run's patterns is
+// run, and if not, the rule which excluded it.
+type TestDecision struct {
+	Name     string `json:"name"`
+	Included bool   `json:"included"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ExplainTests decides which registered tests matching patterns would
+// run on pltfrm with the current options and an OS at version, which
+// may be zero to ignore the tests' version ranges.
+func ExplainTests(patterns []string, pltfrm string, version semver.Version) ([]TestDecision, error) {
+	return explainTests(register.Tests, patterns, pltfrm, version)
+}
+
+func explainTests(tests map[string]*register.Test, patterns []string, pltfrm string, version semver.Version) ([]TestDecision, error) {
+	tags, err := register.ParseTagExpr(Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []TestDecision
+	for name, t := range tests {
+		match := false
+		for _, pattern := range patterns {
+			match, err = filepath.Match(pattern, name)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+
+		reason, err := excludeReason(t, patterns, tags, pltfrm, version)
+		if err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, TestDecision{
+			Name:     name,
+			Included: reason == "",
+			Reason:   reason,
+		})
+	}
+
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].Name < decisions[j].Name
+	})
+	return decisions, nil
+}
+
+func existsIn(item string, entries []string) bool {
+	for _, i := range entries {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowed checks item against a test's whitelist and blacklist.
+func isAllowed(item string, include, exclude []string) (bool, bool) {
+	allowed, excluded := true, false
+	for _, i := range include {
+		if i == item {
+			allowed = true
+			break
+		} else {
+			allowed = false
+		}
+	}
+	for _, i := range exclude {
+		if i == item {
+			allowed = false
+			excluded = true
+		}
+	}
+	return allowed, excluded
+}
+
+// excludeReason returns why the test t, which matched patterns, won't
+// run, or "" if it will.
+func excludeReason(t *register.Test, patterns []string, tags register.TagExpr, pltfrm string, version semver.Version) (string, error) {
+	// Drop anything which is blacklisted directly or by pattern
+	for _, bl := range BlacklistedTests {
+		match, err := filepath.Match(bl, t.Name)
+		if err != nil {
+			return "", err
+		}
+		if match {
+			return fmt.Sprintf("blacklisted by --blacklist-test %q", bl), nil
+		}
+	}
+
+	if !t.MatchesTags(tags) {
+		return fmt.Sprintf("tags %v don't match --tags %q", t.Tags, Tags), nil
+	}
+
+	// Benchmarks are slow and only useful when compared, so they run
+	// only when asked for by name or tag
+	if existsIn(register.TagBenchmark, t.Tags) && !isExactMatch(t.Name, patterns) && !register.SelectsTag(tags, register.TagBenchmark) {
+		return fmt.Sprintf("benchmarks only run when named or selected by --tags %s", register.TagBenchmark), nil
+	}
+
+	// Check the test's min and end versions when running more than one test
+	if !isExactMatch(t.Name, patterns) && versionOutsideRange(version, t.MinVersion, t.EndVersion) {
+		end := "any"
+		if (t.EndVersion != semver.Version{}) {
+			end = t.EndVersion.String()
+		}
+		return fmt.Sprintf("version %v is outside the test's range [%v, %v)", version, t.MinVersion, end), nil
+	}
+
+	caps := platform.PlatformCapabilities(pltfrm)
+	for _, req := range t.Requirements() {
+		if !caps.Has(req) {
+			return fmt.Sprintf("requires capability %s, which platform %s lacks", req, pltfrm), nil
+		}
+	}
+	if arch := architecture(pltfrm); !caps.SupportsArchitecture(arch) {
+		return fmt.Sprintf("platform %s doesn't support architecture %s", pltfrm, arch), nil
+	}
+
+	if t.ConfigDelivery != platform.ConfigDeliveryDefault && !existsIn(pltfrm, []string{"qemu", "qemu-unpriv"}) {
+		return fmt.Sprintf("config delivery %q not supported by platform %s", t.ConfigDelivery, pltfrm), nil
+	}
+
+	checkPlatforms := []string{pltfrm}
+
+	// qemu-unpriv has the same restrictions as QEMU but might also want additional restrictions due to the lack of a Local cluster
+	if pltfrm == "qemu-unpriv" {
+		checkPlatforms = append(checkPlatforms, "qemu")
+	}
+
+	allowed := false
+	var reason string
+	for _, platform := range checkPlatforms {
+		allowedPlatform, excluded := isAllowed(platform, t.Platforms, t.ExcludePlatforms)
+		if excluded {
+			return fmt.Sprintf("platform %s is in ExcludePlatforms %v", platform, t.ExcludePlatforms), nil
+		}
+		arch := architecture(platform)
+		allowedArchitecture, _ := isAllowed(arch, t.Architectures, []string{})
+		allowed = allowed || (allowedPlatform && allowedArchitecture)
+		if reason == "" && !allowedPlatform {
+			reason = fmt.Sprintf("platform %s isn't in Platforms %v", platform, t.Platforms)
+		} else if reason == "" && !allowedArchitecture {
+			reason = fmt.Sprintf("architecture %s isn't in Architectures %v", arch, t.Architectures)
+		}
+	}
+	if !allowed {
+		return reason, nil
+	}
+
+	if allowed, excluded := isAllowed(Options.Distribution, t.Distros, t.ExcludeDistros); excluded {
+		return fmt.Sprintf("distro %s is in ExcludeDistros %v", Options.Distribution, t.ExcludeDistros), nil
+	} else if !allowed {
+		return fmt.Sprintf("distro %s isn't in Distros %v", Options.Distribution, t.Distros), nil
+	}
+
+	return "", nil
+}
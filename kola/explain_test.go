@@ -0,0 +1,353 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+)
+
+// saveOptions restores the options excludeReason depends on when the
+// returned function is called.
+func saveOptions() func() {
+	distro, qemuBoard, packetBoard := Options.Distribution, QEMUOptions.Board, PacketOptions.Board
+	blacklist, tags := BlacklistedTests, Tags
+	return func() {
+		Options.Distribution = distro
+		QEMUOptions.Board = qemuBoard
+		PacketOptions.Board = packetBoard
+		BlacklistedTests = blacklist
+		Tags = tags
+	}
+}
+
+func TestExcludeReason(t *testing.T) {
+	defer saveOptions()()
+	Options.Distribution = "cl"
+	v1000 := semver.Version{Major: 1000}
+	v2000 := semver.Version{Major: 2000}
+
+	for _, tt := range []struct {
+		name        string
+		test        register.Test
+		pltfrm      string
+		qemuBoard   string
+		packetBoard string
+		patterns    []string // defaults to "*"
+		tags        string
+		blacklist   []string
+		version     semver.Version
+		reason      string // substring of the reason, or "" if it runs
+	}{
+		{
+			name:      "blacklisted by name",
+			test:      register.Test{Name: "cl.basic"},
+			pltfrm:    "qemu",
+			blacklist: []string{"cl.basic"},
+			reason:    `blacklisted by --blacklist-test "cl.basic"`,
+		},
+		{
+			name:      "blacklisted by pattern",
+			test:      register.Test{Name: "cl.basic"},
+			pltfrm:    "qemu",
+			blacklist: []string{"other", "cl.*"},
+			reason:    `blacklisted by --blacklist-test "cl.*"`,
+		},
+		{
+			name:      "blacklist doesn't match",
+			test:      register.Test{Name: "cl.basic"},
+			pltfrm:    "qemu",
+			blacklist: []string{"fcos.*"},
+		},
+		{
+			name:   "tags don't match",
+			test:   register.Test{Name: "t", Tags: []string{register.TagSlow}},
+			pltfrm: "qemu",
+			tags:   "!slow",
+			reason: "tags [slow] don't match --tags",
+		},
+		{
+			name:   "tags match",
+			test:   register.Test{Name: "t", Tags: []string{register.TagSmoke}},
+			pltfrm: "qemu",
+			tags:   "smoke && !slow",
+		},
+		{
+			name:    "version below range",
+			test:    register.Test{Name: "t", MinVersion: v2000},
+			pltfrm:  "qemu",
+			version: v1000,
+			reason:  "version 1000.0.0 is outside the test's range [2000.0.0, any)",
+		},
+		{
+			name:    "version above range",
+			test:    register.Test{Name: "t", EndVersion: v1000},
+			pltfrm:  "qemu",
+			version: v2000,
+			reason:  "outside the test's range [0.0.0, 1000.0.0)",
+		},
+		{
+			name:    "version in range",
+			test:    register.Test{Name: "t", MinVersion: v1000, EndVersion: v2000},
+			pltfrm:  "qemu",
+			version: semver.Version{Major: 1500},
+		},
+		{
+			name:   "unknown version ignores range",
+			test:   register.Test{Name: "t", MinVersion: v2000},
+			pltfrm: "qemu",
+		},
+		{
+			name:     "exact match ignores range",
+			test:     register.Test{Name: "t", MinVersion: v2000},
+			pltfrm:   "qemu",
+			patterns: []string{"t"},
+			version:  v1000,
+		},
+		{
+			name:   "config delivery unsupported",
+			test:   register.Test{Name: "t", ConfigDelivery: platform.ConfigDeliveryHTTP},
+			pltfrm: "aws",
+			reason: `config delivery "http" not supported by platform aws`,
+		},
+		{
+			name:   "config delivery supported",
+			test:   register.Test{Name: "t", ConfigDelivery: platform.ConfigDeliveryHTTP},
+			pltfrm: "qemu-unpriv",
+		},
+		{
+			name:   "platform excluded",
+			test:   register.Test{Name: "t", ExcludePlatforms: []string{"aws"}},
+			pltfrm: "aws",
+			reason: "platform aws is in ExcludePlatforms [aws]",
+		},
+		{
+			name:   "platform not included",
+			test:   register.Test{Name: "t", Platforms: []string{"gce"}},
+			pltfrm: "aws",
+			reason: "platform aws isn't in Platforms [gce]",
+		},
+		{
+			name:   "platform included",
+			test:   register.Test{Name: "t", Platforms: []string{"aws"}},
+			pltfrm: "aws",
+		},
+		{
+			name:   "qemu-unpriv falls back to qemu's Platforms",
+			test:   register.Test{Name: "t", Platforms: []string{"qemu"}},
+			pltfrm: "qemu-unpriv",
+		},
+		{
+			name:   "qemu-unpriv falls back to qemu's ExcludePlatforms",
+			test:   register.Test{Name: "t", ExcludePlatforms: []string{"qemu"}},
+			pltfrm: "qemu-unpriv",
+			reason: "platform qemu is in ExcludePlatforms [qemu]",
+		},
+		{
+			name:   "qemu-unpriv excluded itself",
+			test:   register.Test{Name: "t", ExcludePlatforms: []string{"qemu-unpriv"}},
+			pltfrm: "qemu-unpriv",
+			reason: "platform qemu-unpriv is in ExcludePlatforms",
+		},
+		{
+			name:   "qemu doesn't fall back to qemu-unpriv",
+			test:   register.Test{Name: "t", Platforms: []string{"qemu-unpriv"}},
+			pltfrm: "qemu",
+			reason: "platform qemu isn't in Platforms [qemu-unpriv]",
+		},
+		{
+			name:   "neither qemu-unpriv nor qemu included",
+			test:   register.Test{Name: "t", Platforms: []string{"aws"}},
+			pltfrm: "qemu-unpriv",
+			reason: "platform qemu-unpriv isn't in Platforms [aws]",
+		},
+		{
+			name:      "architecture not included",
+			test:      register.Test{Name: "t", Architectures: []string{"amd64"}},
+			pltfrm:    "qemu",
+			qemuBoard: "arm64-usr",
+			reason:    "architecture arm64 isn't in Architectures [amd64]",
+		},
+		{
+			name:      "architecture included",
+			test:      register.Test{Name: "t", Architectures: []string{"arm64"}},
+			pltfrm:    "qemu-unpriv",
+			qemuBoard: "arm64-usr",
+		},
+		{
+			name:   "distro excluded",
+			test:   register.Test{Name: "t", ExcludeDistros: []string{"cl"}},
+			pltfrm: "qemu",
+			reason: "distro cl is in ExcludeDistros [cl]",
+		},
+		{
+			name:   "distro not included",
+			test:   register.Test{Name: "t", Distros: []string{"rhcos"}},
+			pltfrm: "qemu",
+			reason: "distro cl isn't in Distros [rhcos]",
+		},
+		{
+			name:   "distro included",
+			test:   register.Test{Name: "t", Distros: []string{"rhcos", "cl"}},
+			pltfrm: "qemu",
+		},
+		{
+			name:   "no restrictions",
+			test:   register.Test{Name: "t"},
+			pltfrm: "qemu",
+		},
+		{
+			name:   "capability present",
+			test:   register.Test{Name: "t", Requires: []platform.Capability{platform.CapMultipleDisks}},
+			pltfrm: "qemu-unpriv",
+		},
+		{
+			name:   "capability missing",
+			test:   register.Test{Name: "t", Requires: []platform.Capability{platform.CapMultipleDisks}},
+			pltfrm: "aws",
+			reason: "requires capability multiple-disks",
+		},
+		{
+			name:   "flag implies capability",
+			test:   register.Test{Name: "t", Flags: []register.Flag{register.RequiresInternetAccess}},
+			pltfrm: "qemu",
+			reason: "requires capability internet",
+		},
+		{
+			name:   "network-online missing",
+			test:   register.Test{Name: "t", Requires: []platform.Capability{platform.CapNetworkOnline}},
+			pltfrm: "qemu-unpriv",
+			reason: "requires capability network-online",
+		},
+		{
+			name:   "metadata service missing",
+			test:   register.Test{Name: "t", Requires: []platform.Capability{platform.CapMetadataService}},
+			pltfrm: "esx",
+			reason: "requires capability metadata-service",
+		},
+		{
+			name:   "console reboot missing",
+			test:   register.Test{Name: "t", Requires: []platform.Capability{platform.CapConsoleReboot}},
+			pltfrm: "gce",
+			reason: "requires capability console-reboot",
+		},
+		{
+			name:        "architecture unsupported by platform",
+			test:        register.Test{Name: "t"},
+			pltfrm:      "packet",
+			packetBoard: "s390x-usr",
+			reason:      "doesn't support architecture s390x",
+		},
+		{
+			name:   "unknown platform runs everything",
+			test:   register.Test{Name: "t"},
+			pltfrm: "newcloud",
+		},
+		{
+			name:   "unknown platform lacks capabilities",
+			test:   register.Test{Name: "t", Requires: []platform.Capability{platform.CapInternet}},
+			pltfrm: "newcloud",
+			reason: "requires capability internet",
+		},
+		{
+			name:   "benchmark not selected",
+			test:   register.Test{Name: "t", Tags: []string{register.TagBenchmark}},
+			pltfrm: "qemu",
+			reason: "benchmarks only run when named or selected",
+		},
+		{
+			name:   "benchmark selected by tag",
+			test:   register.Test{Name: "t", Tags: []string{register.TagBenchmark}},
+			pltfrm: "qemu",
+			tags:   "benchmark || smoke",
+		},
+		{
+			name:     "benchmark named",
+			test:     register.Test{Name: "t", Tags: []string{register.TagBenchmark}},
+			pltfrm:   "qemu",
+			patterns: []string{"t"},
+		},
+	} {
+		QEMUOptions.Board = tt.qemuBoard
+		PacketOptions.Board = tt.packetBoard
+		BlacklistedTests = tt.blacklist
+		Tags = tt.tags
+		patterns := tt.patterns
+		if patterns == nil {
+			patterns = []string{"*"}
+		}
+		tags, err := register.ParseTagExpr(tt.tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reason, err := excludeReason(&tt.test, patterns, tags, tt.pltfrm, tt.version)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if tt.reason == "" && reason != "" {
+			t.Errorf("%s: excluded because %s", tt.name, reason)
+		} else if tt.reason != "" && !strings.Contains(reason, tt.reason) {
+			t.Errorf("%s: got reason %q, expected %q", tt.name, reason, tt.reason)
+		}
+	}
+}
+
+func TestFilterTests(t *testing.T) {
+	defer saveOptions()()
+	Options.Distribution = "cl"
+	QEMUOptions.Board = ""
+	BlacklistedTests = []string{"cl.blacklisted"}
+	Tags = ""
+
+	tests := map[string]*register.Test{
+		"cl.basic":       {Name: "cl.basic"},
+		"cl.blacklisted": {Name: "cl.blacklisted"},
+		"cl.aws":         {Name: "cl.aws", Platforms: []string{"aws"}},
+		"cl.qemu":        {Name: "cl.qemu", Platforms: []string{"qemu"}},
+		"fcos.basic":     {Name: "fcos.basic"},
+	}
+	decisions, err := explainTests(tests, []string{"cl.*"}, "qemu-unpriv", semver.Version{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TestDecision{
+		{Name: "cl.aws", Reason: "platform qemu-unpriv isn't in Platforms [aws]"},
+		{Name: "cl.basic", Included: true},
+		{Name: "cl.blacklisted", Reason: `blacklisted by --blacklist-test "cl.blacklisted"`},
+		{Name: "cl.qemu", Included: true},
+	}
+	if !reflect.DeepEqual(decisions, expected) {
+		t.Errorf("got decisions %+v, expected %+v", decisions, expected)
+	}
+
+	filtered, err := filterTests(tests, []string{"cl.*"}, "qemu-unpriv", semver.Version{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 2 || filtered["cl.basic"] != tests["cl.basic"] || filtered["cl.qemu"] != tests["cl.qemu"] {
+		t.Errorf("got tests %v, expected cl.basic and cl.qemu", filtered)
+	}
+
+	if _, err := explainTests(tests, []string{"["}, "qemu", semver.Version{}); err == nil {
+		t.Error("bad pattern accepted")
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/coreos/mantle/harness"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+)
+
+// testRun tracks the state shared between the tests of a run: their
+// results, which gate the tests depending on them, their fixtures, and
+// the OS version their machines run.
+type testRun struct {
+	pltfrm    string
+	flight    platform.Flight
+	outputDir string
+	results   map[string]*testResult
+	fixtures  map[string]*fixtureState
+
+	versionLock     sync.Mutex
+	versionRecorded bool
+}
+
+type testResult struct {
+	done   chan struct{}
+	passed bool
+}
+
+type fixtureState struct {
+	fixture *register.Fixture
+	dir     string
+
+	// the configuration of the fixture's cluster, which all its tests
+	// must agree on, and the first test it was taken from
+	rconf     *platform.RuntimeConfig
+	rconfTest string
+
+	lock    sync.Mutex
+	users   int // tests which haven't finished with the fixture
+	started bool
+	ready   chan struct{}
+	cluster platform.Cluster
+	err     error
+}
+
+func newTestRun(tests map[string]*register.Test, pltfrm string, flight platform.Flight, outputDir string) (*testRun, error) {
+	run := &testRun{
+		pltfrm:    pltfrm,
+		flight:    flight,
+		outputDir: outputDir,
+		results:   make(map[string]*testResult),
+		fixtures:  make(map[string]*fixtureState),
+	}
+	// visit the tests in order so that conflicts are reported the same
+	// way every time
+	var names []string
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := tests[name]
+		run.results[name] = &testResult{done: make(chan struct{})}
+		if t.Fixture == "" {
+			continue
+		}
+		f, ok := register.Fixtures[t.Fixture]
+		if !ok {
+			return nil, fmt.Errorf("test %s uses unknown fixture %q", name, t.Fixture)
+		}
+		state, ok := run.fixtures[f.Name]
+		if !ok {
+			state = &fixtureState{
+				fixture: f,
+				dir:     filepath.Join(outputDir, "fixtures", f.Name),
+				ready:   make(chan struct{}),
+			}
+			run.fixtures[f.Name] = state
+		}
+		rconf := testRuntimeConfig(t, state.dir)
+		if state.rconf == nil {
+			state.rconf, state.rconfTest = rconf, name
+		} else if *rconf != *state.rconf {
+			return nil, fmt.Errorf("tests %s and %s share fixture %s but need differently configured clusters", state.rconfTest, name, f.Name)
+		}
+		state.users++
+	}
+
+	// a cycle of dependencies would leave its tests waiting forever
+	visiting := make(map[string]bool)
+	visited := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("test %s depends on itself", name)
+		}
+		visiting[name] = true
+		for _, dep := range tests[name].DependsOn {
+			if _, ok := tests[dep]; !ok {
+				plog.Debugf("Ignoring dependency of %s on %s, which isn't running", name, dep)
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		return nil
+	}
+	for name := range tests {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return run, nil
+}
+
+// finish records the result of the test t once it ends.
+func (run *testRun) finish(h *harness.H, t *register.Test) {
+	result := run.results[t.Name]
+	result.passed = !h.Failed() && !h.Skipped()
+	close(result.done)
+}
+
+// waitForDependencies waits for the tests t depends on, and skips t if
+// any of them didn't pass.
+func (run *testRun) waitForDependencies(h *harness.H, t *register.Test) {
+	for _, dep := range t.DependsOn {
+		result, ok := run.results[dep]
+		if !ok {
+			continue
+		}
+		h.Block(func() { <-result.done })
+		if !result.passed {
+			h.Skipf("Dependency %s didn't pass", dep)
+		}
+	}
+}
+
+// acquire returns the fixture's cluster, setting it up if h's test is
+// the first to use it.  If setup fails, that test fails and the
+// fixture's other tests are skipped.
+func (s *fixtureState) acquire(h *harness.H, run *testRun) platform.Cluster {
+	s.lock.Lock()
+	if s.started {
+		s.lock.Unlock()
+		h.Block(func() { <-s.ready })
+		if s.err != nil {
+			h.Skipf("Fixture %s failed: %v", s.fixture.Name, s.err)
+		}
+		return s.cluster
+	}
+	s.started = true
+	s.lock.Unlock()
+
+	plog.Infof("Setting up fixture %s", s.fixture.Name)
+	s.cluster, s.err = s.setup(run)
+	close(s.ready)
+	if s.err != nil {
+		h.Fatalf("Fixture %s failed: %v", s.fixture.Name, s.err)
+	}
+	return s.cluster
+}
+
+func (s *fixtureState) setup(run *testRun) (platform.Cluster, error) {
+	if err := os.MkdirAll(s.dir, 0777); err != nil {
+		return nil, err
+	}
+	c, err := run.flight.NewCluster(s.rconf)
+	if err != nil {
+		return nil, fmt.Errorf("creating cluster: %v", err)
+	}
+
+	if s.fixture.ClusterSize > 0 {
+		userdata, err := clusterUserData(c, s.fixture.UserData, s.fixture.UserDataV3, s.fixture.ClusterSize)
+		if err != nil {
+			c.Destroy()
+			return nil, err
+		}
+		if _, err := platform.NewMachines(c, userdata, s.fixture.ClusterSize); err != nil {
+			c.Destroy()
+			return nil, fmt.Errorf("starting machines: %v", err)
+		}
+	}
+	if s.fixture.Setup != nil {
+		if err := s.fixture.Setup(c); err != nil {
+			c.Destroy()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// release ends h's use of the fixture, and tears it down if h's test was
+// the last to use it.  Problems found on the machines' consoles are
+// reported to that test.
+func (s *fixtureState) release(h *harness.H) {
+	s.lock.Lock()
+	s.users--
+	last := s.users == 0
+	s.lock.Unlock()
+	if !last || s.cluster == nil {
+		return
+	}
+
+	plog.Infof("Tearing down fixture %s", s.fixture.Name)
+	s.cluster.Destroy()
+	for id, output := range s.cluster.ConsoleOutput() {
+		for _, badness := range CheckConsole([]byte(output), nil) {
+			h.Errorf("Found %s on fixture %s machine %s console", badness, s.fixture.Name, id)
+		}
+	}
+	for id, output := range s.cluster.JournalOutput() {
+		for _, badness := range CheckConsole([]byte(output), nil) {
+			h.Errorf("Found %s on fixture %s machine %s journal", badness, s.fixture.Name, id)
+		}
+	}
+	attachMachineLogs(h, s.dir, s.cluster)
+}
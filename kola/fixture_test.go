@@ -0,0 +1,313 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/coreos/mantle/harness"
+	"github.com/coreos/mantle/harness/reporters"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+)
+
+type fakeFlight struct {
+	platform.Flight
+	cluster *fakeCluster
+	rconf   *platform.RuntimeConfig
+}
+
+func (f *fakeFlight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
+	f.rconf = rconf
+	return f.cluster, nil
+}
+
+type fakeCluster struct {
+	platform.Cluster
+
+	lock      sync.Mutex
+	destroyed int
+	console   map[string]string
+	journal   map[string]string
+}
+
+func (c *fakeCluster) Destroy() {
+	c.lock.Lock()
+	c.destroyed++
+	c.lock.Unlock()
+}
+
+func (c *fakeCluster) Destroyed() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.destroyed
+}
+
+func (c *fakeCluster) ConsoleOutput() map[string]string { return c.console }
+func (c *fakeCluster) JournalOutput() map[string]string { return c.journal }
+
+type resultSink struct {
+	lock    sync.Mutex
+	results map[string]reporters.EventType
+}
+
+func (r *resultSink) Event(e reporters.Event) {
+	if e.Type == reporters.EventStart || e.Type == reporters.EventLog {
+		return
+	}
+	r.lock.Lock()
+	r.results[e.Test] = e.Type
+	r.lock.Unlock()
+}
+
+func (r *resultSink) Close() error { return nil }
+
+// runFixtureSuite runs tests, each of which calls fn in place of the test
+// body, on a fake flight, and returns their results.
+func runFixtureSuite(t *testing.T, tests map[string]*register.Test, flight platform.Flight, fn func(h *harness.H, c platform.Cluster)) map[string]reporters.EventType {
+	dir, err := ioutil.TempDir("", "kola-fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir = filepath.Join(dir, "_test_temp")
+
+	run, err := newTestRun(tests, "qemu", flight, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &resultSink{results: make(map[string]reporters.EventType)}
+	htests := harness.Tests{}
+	for name, test := range tests {
+		test := test
+		htests.Add(name, func(h *harness.H) {
+			h.Parallel()
+			defer run.finish(h, test)
+			var fixture *fixtureState
+			if test.Fixture != "" {
+				fixture = run.fixtures[test.Fixture]
+				defer fixture.release(h)
+			}
+			run.waitForDependencies(h, test)
+			var c platform.Cluster
+			if fixture != nil {
+				c = fixture.acquire(h, run)
+			}
+			fn(h, c)
+		})
+	}
+	suite := harness.NewSuite(harness.Options{
+		OutputDir: dir,
+		Parallel:  2,
+		Events:    reporters.EventSinks{sink},
+	}, htests)
+	if err := suite.Run(); err != nil && err != harness.SuiteFailed {
+		t.Fatal(err)
+	}
+	return sink.results
+}
+
+func TestNewTestRunCycle(t *testing.T) {
+	defer delete(register.Fixtures, "test.config")
+	register.RegisterFixture(&register.Fixture{Name: "test.config"})
+
+	for _, tt := range []struct {
+		name  string
+		tests map[string]*register.Test
+		ok    bool
+	}{
+		{
+			"chain",
+			map[string]*register.Test{
+				"a": {Name: "a"},
+				"b": {Name: "b", DependsOn: []string{"a"}},
+				"c": {Name: "c", DependsOn: []string{"a", "b"}},
+			},
+			true,
+		},
+		{
+			"missing dependency",
+			map[string]*register.Test{
+				"b": {Name: "b", DependsOn: []string{"a"}},
+			},
+			true,
+		},
+		{
+			"self",
+			map[string]*register.Test{
+				"a": {Name: "a", DependsOn: []string{"a"}},
+			},
+			false,
+		},
+		{
+			"cycle",
+			map[string]*register.Test{
+				"a": {Name: "a", DependsOn: []string{"c"}},
+				"b": {Name: "b", DependsOn: []string{"a"}},
+				"c": {Name: "c", DependsOn: []string{"b"}},
+			},
+			false,
+		},
+		{
+			"unknown fixture",
+			map[string]*register.Test{
+				"a": {Name: "a", Fixture: "nonexistent"},
+			},
+			false,
+		},
+		{
+			"fixture with matching configs",
+			map[string]*register.Test{
+				"a": {Name: "a", Fixture: "test.config", Flags: []register.Flag{register.NoSSHKeyInMetadata}},
+				"b": {Name: "b", Fixture: "test.config", Flags: []register.Flag{register.NoSSHKeyInMetadata}},
+			},
+			true,
+		},
+		{
+			"fixture with conflicting flags",
+			map[string]*register.Test{
+				"a": {Name: "a", Fixture: "test.config", Flags: []register.Flag{register.NoSSHKeyInUserData}},
+				"b": {Name: "b", Fixture: "test.config"},
+			},
+			false,
+		},
+		{
+			"fixture with conflicting config delivery",
+			map[string]*register.Test{
+				"a": {Name: "a", Fixture: "test.config", ConfigDelivery: platform.ConfigDeliveryHTTP},
+				"b": {Name: "b", Fixture: "test.config"},
+			},
+			false,
+		},
+	} {
+		_, err := newTestRun(tt.tests, "qemu", nil, "")
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestFixtureTeardown(t *testing.T) {
+	setups := 0
+	defer delete(register.Fixtures, "test.shared")
+	register.RegisterFixture(&register.Fixture{
+		Name: "test.shared",
+		Setup: func(platform.Cluster) error {
+			setups++
+			return nil
+		},
+	})
+	tests := map[string]*register.Test{
+		"a": {Name: "a", Fixture: "test.shared", ConfigDelivery: platform.ConfigDeliveryHTTP},
+		"b": {Name: "b", Fixture: "test.shared", ConfigDelivery: platform.ConfigDeliveryHTTP},
+		"c": {Name: "c", Fixture: "test.shared", ConfigDelivery: platform.ConfigDeliveryHTTP, DependsOn: []string{"a"}},
+	}
+	cluster := &fakeCluster{}
+	flight := &fakeFlight{cluster: cluster}
+	results := runFixtureSuite(t, tests, flight, func(h *harness.H, c platform.Cluster) {
+		if c != cluster {
+			h.Errorf("got cluster %v", c)
+		}
+		if n := cluster.Destroyed(); n != 0 {
+			h.Errorf("fixture destroyed while in use")
+		}
+	})
+
+	expected := map[string]reporters.EventType{
+		"a": reporters.EventPass,
+		"b": reporters.EventPass,
+		"c": reporters.EventPass,
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("got results %v, expected %v", results, expected)
+	}
+	if setups != 1 {
+		t.Errorf("fixture set up %d times", setups)
+	}
+	if flight.rconf == nil || flight.rconf.ConfigDelivery != platform.ConfigDeliveryHTTP {
+		t.Errorf("fixture cluster created with %+v, expected the tests' config delivery", flight.rconf)
+	}
+	if n := cluster.Destroyed(); n != 1 {
+		t.Errorf("fixture destroyed %d times", n)
+	}
+}
+
+func TestFixtureSetupFailure(t *testing.T) {
+	defer delete(register.Fixtures, "test.broken")
+	register.RegisterFixture(&register.Fixture{
+		Name: "test.broken",
+		Setup: func(platform.Cluster) error {
+			return errors.New("broken")
+		},
+	})
+	tests := map[string]*register.Test{
+		"a": {Name: "a", Fixture: "test.broken"},
+		"b": {Name: "b", Fixture: "test.broken"},
+		"c": {Name: "c", Fixture: "test.broken"},
+	}
+	cluster := &fakeCluster{}
+	results := runFixtureSuite(t, tests, &fakeFlight{cluster: cluster}, func(h *harness.H, c platform.Cluster) {
+		h.Error("test ran without its fixture")
+	})
+
+	// whichever test set up the fixture fails, and the others skip
+	failed, skipped := 0, 0
+	for _, result := range results {
+		switch result {
+		case reporters.EventFail:
+			failed++
+		case reporters.EventSkip:
+			skipped++
+		}
+	}
+	if failed != 1 || skipped != 2 {
+		t.Errorf("got results %v, expected one failure and two skips", results)
+	}
+	if n := cluster.Destroyed(); n != 1 {
+		t.Errorf("failed fixture destroyed %d times", n)
+	}
+}
+
+func TestDependencySkip(t *testing.T) {
+	tests := map[string]*register.Test{
+		"a": {Name: "a"},
+		"b": {Name: "b", DependsOn: []string{"a"}},
+		"c": {Name: "c", DependsOn: []string{"b"}},
+		"d": {Name: "d"},
+	}
+	results := runFixtureSuite(t, tests, &fakeFlight{}, func(h *harness.H, c platform.Cluster) {
+		if h.Name() == "a" {
+			h.Fatal("failing")
+		}
+	})
+
+	expected := map[string]reporters.EventType{
+		"a": reporters.EventFail,
+		"b": reporters.EventSkip,
+		"c": reporters.EventSkip,
+		"d": reporters.EventPass,
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("got results %v, expected %v", results, expected)
+	}
+}
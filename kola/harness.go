@@ -15,12 +15,15 @@
 package kola
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
@@ -30,6 +33,7 @@ import (
 	"github.com/coreos/mantle/harness"
 	"github.com/coreos/mantle/harness/reporters"
 	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/history"
 	"github.com/coreos/mantle/kola/register"
 	"github.com/coreos/mantle/kola/torcx"
 	"github.com/coreos/mantle/platform"
@@ -38,6 +42,7 @@ import (
 	doapi "github.com/coreos/mantle/platform/api/do"
 	esxapi "github.com/coreos/mantle/platform/api/esx"
 	gcloudapi "github.com/coreos/mantle/platform/api/gcloud"
+	libvirtapi "github.com/coreos/mantle/platform/api/libvirt"
 	openstackapi "github.com/coreos/mantle/platform/api/openstack"
 	packetapi "github.com/coreos/mantle/platform/api/packet"
 	"github.com/coreos/mantle/platform/conf"
@@ -46,6 +51,7 @@ import (
 	"github.com/coreos/mantle/platform/machine/do"
 	"github.com/coreos/mantle/platform/machine/esx"
 	"github.com/coreos/mantle/platform/machine/gcloud"
+	"github.com/coreos/mantle/platform/machine/libvirt"
 	"github.com/coreos/mantle/platform/machine/openstack"
 	"github.com/coreos/mantle/platform/machine/packet"
 	"github.com/coreos/mantle/platform/machine/qemu"
@@ -62,12 +68,15 @@ var (
 	DOOptions        = doapi.Options{Options: &Options}        // glue to set platform options from main
 	ESXOptions       = esxapi.Options{Options: &Options}       // glue to set platform options from main
 	GCEOptions       = gcloudapi.Options{Options: &Options}    // glue to set platform options from main
+	LibvirtOptions   = libvirtapi.Options{Options: &Options}   // glue to set platform options from main
 	OpenStackOptions = openstackapi.Options{Options: &Options} // glue to set platform options from main
 	PacketOptions    = packetapi.Options{Options: &Options}    // glue to set platform options from main
 	QEMUOptions      = qemu.Options{Options: &Options}         // glue to set platform options from main
 
 	TestParallelism   int    //glue var to set test parallelism from main
+	QuotaCheck        string // one of the QuotaCheck* values
 	TAPFile           string // if not "", write TAP results here
+	EventStream       string // if not "", stream test events here as JSON lines
 	TorcxManifestFile string // torcx manifest to expose to tests, if set
 	// TorcxManifest is the unmarshalled torcx manifest file. It is available for
 	// tests to access via `kola.TorcxManifest`. It will be nil if there was no
@@ -77,6 +86,7 @@ var (
 	UpdatePayloadFile string
 
 	BlacklistedTests []string // tests which are blacklisted
+	Tags             string   // if not "", run only tests whose tags match this expression
 
 	consoleChecks = []struct {
 		desc     string
@@ -160,131 +170,60 @@ var (
 // glue until kola does introspection.
 type NativeRunner func(funcName string, m platform.Machine) error
 
-func NewFlight(pltfrm string) (flight platform.Flight, err error) {
+// NewFlight creates a flight on pltfrm which records the resources it
+// creates in journal, which may be nil.
+func NewFlight(pltfrm string, journal *platform.ResourceJournal) (flight platform.Flight, err error) {
 	switch pltfrm {
 	case "aws":
-		flight, err = aws.NewFlight(&AWSOptions)
+		flight, err = aws.NewFlight(&AWSOptions, journal)
 	case "azure":
-		flight, err = azure.NewFlight(&AzureOptions)
+		flight, err = azure.NewFlight(&AzureOptions, journal)
 	case "do":
-		flight, err = do.NewFlight(&DOOptions)
+		flight, err = do.NewFlight(&DOOptions, journal)
 	case "esx":
-		flight, err = esx.NewFlight(&ESXOptions)
+		flight, err = esx.NewFlight(&ESXOptions, journal)
 	case "gce":
-		flight, err = gcloud.NewFlight(&GCEOptions)
+		flight, err = gcloud.NewFlight(&GCEOptions, journal)
+	case "libvirt":
+		flight, err = libvirt.NewFlight(&LibvirtOptions, journal)
 	case "openstack":
-		flight, err = openstack.NewFlight(&OpenStackOptions)
+		flight, err = openstack.NewFlight(&OpenStackOptions, journal)
 	case "packet":
-		flight, err = packet.NewFlight(&PacketOptions)
+		flight, err = packet.NewFlight(&PacketOptions, journal)
 	case "qemu":
-		flight, err = qemu.NewFlight(&QEMUOptions)
+		flight, err = qemu.NewFlight(&QEMUOptions, journal)
 	case "qemu-unpriv":
-		flight, err = unprivqemu.NewFlight(&QEMUOptions)
+		flight, err = unprivqemu.NewFlight(&QEMUOptions, journal)
 	default:
 		err = fmt.Errorf("invalid platform %q", pltfrm)
 	}
 	return
 }
 
-func filterTests(tests map[string]*register.Test, pattern, pltfrm string, version semver.Version) (map[string]*register.Test, error) {
-	r := make(map[string]*register.Test)
-
-	checkPlatforms := []string{pltfrm}
-
-	// qemu-unpriv has the same restrictions as QEMU but might also want additional restrictions due to the lack of a Local cluster
-	if pltfrm == "qemu-unpriv" {
-		checkPlatforms = append(checkPlatforms, "qemu")
-	}
-
-	var blacklisted bool
-	for name, t := range tests {
-		// Drop anything which is blacklisted directly or by pattern
-		blacklisted = false
-		for _, bl := range BlacklistedTests {
-			match, err := filepath.Match(bl, t.Name)
-			if err != nil {
-				return nil, err
-			}
-			// If it matched the pattern this test is blacklisted
-			if match {
-				blacklisted = true
-				break
-			}
-		}
-		// If the test is blacklisted, skip it and continue to the next test
-		if blacklisted {
-			plog.Debugf("Skipping blacklisted test %s", t.Name)
-			continue
-		}
-
-		match, err := filepath.Match(pattern, t.Name)
-		if err != nil {
-			return nil, err
-		}
-		if !match {
-			continue
-		}
-
-		// Check the test's min and end versions when running more than one test
-		if t.Name != pattern && versionOutsideRange(version, t.MinVersion, t.EndVersion) {
-			continue
-		}
-
-		existsIn := func(item string, entries []string) bool {
-			for _, i := range entries {
-				if i == item {
-					return true
-				}
-			}
-			return false
-		}
-
-		if existsIn(pltfrm, register.PlatformsNoInternet) && t.HasFlag(register.RequiresInternetAccess) {
-			plog.Debugf("skipping test %s: Internet required but not supported by platform %s", t.Name, pltfrm)
-			continue
-		}
-
-		isAllowed := func(item string, include, exclude []string) (bool, bool) {
-			allowed, excluded := true, false
-			for _, i := range include {
-				if i == item {
-					allowed = true
-					break
-				} else {
-					allowed = false
-				}
-			}
-			for _, i := range exclude {
-				if i == item {
-					allowed = false
-					excluded = true
-				}
-			}
-			return allowed, excluded
+// isExactMatch reports whether one of the patterns is exactly name.
+func isExactMatch(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if name == pattern {
+			return true
 		}
+	}
+	return false
+}
 
-		isExcluded := false
-		allowed := false
-		for _, platform := range checkPlatforms {
-			allowedPlatform, excluded := isAllowed(platform, t.Platforms, t.ExcludePlatforms)
-			if excluded {
-				isExcluded = true
-				break
-			}
-			allowedArchitecture, _ := isAllowed(architecture(platform), t.Architectures, []string{})
-			allowed = allowed || (allowedPlatform && allowedArchitecture)
-		}
-		if isExcluded || !allowed {
-			continue
-		}
+func filterTests(tests map[string]*register.Test, patterns []string, pltfrm string, version semver.Version) (map[string]*register.Test, error) {
+	decisions, err := explainTests(tests, patterns, pltfrm, version)
+	if err != nil {
+		return nil, err
+	}
 
-		if allowed, excluded := isAllowed(Options.Distribution, t.Distros, t.ExcludeDistros); !allowed || excluded {
-			continue
+	r := make(map[string]*register.Test)
+	for _, d := range decisions {
+		if d.Included {
+			r[d.Name] = tests[d.Name]
+		} else {
+			plog.Debugf("Skipping test %s: %s", d.Name, d.Reason)
 		}
-
-		r[name] = t
 	}
-
 	return r, nil
 }
 
@@ -308,28 +247,28 @@ func versionOutsideRange(version, minVersion, endVersion semver.Version) bool {
 }
 
 // RunTests is a harness for running multiple tests in parallel. Filters
-// tests based on a glob pattern and by platform. Has access to all
+// tests based on glob patterns, tags and platform. Has access to all
 // tests either registered in this package or by imported packages that
 // register tests in their init() function.
 // outputDir is where various test logs and data will be written for
 // analysis after the test run. If it already exists it will be erased!
-func RunTests(pattern, pltfrm, outputDir string) error {
+func RunTests(patterns []string, pltfrm, outputDir string) error {
 	var versionStr string
 
 	// Avoid incurring cost of starting machine in getClusterSemver when
 	// either:
 	// 1) none of the selected tests care about the version
-	// 2) glob is an exact match which means minVersion will be ignored
+	// 2) globs are exact matches which means minVersion will be ignored
 	//    either way
 	// 3) the provided torcx flag is wrong
-	tests, err := filterTests(register.Tests, pattern, pltfrm, semver.Version{})
+	tests, err := filterTests(register.Tests, patterns, pltfrm, semver.Version{})
 	if err != nil {
 		plog.Fatal(err)
 	}
 
 	skipGetVersion := true
 	for name, t := range tests {
-		if name != pattern && (t.MinVersion != semver.Version{} || t.EndVersion != semver.Version{}) {
+		if !isExactMatch(name, patterns) && (t.MinVersion != semver.Version{} || t.EndVersion != semver.Version{}) {
 			skipGetVersion = false
 			break
 		}
@@ -347,7 +286,33 @@ func RunTests(pattern, pltfrm, outputDir string) error {
 		torcxManifestFile.Close()
 	}
 
-	flight, err := NewFlight(pltfrm)
+	// the suite closes the event stream once it runs, so close it
+	// here only if RunTests gives up first
+	var events reporters.EventSinks
+	if EventStream != "" {
+		stream, err := reporters.NewEventStream(EventStream)
+		if err != nil {
+			return fmt.Errorf("opening event stream: %v", err)
+		}
+		events = append(events, stream)
+	}
+	suiteStarted := false
+	defer func() {
+		if !suiteStarted {
+			events.Close()
+		}
+	}()
+
+	// check the quota before the flight creates anything
+	if err := checkQuota(pltfrm, EstimateResources(tests, TestParallelism, !skipGetVersion)); err != nil {
+		return err
+	}
+
+	journal, err := OpenResourceJournal(outputDir)
+	if err != nil {
+		return err
+	}
+	flight, err := NewFlight(pltfrm, journal)
 	if err != nil {
 		plog.Fatalf("Flight failed: %v", err)
 	}
@@ -363,7 +328,7 @@ func RunTests(pattern, pltfrm, outputDir string) error {
 		versionStr = version.String()
 
 		// one more filter pass now that we know real version
-		tests, err = filterTests(tests, pattern, pltfrm, *version)
+		tests, err = filterTests(tests, patterns, pltfrm, *version)
 		if err != nil {
 			plog.Fatal(err)
 		}
@@ -377,16 +342,21 @@ func RunTests(pattern, pltfrm, outputDir string) error {
 			reporters.NewJSONReporter("report.json", pltfrm, versionStr),
 		},
 	}
+	run, err := newTestRun(tests, pltfrm, flight, outputDir)
+	if err != nil {
+		return err
+	}
+	opts.Events = events
 	var htests harness.Tests
 	for _, test := range tests {
 		test := test // for the closure
-		run := func(h *harness.H) {
-			runTest(h, test, pltfrm, flight)
-		}
-		htests.Add(test.Name, run)
+		htests.Add(test.Name, func(h *harness.H) {
+			runTest(h, test, run)
+		})
 	}
 
 	suite := harness.NewSuite(opts, htests)
+	suiteStarted = true
 	err = suite.Run()
 
 	if TAPFile != "" {
@@ -454,58 +424,63 @@ func parseCLVersion(input string) (*semver.Version, error) {
 	return version, nil
 }
 
+// clusterUserData picks the userdata for the configured Ignition version
+// from v2 and v3, and fills in a discovery URL for size machines of c.
+func clusterUserData(c platform.Cluster, v2, v3 *conf.UserData, size int) (*conf.UserData, error) {
+	var userdata *conf.UserData
+	if Options.IgnitionVersion == "v2" {
+		userdata = v2
+	} else if Options.IgnitionVersion == "v3" {
+		userdata = v3
+	}
+	if userdata != nil && userdata.Contains("$discovery") {
+		url, err := c.GetDiscoveryURL(size)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create discovery endpoint: %v", err)
+		}
+		userdata = userdata.Subst("$discovery", url)
+	}
+	return userdata, nil
+}
+
 // runTest is a harness for running a single test.
 // outputDir is where various test logs and data will be written for
 // analysis after the test run. It should already exist.
-func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flight) {
+func runTest(h *harness.H, t *register.Test, run *testRun) {
 	h.Parallel()
+	defer run.finish(h, t)
 
-	rconf := &platform.RuntimeConfig{
-		OutputDir:          h.OutputDir(),
-		NoSSHKeyInUserData: t.HasFlag(register.NoSSHKeyInUserData),
-		NoSSHKeyInMetadata: t.HasFlag(register.NoSSHKeyInMetadata),
-		NoEnableSelinux:    t.HasFlag(register.NoEnableSelinux),
-	}
-	c, err := flight.NewCluster(rconf)
-	if err != nil {
-		h.Fatalf("Cluster failed: %v", err)
+	var fixture *fixtureState
+	if t.Fixture != "" {
+		fixture = run.fixtures[t.Fixture]
+		defer fixture.release(h)
 	}
-	defer func() {
-		c.Destroy()
-		for id, output := range c.ConsoleOutput() {
-			for _, badness := range CheckConsole([]byte(output), t) {
-				h.Errorf("Found %s on machine %s console", badness, id)
-			}
-		}
-		for id, output := range c.JournalOutput() {
-			for _, badness := range CheckConsole([]byte(output), t) {
-				h.Errorf("Found %s on machine %s journal", badness, id)
-			}
-		}
-	}()
+	run.waitForDependencies(h, t)
 
-	if t.ClusterSize > 0 {
-		var userdata *conf.UserData
-		if Options.IgnitionVersion == "v2" {
-			userdata = t.UserData
-		} else if Options.IgnitionVersion == "v3" {
-			userdata = t.UserDataV3
-		}
-		if userdata != nil && userdata.Contains("$discovery") {
-			url, err := c.GetDiscoveryURL(t.ClusterSize)
-			if err != nil {
-				// Skip instead of failing since the harness not being able to
-				// get a discovery url is likely an outage (e.g
-				// 503 Service Unavailable: Back-end server is at capacity)
-				// not a problem with the OS
-				h.Skipf("Failed to create discovery endpoint: %v", err)
+	var c platform.Cluster
+	if fixture != nil {
+		c = fixture.acquire(h, run)
+	} else {
+		c = newTestCluster(h, t, run.flight)
+		defer func() {
+			c.Destroy()
+			for id, output := range c.ConsoleOutput() {
+				for _, badness := range CheckConsole([]byte(output), t) {
+					h.Errorf("Found %s on machine %s console", badness, id)
+				}
 			}
-			userdata = userdata.Subst("$discovery", url)
-		}
+			for id, output := range c.JournalOutput() {
+				for _, badness := range CheckConsole([]byte(output), t) {
+					h.Errorf("Found %s on machine %s journal", badness, id)
+				}
+			}
+			attachMachineLogs(h, h.OutputDir(), c)
+		}()
+		startTestMachines(h, t, c)
+	}
 
-		if _, err := platform.NewMachines(c, userdata, t.ClusterSize); err != nil {
-			h.Fatalf("Cluster failed starting machines: %v", err)
-		}
+	if !t.HasFlag(register.NoSSHKeyInUserData) && !t.HasFlag(register.NoSSHKeyInMetadata) {
+		run.recordVersion(c)
 	}
 
 	// pass along all registered native functions
@@ -519,12 +494,13 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 		H:           h,
 		Cluster:     c,
 		NativeFuncs: names,
+		KoletPath:   findKolet(architecture(run.pltfrm)),
 		FailFast:    t.FailFast,
 	}
 
 	// drop kolet binary on machines
-	if t.NativeFuncs != nil {
-		scpKolet(tcluster, architecture(pltfrm))
+	if len(t.NativeFuncs) > 0 || len(t.NativeJSONFuncs) > 0 {
+		scpKolet(tcluster, architecture(run.pltfrm))
 	}
 
 	defer func() {
@@ -534,13 +510,109 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 	}()
 
 	// run test
-	t.Run(tcluster)
+	if t.Iterations <= 1 {
+		t.Run(tcluster)
+		return
+	}
+	for i := 1; i <= t.Iterations; i++ {
+		h.Logf("Iteration %d of %d", i, t.Iterations)
+		t.Run(tcluster)
+	}
+}
+
+// recordVersion writes the OS version of c's first machine to the run's
+// history.VersionFile, unless an earlier test has, so that the run's
+// history knows the version even if no test needed it.
+func (run *testRun) recordVersion(c platform.Cluster) {
+	run.versionLock.Lock()
+	defer run.versionLock.Unlock()
+	machines := c.Machines()
+	if run.versionRecorded || len(machines) == 0 {
+		return
+	}
+	out, stderr, err := machines[0].SSH(`. /etc/os-release && echo "${OSTREE_VERSION:-$VERSION_ID}"`)
+	if err != nil {
+		plog.Debugf("Reading OS version: %v: %s", err, stderr)
+		return
+	}
+	version := append(bytes.TrimSpace(out), '\n')
+	if err := ioutil.WriteFile(filepath.Join(run.outputDir, history.VersionFile), version, 0644); err != nil {
+		plog.Warningf("Recording OS version: %v", err)
+		return
+	}
+	run.versionRecorded = true
+}
+
+// newTestCluster creates the cluster for a test which doesn't use a
+// fixture.
+func newTestCluster(h *harness.H, t *register.Test, flight platform.Flight) platform.Cluster {
+	c, err := flight.NewCluster(testRuntimeConfig(t, h.OutputDir()))
+	if err != nil {
+		h.Fatalf("Cluster failed: %v", err)
+	}
+	return c
+}
+
+// testRuntimeConfig returns the configuration of the cluster t runs on,
+// which writes its output to outputDir.
+func testRuntimeConfig(t *register.Test, outputDir string) *platform.RuntimeConfig {
+	return &platform.RuntimeConfig{
+		OutputDir:          outputDir,
+		NoSSHKeyInUserData: t.HasFlag(register.NoSSHKeyInUserData),
+		NoSSHKeyInMetadata: t.HasFlag(register.NoSSHKeyInMetadata),
+		NoEnableSelinux:    t.HasFlag(register.NoEnableSelinux),
+		ConfigDelivery:     t.ConfigDelivery,
+	}
+}
+
+// startTestMachines starts the machines of a test's cluster.
+func startTestMachines(h *harness.H, t *register.Test, c platform.Cluster) {
+	if t.ClusterSize > 0 {
+		userdata, err := clusterUserData(c, t.UserData, t.UserDataV3, t.ClusterSize)
+		if err != nil {
+			// Skip instead of failing since the harness not being able to
+			// get a discovery url is likely an outage (e.g
+			// 503 Service Unavailable: Back-end server is at capacity)
+			// not a problem with the OS
+			h.Skip(err)
+		}
+
+		if _, err := platform.NewMachines(c, userdata, t.ClusterSize); err != nil {
+			h.Fatalf("Cluster failed starting machines: %v", err)
+		}
+	}
+}
+
+// attachMachineLogs attaches the console and journal of each of the
+// cluster's machines to the test, once the machines are destroyed.
+func attachMachineLogs(h *harness.H, dir string, c platform.Cluster) {
+	outputs := map[string]map[string]string{
+		"console.txt": c.ConsoleOutput(),
+		"journal.txt": c.JournalOutput(),
+	}
+	for name, output := range outputs {
+		for id, text := range output {
+			// local platforms write the logs to the output
+			// directory, but cloud platforms only keep them in
+			// memory
+			var err error
+			path := filepath.Join(dir, id, name)
+			if _, statErr := os.Stat(path); statErr == nil {
+				err = h.ArtifactFile(filepath.Join(id, name), path, "text/plain")
+			} else if text != "" {
+				err = h.Artifact(filepath.Join(id, name), strings.NewReader(text), "text/plain")
+			}
+			if err != nil {
+				plog.Warningf("Attaching %s of machine %s: %v", name, id, err)
+			}
+		}
+	}
 }
 
 // architecture returns the machine architecture of the given platform.
 func architecture(pltfrm string) string {
 	nativeArch := "amd64"
-	if pltfrm == "qemu" && QEMUOptions.Board != "" {
+	if (pltfrm == "qemu" || pltfrm == "qemu-unpriv") && QEMUOptions.Board != "" {
 		nativeArch = boardToArch(QEMUOptions.Board)
 	}
 	if pltfrm == "packet" && PacketOptions.Board != "" {
@@ -554,32 +626,44 @@ func boardToArch(board string) string {
 	return strings.SplitN(board, "-", 2)[0]
 }
 
-// scpKolet searches for a kolet binary and copies it to the machine.
-func scpKolet(c cluster.TestCluster, mArch string) {
-	for _, d := range []string{
-		".",
-		filepath.Dir(os.Args[0]),
+// findKolet searches for a kolet binary for the architecture, returning
+// "" if there is none.
+func findKolet(mArch string) string {
+	dirs := []string{
 		filepath.Join(filepath.Dir(os.Args[0]), mArch),
 		filepath.Join("/usr/lib/kola", mArch),
-	} {
+	}
+	if mArch == runtime.GOARCH {
+		// a kolet without an architecture is built for the host,
+		// which is wrong for emulated machines
+		dirs = append([]string{".", filepath.Dir(os.Args[0])}, dirs...)
+	}
+	for _, d := range dirs {
 		kolet := filepath.Join(d, "kolet")
 		if _, err := os.Stat(kolet); err == nil {
-			if err := c.DropFile(kolet); err != nil {
-				c.Fatalf("dropping kolet binary: %v", err)
-			}
-			// The default SELinux rules do not allow init_t to execute user_home_t
-			if Options.Distribution == "rhcos" || Options.Distribution == "fcos" {
-				for _, machine := range c.Machines() {
-					out, stderr, err := machine.SSH("sudo chcon -t bin_t kolet")
-					if err != nil {
-						c.Fatalf("running chcon on kolet: %s: %s: %v", out, stderr, err)
-					}
-				}
+			return kolet
+		}
+	}
+	return ""
+}
+
+// scpKolet copies the kolet binary to the machines.
+func scpKolet(c cluster.TestCluster, mArch string) {
+	if c.KoletPath == "" {
+		c.Fatalf("Unable to locate kolet binary for %s", mArch)
+	}
+	if err := c.DropFile(c.KoletPath); err != nil {
+		c.Fatalf("dropping kolet binary: %v", err)
+	}
+	// The default SELinux rules do not allow init_t to execute user_home_t
+	if Options.Distribution == "rhcos" || Options.Distribution == "fcos" {
+		for _, machine := range c.Machines() {
+			out, stderr, err := machine.SSH("sudo chcon -t bin_t kolet")
+			if err != nil {
+				c.Fatalf("running chcon on kolet: %s: %s: %v", out, stderr, err)
 			}
-			return
 		}
 	}
-	c.Fatalf("Unable to locate kolet binary for %s", mArch)
 }
 
 // CheckConsole checks some console output for badness and returns short
@@ -604,10 +688,14 @@ func CheckConsole(output []byte, t *register.Test) []string {
 	return ret
 }
 
-func SetupOutputDir(outputDir, platform string) (string, error) {
+// SetupOutputDir creates the output directory, choosing a default if
+// outputDir is empty, and arranges for flights to record the resources
+// they create in its resource journal.  It refuses to reuse a directory
+// whose journal still lists resources, since they'd leak.
+func SetupOutputDir(outputDir, pltfrm string) (string, error) {
 	defaulted := outputDir == ""
 	defaultBaseDirName := "_kola_temp"
-	defaultDirName := fmt.Sprintf("%s-%s-%d", platform, time.Now().Format("2006-01-02-1504"), os.Getpid())
+	defaultDirName := fmt.Sprintf("%s-%s-%d", pltfrm, time.Now().Format("2006-01-02-1504"), os.Getpid())
 
 	if defaulted {
 		if _, err := os.Stat(defaultBaseDirName); os.IsNotExist(err) {
@@ -618,6 +706,13 @@ func SetupOutputDir(outputDir, platform string) (string, error) {
 		outputDir = filepath.Join(defaultBaseDirName, defaultDirName)
 	}
 
+	// don't discard the record of resources leaked by an earlier run
+	if resources, err := platform.ReadResourceJournal(ResourceJournalPath(outputDir)); err == nil && len(resources) > 0 {
+		return "", fmt.Errorf("%s lists %d resources leaked by an earlier run; delete them with 'kola cleanup %s' first", ResourceJournalPath(outputDir), len(resources), outputDir)
+	} else if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
 	outputDir, err := harness.CleanOutputDir(outputDir)
 	if err != nil {
 		return "", err
@@ -625,7 +720,7 @@ func SetupOutputDir(outputDir, platform string) (string, error) {
 
 	if defaulted {
 		tempLinkPath := filepath.Join(outputDir, "latest")
-		linkPath := filepath.Join(defaultBaseDirName, platform+"-latest")
+		linkPath := filepath.Join(defaultBaseDirName, pltfrm+"-latest")
 		// don't clobber existing files that are not symlinks
 		st, err := os.Lstat(linkPath)
 		if err == nil && (st.Mode()&os.ModeType) != os.ModeSymlink {
@@ -0,0 +1,73 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/mantle/harness"
+)
+
+func TestAttachMachineLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputDir := filepath.Join(dir, "_test_temp")
+
+	// machine a writes its console to disk, machine b only has it in
+	// memory
+	machineDir := filepath.Join(dir, "machines")
+	if err := os.MkdirAll(filepath.Join(machineDir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(machineDir, "a", "console.txt"), []byte("from disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &fakeCluster{
+		console: map[string]string{"a": "in memory", "b": "console b"},
+		journal: map[string]string{"b": "journal b", "c": ""},
+	}
+
+	suite := harness.NewSuite(harness.Options{OutputDir: outputDir}, harness.Tests{
+		"logs": func(h *harness.H) {
+			attachMachineLogs(h, machineDir, c)
+		},
+	})
+	if err := suite.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := filepath.Join(outputDir, "logs", "artifacts")
+	for path, expected := range map[string]string{
+		"a/console.txt": "from disk",
+		"b/console.txt": "console b",
+		"b/journal.txt": "journal b",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(artifacts, path))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if string(data) != expected {
+			t.Errorf("%s contains %q, expected %q", path, data, expected)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(artifacts, "c", "journal.txt")); err == nil {
+		t.Error("empty journal attached")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history keeps the results of kola runs in a local database so
+// they can be compared across runs.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+var runsBucket = []byte("runs")
+
+// VersionFile is the file in a run's output directory which holds the
+// OS version its machines ran, for runs whose report doesn't say.
+const VersionFile = "os-version.txt"
+
+// DefaultPath returns the per-user database path,
+// $XDG_DATA_HOME/kola/history.db, where XDG_DATA_HOME defaults to
+// ~/.local/share.
+func DefaultPath() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "kola-history.db"
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "kola", "history.db")
+}
+
+// Run is the record of one kola run.
+type Run struct {
+	ID       string       `json:"id"`  // Dir and the start time
+	Dir      string       `json:"dir"` // absolute path of the output directory
+	Time     time.Time    `json:"time"`
+	Platform string       `json:"platform"`
+	Version  string       `json:"version"`
+	Distro   string       `json:"distro"`
+	Board    string       `json:"board"`
+	Tests    []TestRecord `json:"tests"`
+}
+
+type TestRecord struct {
+	Name     string                `json:"name"`
+	Result   testresult.TestResult `json:"result"`
+	Duration time.Duration         `json:"duration"`
+}
+
+// ReadRun reads the record of a run from the report.json,
+// properties.json and VersionFile in its output directory.  The latter
+// two are optional, since other harness users don't write them.  The run
+// is timed by its start in properties.json, or else by when its report
+// was written.
+func ReadRun(dir string) (*Run, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	reportPath := filepath.Join(dir, "report.json")
+	info, err := os.Stat(reportPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var report struct {
+		Platform string       `json:"platform"`
+		Version  string       `json:"version"`
+		Tests    []TestRecord `json:"tests"`
+	}
+	if err := readJSON(reportPath, &report); err != nil {
+		return nil, err
+	}
+	var props struct {
+		Platform string    `json:"platform"`
+		Distro   string    `json:"distro"`
+		Board    string    `json:"board"`
+		Start    time.Time `json:"start"`
+	}
+	if err := readJSON(filepath.Join(dir, "properties.json"), &props); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	run := &Run{
+		Dir:      dir,
+		Time:     info.ModTime(),
+		Platform: report.Platform,
+		Version:  report.Version,
+		Distro:   props.Distro,
+		Board:    props.Board,
+		Tests:    report.Tests,
+	}
+	if run.Platform == "" {
+		run.Platform = props.Platform
+	}
+	if !props.Start.IsZero() {
+		run.Time = props.Start
+	}
+	if run.Version == "" {
+		version, err := ioutil.ReadFile(filepath.Join(dir, VersionFile))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		run.Version = strings.TrimSpace(string(version))
+	}
+	// repeated runs in the same directory are distinct
+	run.ID = fmt.Sprintf("%s@%s", dir, run.Time.UTC().Format(time.RFC3339Nano))
+	return run, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return nil
+}
+
+// DB is a database of runs.
+type DB struct {
+	db *bolt.DB
+}
+
+// Open opens the database at path, creating it if it doesn't exist.
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Minute})
+	if err != nil {
+		return nil, fmt.Errorf("opening history database %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(runsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Add records a run, replacing any earlier record with the same ID.
+func (d *DB) Add(run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).Put([]byte(run.ID), data)
+	})
+}
+
+// Runs returns all recorded runs, oldest first.
+func (d *DB) Runs() ([]Run, error) {
+	var runs []Run
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(k, v []byte) error {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("decoding run %s: %v", k, err)
+			}
+			runs = append(runs, run)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Time.Before(runs[j].Time)
+	})
+	return runs, nil
+}
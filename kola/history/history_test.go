@@ -0,0 +1,204 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+var epoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func run(id string, hours int, version string, results ...testresult.TestResult) Run {
+	r := Run{
+		ID:       id,
+		Time:     epoch.Add(time.Duration(hours) * time.Hour),
+		Platform: "qemu",
+		Version:  version,
+	}
+	names := []string{"a", "b"}
+	for i, result := range results {
+		r.Tests = append(r.Tests, TestRecord{
+			Name:     names[i],
+			Result:   result,
+			Duration: time.Duration(i+1) * time.Minute,
+		})
+	}
+	return r
+}
+
+var testRuns = []Run{
+	run("1", 0, "2000.0.0", testresult.Pass, testresult.Pass),
+	run("2", 1, "2000.0.0", testresult.Fail, testresult.Pass),
+	run("3", 2, "2010.0.0", testresult.Pass, testresult.Fail),
+	run("4", 3, "2100.0.0", testresult.Skip, testresult.Fail),
+}
+
+func TestPassRates(t *testing.T) {
+	rates := PassRates(testRuns)
+	expected := []PassRate{
+		{Test: "a", Platform: "qemu", Version: "2000.0.0", Passed: 1, Failed: 1, Rate: 0.5},
+		{Test: "a", Platform: "qemu", Version: "2010.0.0", Passed: 1, Rate: 1},
+		{Test: "a", Platform: "qemu", Version: "2100.0.0", Skipped: 1},
+		{Test: "b", Platform: "qemu", Version: "2000.0.0", Passed: 2, Rate: 1},
+		{Test: "b", Platform: "qemu", Version: "2010.0.0", Failed: 1},
+		{Test: "b", Platform: "qemu", Version: "2100.0.0", Failed: 1},
+	}
+	if !reflect.DeepEqual(rates, expected) {
+		t.Errorf("got %+v, expected %+v", rates, expected)
+	}
+}
+
+func TestFlakes(t *testing.T) {
+	flakes := Flakes(testRuns, epoch)
+	expected := []Flake{
+		{Test: "a", Platform: "qemu", Version: "2000.0.0", Passed: 1, Failed: 1, LastFailure: epoch.Add(time.Hour)},
+	}
+	if !reflect.DeepEqual(flakes, expected) {
+		t.Errorf("got %+v, expected %+v", flakes, expected)
+	}
+	if flakes := Flakes(testRuns, epoch.Add(90*time.Minute)); len(flakes) != 0 {
+		t.Errorf("got flakes %+v before the window", flakes)
+	}
+}
+
+func TestFirstFailures(t *testing.T) {
+	failures := FirstFailures(testRuns)
+	expected := []FirstFailure{
+		{Test: "b", Platform: "qemu", FirstFailingVersion: "2010.0.0", LastPassingVersion: "2000.0.0"},
+	}
+	if !reflect.DeepEqual(failures, expected) {
+		t.Errorf("got %+v, expected %+v", failures, expected)
+	}
+}
+
+func TestSlowest(t *testing.T) {
+	slowest := Slowest(testRuns, 1)
+	expected := []Duration{
+		{Test: "b", Platform: "qemu", Runs: 2, Mean: 2 * time.Minute, Max: 2 * time.Minute},
+	}
+	if !reflect.DeepEqual(slowest, expected) {
+		t.Errorf("got %+v, expected %+v", slowest, expected)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"2000.0.0", "2000.0.0", 0},
+		{"2000.0.0", "2010.0.0", -1},
+		{"2191.4.1", "2191.10.0", -1},
+		{"42.80.20190828.2", "42.80.20190828.10", -1},
+		{"30.1.2", "30.1", 1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+	} {
+		if c := CompareVersions(tt.a, tt.b); c != tt.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, c, tt.expected)
+		}
+	}
+}
+
+func TestDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outputDir := filepath.Join(dir, "output")
+	if err := os.Mkdir(outputDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	// the report has no version when no test needed it
+	report := `{"tests": [{"name": "a", "result": "PASS", "duration": 60000000000}], "result": "PASS", "platform": "qemu", "version": ""}`
+	props := `{"platform": "qemu", "distro": "cl", "board": "amd64-usr", "start": "2019-10-01T12:00:00Z"}`
+	if err := ioutil.WriteFile(filepath.Join(outputDir, "report.json"), []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(outputDir, "properties.json"), []byte(props), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(outputDir, VersionFile), []byte("2000.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadRun(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	if r.Dir != outputDir || !r.Time.Equal(start) || r.Distro != "cl" || r.Board != "amd64-usr" || r.Version != "2000.0.0" {
+		t.Errorf("unexpected run %+v", r)
+	}
+
+	db, err := Open(filepath.Join(dir, "db", "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// adding the same run twice replaces it
+	for i := 0; i < 2; i++ {
+		if err := db.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := db.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != r.ID || !reflect.DeepEqual(runs[0].Tests, r.Tests) {
+		t.Errorf("got runs %+v, expected %+v", runs, r)
+	}
+
+	// a later run in the same directory is kept separately
+	props = `{"platform": "qemu", "start": "2019-10-02T12:00:00Z"}`
+	if err := ioutil.WriteFile(filepath.Join(outputDir, "properties.json"), []byte(props), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r2, err := ReadRun(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add(r2); err != nil {
+		t.Fatal(err)
+	}
+	if runs, err = db.Runs(); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != r.ID || runs[1].ID != r2.ID {
+		t.Errorf("got runs %+v, expected %s and %s", runs, r.ID, r2.ID)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	defer os.Setenv("XDG_DATA_HOME", os.Getenv("XDG_DATA_HOME"))
+	os.Setenv("XDG_DATA_HOME", "/data")
+	if path := DefaultPath(); path != "/data/kola/history.db" {
+		t.Errorf("got %s with XDG_DATA_HOME set", path)
+	}
+	os.Unsetenv("XDG_DATA_HOME")
+	if path := DefaultPath(); !strings.HasSuffix(path, "/.local/share/kola/history.db") {
+		t.Errorf("got %s without XDG_DATA_HOME", path)
+	}
+}
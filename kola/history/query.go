@@ -0,0 +1,291 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+type PassRate struct {
+	Test     string  `json:"test"`
+	Platform string  `json:"platform"`
+	Version  string  `json:"version"`
+	Passed   int     `json:"passed"`
+	Failed   int     `json:"failed"`
+	Skipped  int     `json:"skipped"`
+	Rate     float64 `json:"rate"` // passed / (passed + failed)
+}
+
+type Flake struct {
+	Test        string    `json:"test"`
+	Platform    string    `json:"platform"`
+	Version     string    `json:"version"`
+	Passed      int       `json:"passed"`
+	Failed      int       `json:"failed"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+type FirstFailure struct {
+	Test                string `json:"test"`
+	Platform            string `json:"platform"`
+	FirstFailingVersion string `json:"first_failing_version"`
+	LastPassingVersion  string `json:"last_passing_version"` // empty if it never passed
+}
+
+type Duration struct {
+	Test     string        `json:"test"`
+	Platform string        `json:"platform"`
+	Runs     int           `json:"runs"`
+	Mean     time.Duration `json:"mean"`
+	Max      time.Duration `json:"max"`
+}
+
+type key struct {
+	test, platform, version string
+}
+
+func lessKey(a, b key) bool {
+	if a.test != b.test {
+		return a.test < b.test
+	}
+	if a.platform != b.platform {
+		return a.platform < b.platform
+	}
+	return CompareVersions(a.version, b.version) < 0
+}
+
+// PassRates returns the results of each test by platform and version.
+func PassRates(runs []Run) []PassRate {
+	rates := make(map[key]*PassRate)
+	var keys []key
+	for _, run := range runs {
+		for _, t := range run.Tests {
+			k := key{t.Name, run.Platform, run.Version}
+			r, ok := rates[k]
+			if !ok {
+				r = &PassRate{Test: t.Name, Platform: run.Platform, Version: run.Version}
+				rates[k] = r
+				keys = append(keys, k)
+			}
+			switch t.Result {
+			case testresult.Pass:
+				r.Passed++
+			case testresult.Fail:
+				r.Failed++
+			case testresult.Skip:
+				r.Skipped++
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+
+	result := make([]PassRate, 0, len(keys))
+	for _, k := range keys {
+		r := rates[k]
+		if r.Passed+r.Failed > 0 {
+			r.Rate = float64(r.Passed) / float64(r.Passed+r.Failed)
+		}
+		result = append(result, *r)
+	}
+	return result
+}
+
+// Flakes returns the tests which both passed and failed on the same
+// platform and version in the runs since a time, most failures first.
+func Flakes(runs []Run, since time.Time) []Flake {
+	flakes := make(map[key]*Flake)
+	var keys []key
+	for _, run := range runs {
+		if run.Time.Before(since) {
+			continue
+		}
+		for _, t := range run.Tests {
+			k := key{t.Name, run.Platform, run.Version}
+			f, ok := flakes[k]
+			if !ok {
+				f = &Flake{Test: t.Name, Platform: run.Platform, Version: run.Version}
+				flakes[k] = f
+				keys = append(keys, k)
+			}
+			switch t.Result {
+			case testresult.Pass:
+				f.Passed++
+			case testresult.Fail:
+				f.Failed++
+				if run.Time.After(f.LastFailure) {
+					f.LastFailure = run.Time
+				}
+			}
+		}
+	}
+
+	var result []Flake
+	for _, k := range keys {
+		if f := flakes[k]; f.Passed > 0 && f.Failed > 0 {
+			result = append(result, *f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Failed != result[j].Failed {
+			return result[i].Failed > result[j].Failed
+		}
+		return lessKey(key{result[i].Test, result[i].Platform, result[i].Version},
+			key{result[j].Test, result[j].Platform, result[j].Version})
+	})
+	return result
+}
+
+// FirstFailures returns the tests whose latest result on a platform is a
+// failure, with the version where they started failing.  Runs without a
+// version are ignored.
+func FirstFailures(runs []Run) []FirstFailure {
+	type outcome struct {
+		version string
+		time    time.Time
+		pass    bool
+	}
+	outcomes := make(map[key][]outcome)
+	var keys []key
+	for _, run := range runs {
+		if run.Version == "" {
+			continue
+		}
+		for _, t := range run.Tests {
+			if t.Result != testresult.Pass && t.Result != testresult.Fail {
+				continue
+			}
+			k := key{test: t.Name, platform: run.Platform}
+			if _, ok := outcomes[k]; !ok {
+				keys = append(keys, k)
+			}
+			outcomes[k] = append(outcomes[k], outcome{run.Version, run.Time, t.Result == testresult.Pass})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+
+	var result []FirstFailure
+	for _, k := range keys {
+		o := outcomes[k]
+		sort.SliceStable(o, func(i, j int) bool {
+			if c := CompareVersions(o[i].version, o[j].version); c != 0 {
+				return c < 0
+			}
+			return o[i].time.Before(o[j].time)
+		})
+		i := len(o) - 1
+		if o[i].pass {
+			continue
+		}
+		for i > 0 && !o[i-1].pass {
+			i--
+		}
+		f := FirstFailure{
+			Test:                k.test,
+			Platform:            k.platform,
+			FirstFailingVersion: o[i].version,
+		}
+		if i > 0 {
+			f.LastPassingVersion = o[i-1].version
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// Slowest returns the limit tests with the longest mean duration of
+// their passing runs on a platform, or all tests if limit is 0.
+func Slowest(runs []Run, limit int) []Duration {
+	durations := make(map[key]*Duration)
+	var keys []key
+	for _, run := range runs {
+		for _, t := range run.Tests {
+			if t.Result != testresult.Pass {
+				continue
+			}
+			k := key{test: t.Name, platform: run.Platform}
+			d, ok := durations[k]
+			if !ok {
+				d = &Duration{Test: t.Name, Platform: run.Platform}
+				durations[k] = d
+				keys = append(keys, k)
+			}
+			d.Runs++
+			// accumulate the total in Mean until it's divided below
+			d.Mean += t.Duration
+			if t.Duration > d.Max {
+				d.Max = t.Duration
+			}
+		}
+	}
+
+	result := make([]Duration, 0, len(keys))
+	for _, k := range keys {
+		d := durations[k]
+		d.Mean /= time.Duration(d.Runs)
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Mean != result[j].Mean {
+			return result[i].Mean > result[j].Mean
+		}
+		return lessKey(key{test: result[i].Test, platform: result[i].Platform},
+			key{test: result[j].Test, platform: result[j].Platform})
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// CompareVersions orders OS versions by comparing their dot- and
+// dash-separated fields, numerically where both fields are numbers.  It
+// handles both semantic versions and RHCOS-style versions such as
+// 42.80.20190828.2.
+func CompareVersions(a, b string) int {
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool { return r == '.' || r == '-' || r == '+' })
+	}
+	af, bf := split(a), split(b)
+	for i := 0; i < len(af) && i < len(bf); i++ {
+		an, aerr := strconv.ParseUint(af[i], 10, 64)
+		bn, berr := strconv.ParseUint(bf[i], 10, 64)
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case af[i] != bf[i]:
+			if af[i] < bf[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(af) < len(bf):
+		return -1
+	case len(af) > len(bf):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package native is the protocol between kola and native functions run
+// by kolet which take arguments and return results.
+//
+// kola writes the function's arguments to kolet's stdin as JSON.  kolet
+// writes a stream of JSON messages to its stdout: any number of log
+// messages, then a final message with the function's result or error.
+// kolet keeps its stdout for these messages alone, so anything else the
+// function prints goes to stderr.
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Func is a native function.  args holds its JSON-encoded arguments,
+// and its result is encoded as JSON for the caller.
+type Func func(log *Logger, args json.RawMessage) (interface{}, error)
+
+// Message is a line of a native function's output.
+type Message struct {
+	Log    string          `json:"log,omitempty"`
+	Done   bool            `json:"done,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Logger sends log messages from a native function to the harness.
+type Logger struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+func (l *Logger) send(m Message) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.enc.Encode(m)
+}
+
+// Log formats its arguments like fmt.Sprint and logs them in the
+// calling test.
+func (l *Logger) Log(args ...interface{}) {
+	l.send(Message{Log: fmt.Sprint(args...)})
+}
+
+// Logf formats its arguments like fmt.Sprintf and logs them in the
+// calling test.
+func (l *Logger) Logf(format string, args ...interface{}) {
+	l.send(Message{Log: fmt.Sprintf(format, args...)})
+}
+
+// Serve runs f with the arguments read from in, writing its logs and
+// result to out.  It returns f's error, if any.
+func Serve(f Func, in io.Reader, out io.Writer) error {
+	l := &Logger{enc: json.NewEncoder(out)}
+
+	args, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []byte("null")
+	}
+	if !json.Valid(args) {
+		err = errors.New("arguments aren't valid JSON")
+		l.send(Message{Done: true, Error: err.Error()})
+		return err
+	}
+
+	result, err := f(l, args)
+	if err != nil {
+		l.send(Message{Done: true, Error: err.Error()})
+		return err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		err = fmt.Errorf("encoding result: %v", err)
+		l.send(Message{Done: true, Error: err.Error()})
+		return err
+	}
+	return l.send(Message{Done: true, Result: data})
+}
+
+// Decode reads the output of a native function from r, passing its log
+// messages to logf as they arrive, and decodes its result into result
+// unless result is nil.  It returns the function's error, if any.
+func Decode(r io.Reader, logf func(string), result interface{}) error {
+	dec := json.NewDecoder(r)
+	for {
+		var m Message
+		if err := dec.Decode(&m); err == io.EOF {
+			return errors.New("native function exited without a result")
+		} else if err != nil {
+			return fmt.Errorf("reading native function output: %v", err)
+		}
+		if !m.Done {
+			logf(m.Log)
+			continue
+		}
+
+		if m.Error != "" {
+			return errors.New(m.Error)
+		}
+		if result != nil {
+			if err := json.Unmarshal(m.Result, result); err != nil {
+				return fmt.Errorf("decoding native function result: %v", err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sumArgs struct {
+	Values []int `json:"values"`
+}
+
+type sumResult struct {
+	Sum int `json:"sum"`
+}
+
+func sum(log *Logger, args json.RawMessage) (interface{}, error) {
+	var a sumArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if len(a.Values) == 0 {
+		return nil, errors.New("nothing to add")
+	}
+	var r sumResult
+	for _, v := range a.Values {
+		log.Logf("adding %d", v)
+		r.Sum += v
+	}
+	return r, nil
+}
+
+func TestServeDecode(t *testing.T) {
+	var out bytes.Buffer
+	if err := Serve(sum, strings.NewReader(`{"values": [1, 2, 3]}`), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []string
+	var result sumResult
+	if err := Decode(&out, func(s string) { logs = append(logs, s) }, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Sum != 6 {
+		t.Errorf("got sum %d, expected 6", result.Sum)
+	}
+	if expected := []string{"adding 1", "adding 2", "adding 3"}; !reflect.DeepEqual(logs, expected) {
+		t.Errorf("got logs %q, expected %q", logs, expected)
+	}
+}
+
+func TestServeError(t *testing.T) {
+	var out bytes.Buffer
+	if err := Serve(sum, strings.NewReader(`{}`), &out); err == nil {
+		t.Fatal("Serve succeeded with no values")
+	}
+	err := Decode(&out, func(string) {}, nil)
+	if err == nil || err.Error() != "nothing to add" {
+		t.Errorf("got error %v, expected \"nothing to add\"", err)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	if err := Decode(strings.NewReader(`{"log": "starting"}`+"\n"), func(string) {}, nil); err == nil {
+		t.Error("Decode succeeded without a result")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	awsapi "github.com/coreos/mantle/platform/api/aws"
+	doapi "github.com/coreos/mantle/platform/api/do"
+	esxapi "github.com/coreos/mantle/platform/api/esx"
+	gcloudapi "github.com/coreos/mantle/platform/api/gcloud"
+)
+
+// Values for QuotaCheck.
+const (
+	QuotaCheckOff  = "off"  // don't estimate or check quotas
+	QuotaCheckWarn = "warn" // warn if the run may exceed the quota
+	QuotaCheckFail = "fail" // refuse to run if it may exceed the quota
+)
+
+// estimatedMachineLifetime is the assumed lifetime of a test machine,
+// used to estimate instance-hours.
+const estimatedMachineLifetime = 10 * time.Minute
+
+// ResourceEstimate is a rough estimate of the machines a run will use.
+type ResourceEstimate struct {
+	Tests int
+	// Machines is the total number of machines created by the run.
+	Machines int
+	// Peak is the largest number of machines running at once.
+	Peak int
+	// InstanceHours is the approximate total machine runtime.
+	InstanceHours float64
+}
+
+// EstimateResources estimates the machines needed to run tests with the
+// given parallelism.  Tests without a ClusterSize create their own
+// machines and are counted as needing one.  Tests sharing a fixture
+// share its cluster, which is counted once.  If probe is set, a machine
+// is first started on its own to check the OS version, which adds to
+// the total but not to the peak.
+func EstimateResources(tests map[string]*register.Test, parallel int, probe bool) ResourceEstimate {
+	if parallel < 1 {
+		parallel = 1
+	}
+	sizes := make([]int, 0, len(tests))
+	fixtures := make(map[string]bool)
+	for _, t := range tests {
+		size := t.ClusterSize
+		if t.Fixture != "" {
+			if fixtures[t.Fixture] {
+				continue
+			}
+			fixtures[t.Fixture] = true
+			size = 0
+			if f, ok := register.Fixtures[t.Fixture]; ok {
+				size = f.ClusterSize
+			}
+		}
+		if size < 1 {
+			size = 1
+		}
+		sizes = append(sizes, size)
+	}
+	// at worst, the largest clusters all run at the same time
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	est := ResourceEstimate{Tests: len(tests)}
+	for i, size := range sizes {
+		est.Machines += size
+		if i < parallel {
+			est.Peak += size
+		}
+	}
+	if probe {
+		est.Machines++
+		if est.Peak < 1 {
+			est.Peak = 1
+		}
+	}
+	est.InstanceHours = float64(est.Machines) * estimatedMachineLifetime.Hours()
+	return est
+}
+
+// instanceQuota returns the instance quota of the account used on
+// pltfrm, or false if the platform can't report one.  It doesn't need a
+// flight, so it runs before anything is created.
+func instanceQuota(pltfrm string) (platform.InstanceQuota, bool, error) {
+	var quota platform.InstanceQuota
+	var err error
+	switch pltfrm {
+	case "aws":
+		var api *awsapi.API
+		if api, err = awsapi.New(&AWSOptions); err == nil {
+			quota, err = api.InstanceQuota()
+		}
+	case "do":
+		var api *doapi.API
+		if api, err = doapi.New(&DOOptions); err == nil {
+			quota, err = api.InstanceQuota(context.TODO())
+		}
+	case "esx":
+		var api *esxapi.API
+		if api, err = esxapi.New(&ESXOptions); err == nil {
+			quota, err = api.InstanceQuota()
+		}
+	case "gce":
+		var api *gcloudapi.API
+		if api, err = gcloudapi.New(&GCEOptions); err == nil {
+			quota, err = api.InstanceQuota()
+		}
+	default:
+		return quota, false, nil
+	}
+	return quota, true, err
+}
+
+// checkQuota reports the estimated resource usage and compares the
+// peak against pltfrm's instance quota, if the platform has one.  It
+// returns an error only if QuotaCheck is QuotaCheckFail and the quota
+// would be exceeded.
+func checkQuota(pltfrm string, est ResourceEstimate) error {
+	if QuotaCheck == QuotaCheckOff {
+		return nil
+	}
+
+	plog.Noticef("Estimated usage: %d tests, %d machines, at most %d at once, ~%.1f instance-hours",
+		est.Tests, est.Machines, est.Peak, est.InstanceHours)
+
+	quota, ok, err := instanceQuota(pltfrm)
+	if err != nil {
+		plog.Warningf("Couldn't check %s instance quota: %v", pltfrm, err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	plog.Infof("Instance quota: %v", quota)
+	if est.Peak <= quota.Available() {
+		return nil
+	}
+
+	msg := fmt.Sprintf("run may need %d machines at once but only %d are available (%v)", est.Peak, quota.Available(), quota)
+	if QuotaCheck == QuotaCheckFail {
+		return fmt.Errorf("%s; reduce --parallel or use --quota-check=%s", msg, QuotaCheckWarn)
+	}
+	plog.Warningf("%s; tests may fail to create machines", msg)
+	return nil
+}
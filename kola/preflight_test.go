@@ -0,0 +1,112 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coreos/mantle/kola/register"
+)
+
+func TestEstimateResources(t *testing.T) {
+	defer delete(register.Fixtures, "test.estimate")
+	register.RegisterFixture(&register.Fixture{
+		Name:        "test.estimate",
+		ClusterSize: 3,
+	})
+
+	for _, tt := range []struct {
+		name     string
+		tests    []register.Test
+		parallel int
+		probe    bool
+		machines int
+		peak     int
+	}{
+		{
+			name:     "none",
+			parallel: 4,
+		},
+		{
+			name:     "own machines count as one",
+			tests:    []register.Test{{ClusterSize: 0}, {ClusterSize: 0}},
+			parallel: 4,
+			machines: 2,
+			peak:     2,
+		},
+		{
+			name:     "largest clusters at once",
+			tests:    []register.Test{{ClusterSize: 1}, {ClusterSize: 3}, {ClusterSize: 2}},
+			parallel: 2,
+			machines: 6,
+			peak:     5,
+		},
+		{
+			name:     "no parallelism",
+			tests:    []register.Test{{ClusterSize: 1}, {ClusterSize: 3}},
+			parallel: 0,
+			machines: 4,
+			peak:     3,
+		},
+		{
+			name: "fixture counted once",
+			tests: []register.Test{
+				{Fixture: "test.estimate"},
+				{Fixture: "test.estimate"},
+				{Fixture: "test.estimate"},
+				{ClusterSize: 1},
+			},
+			parallel: 4,
+			machines: 4,
+			peak:     4,
+		},
+		{
+			name:     "probe adds to the total",
+			tests:    []register.Test{{ClusterSize: 1}, {ClusterSize: 3}},
+			parallel: 4,
+			probe:    true,
+			machines: 5,
+			peak:     4,
+		},
+		{
+			name:     "probe alone",
+			parallel: 4,
+			probe:    true,
+			machines: 1,
+			peak:     1,
+		},
+		{
+			name:     "unknown fixture",
+			tests:    []register.Test{{Fixture: "test.missing"}, {Fixture: "test.missing"}},
+			parallel: 4,
+			machines: 1,
+			peak:     1,
+		},
+	} {
+		tests := make(map[string]*register.Test)
+		for i := range tt.tests {
+			tests[fmt.Sprint(i)] = &tt.tests[i]
+		}
+		est := EstimateResources(tests, tt.parallel, tt.probe)
+		if est.Tests != len(tt.tests) || est.Machines != tt.machines || est.Peak != tt.peak {
+			t.Errorf("%s: got %+v, expected %d tests, %d machines, peak %d",
+				tt.name, est, len(tt.tests), tt.machines, tt.peak)
+		}
+		if hours := float64(tt.machines) * estimatedMachineLifetime.Hours(); est.InstanceHours != hours {
+			t.Errorf("%s: got %v instance-hours, expected %v", tt.name, est.InstanceHours, hours)
+		}
+	}
+}
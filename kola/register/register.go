@@ -20,6 +20,8 @@ import (
 	"github.com/coreos/go-semver/semver"
 
 	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/native"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
@@ -30,15 +32,7 @@ const (
 	NoSSHKeyInMetadata                 // don't add SSH key to platform metadata
 	NoEmergencyShellCheck              // don't check console output for emergency shell invocation
 	NoEnableSelinux                    // don't enable selinux when starting or rebooting a machine
-	RequiresInternetAccess             // run the test only if the platform supports Internet access
-)
-
-var (
-	// platforms that have no Internet access
-	PlatformsNoInternet = []string{
-		"qemu",
-		"qemu-unpriv",
-	}
+	RequiresInternetAccess             // deprecated: require platform.CapInternet instead
 )
 
 // Test provides the main test abstraction for kola. The run function is
@@ -49,6 +43,7 @@ type Test struct {
 	Name             string // should be unique
 	Run              func(cluster.TestCluster)
 	NativeFuncs      map[string]func() error
+	NativeJSONFuncs  map[string]native.Func // native functions with arguments and results; see TestCluster.RunNativeJSON
 	UserData         *conf.UserData
 	UserDataV3       *conf.UserData
 	ClusterSize      int
@@ -58,11 +53,38 @@ type Test struct {
 	ExcludeDistros   []string // blacklist of distributions to ignore -- defaults to none
 	Architectures    []string // whitelist of machine architectures supported -- defaults to all
 	Flags            []Flag   // special-case options for this test
+	Tags             []string // labels for selecting tests, such as TagSmoke
+
+	// Fixture names a registered Fixture whose cluster the test runs
+	// on instead of creating its own.  ClusterSize and UserData are
+	// then ignored, and the test must leave the machines usable by the
+	// fixture's other tests.
+	Fixture string
+
+	// DependsOn names tests which must pass before this one runs.  If
+	// one of them fails or is skipped, this test is skipped.  Tests
+	// which aren't part of the run are ignored.
+	DependsOn []string
+
+	// Requires lists the platform capabilities the test needs; it is
+	// skipped on platforms which lack any of them.  Prefer it to
+	// naming platforms in Platforms and ExcludePlatforms.
+	Requires []platform.Capability
 
 	// FailFast skips any sub-test that occurs after a sub-test has
 	// failed.
 	FailFast bool
 
+	// Iterations makes the test a benchmark which runs Run that many
+	// times on the same cluster.  Each measurement it reports with
+	// ReportMetric is summarized over the iterations.
+	Iterations int
+
+	// ConfigDelivery selects how UserData is passed to machines.  It is
+	// only supported on the qemu platforms; tests which set it are
+	// skipped elsewhere.
+	ConfigDelivery platform.ConfigDelivery
+
 	// MinVersion prevents the test from executing on CoreOS machines
 	// less than MinVersion. This will be ignored if the name fully
 	// matches without globbing.
@@ -74,9 +96,26 @@ type Test struct {
 	EndVersion semver.Version
 }
 
+// Fixture is a cluster shared by several tests.  It is set up when the
+// first of its tests runs and destroyed after the last one finishes.
+type Fixture struct {
+	Name        string // should be unique
+	ClusterSize int
+	UserData    *conf.UserData
+	UserDataV3  *conf.UserData
+
+	// Setup prepares the cluster after its machines have booted.  If
+	// it fails, the test which set up the fixture fails and the
+	// fixture's other tests are skipped.
+	Setup func(platform.Cluster) error
+}
+
 // Registered tests live here. Mapping of names to tests.
 var Tests = map[string]*Test{}
 
+// Registered fixtures live here. Mapping of names to fixtures.
+var Fixtures = map[string]*Fixture{}
+
 // Register is usually called in init() functions and is how kola test
 // harnesses knows which tests it can choose from. Panics if existing
 // name is registered
@@ -93,6 +132,15 @@ func Register(t *Test) {
 	Tests[t.Name] = t
 }
 
+// RegisterFixture makes a fixture available to tests. Panics if
+// existing name is registered
+func RegisterFixture(f *Fixture) {
+	if _, ok := Fixtures[f.Name]; ok {
+		panic(fmt.Sprintf("fixture %v already registered", f.Name))
+	}
+	Fixtures[f.Name] = f
+}
+
 func (t *Test) HasFlag(flag Flag) bool {
 	for _, f := range t.Flags {
 		if f == flag {
@@ -101,3 +149,13 @@ func (t *Test) HasFlag(flag Flag) bool {
 	}
 	return false
 }
+
+// Requirements returns the platform capabilities the test needs,
+// including those implied by its flags.
+func (t *Test) Requirements() []platform.Capability {
+	reqs := t.Requires
+	if t.HasFlag(RequiresInternetAccess) {
+		reqs = append([]platform.Capability{platform.CapInternet}, reqs...)
+	}
+	return reqs
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/mantle/platform"
+)
+
+func TestRequirements(t *testing.T) {
+	for _, tt := range []struct {
+		test     Test
+		expected []platform.Capability
+	}{
+		{Test{}, nil},
+		{
+			Test{Requires: []platform.Capability{platform.CapMultipleDisks}},
+			[]platform.Capability{platform.CapMultipleDisks},
+		},
+		{
+			Test{Flags: []Flag{RequiresInternetAccess}},
+			[]platform.Capability{platform.CapInternet},
+		},
+		{
+			Test{
+				Flags:    []Flag{NoSSHKeyInUserData, RequiresInternetAccess},
+				Requires: []platform.Capability{platform.CapNetworkOnline},
+			},
+			[]platform.Capability{platform.CapInternet, platform.CapNetworkOnline},
+		},
+	} {
+		if reqs := tt.test.Requirements(); !reflect.DeepEqual(reqs, tt.expected) {
+			t.Errorf("%+v: got requirements %v, expected %v", tt.test, reqs, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Common test tags.
+const (
+	TagSmoke       = "smoke"       // quick checks that the OS works at all
+	TagSlow        = "slow"        // takes many minutes or reboots repeatedly
+	TagNetwork     = "network"     // exercises networking
+	TagStorage     = "storage"     // exercises disks and filesystems
+	TagDestructive = "destructive" // breaks the machine on purpose
+	TagBenchmark   = "benchmark"   // reports performance metrics; opt-in
+)
+
+// TagExpr selects tests by their tags.
+type TagExpr interface {
+	Match(tags []string) bool
+}
+
+type tagName string
+
+func (e tagName) Match(tags []string) bool {
+	for _, t := range tags {
+		if t == string(e) {
+			return true
+		}
+	}
+	return false
+}
+
+type tagNot struct{ e TagExpr }
+
+func (e tagNot) Match(tags []string) bool { return !e.e.Match(tags) }
+
+type tagAnd struct{ l, r TagExpr }
+
+func (e tagAnd) Match(tags []string) bool { return e.l.Match(tags) && e.r.Match(tags) }
+
+type tagOr struct{ l, r TagExpr }
+
+func (e tagOr) Match(tags []string) bool { return e.l.Match(tags) || e.r.Match(tags) }
+
+type tagAll struct{}
+
+func (tagAll) Match(tags []string) bool { return true }
+
+// ParseTagExpr parses a boolean expression over tag names, such as
+// "smoke && !slow" or "(storage || network) && !destructive".  !
+// binds tightest, then &&, then ||.  An empty expression matches every
+// test.
+func ParseTagExpr(s string) (TagExpr, error) {
+	p := &tagParser{tokens: tokenizeTags(s)}
+	if len(p.tokens) == 0 {
+		return tagAll{}, nil
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("parsing tag expression %q: %v", s, err)
+	}
+	if tok := p.next(); tok != "" {
+		return nil, fmt.Errorf("parsing tag expression %q: unexpected %q", s, tok)
+	}
+	return e, nil
+}
+
+// MatchesTags reports whether the test's tags satisfy e.
+func (t *Test) MatchesTags(e TagExpr) bool {
+	return e.Match(t.Tags)
+}
+
+// SelectsTag reports whether e explicitly asks for tests with tag, by
+// naming it other than under a !.  Tests with opt-in tags, such as
+// benchmarks, only run when selected this way.
+func SelectsTag(e TagExpr, tag string) bool {
+	return selectsTag(e, tag, false)
+}
+
+func selectsTag(e TagExpr, tag string, negated bool) bool {
+	switch e := e.(type) {
+	case tagName:
+		return !negated && string(e) == tag
+	case tagNot:
+		return selectsTag(e.e, tag, !negated)
+	case tagAnd:
+		return selectsTag(e.l, tag, negated) || selectsTag(e.r, tag, negated)
+	case tagOr:
+		return selectsTag(e.l, tag, negated) || selectsTag(e.r, tag, negated)
+	}
+	return false
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.", r)
+}
+
+func tokenizeTags(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == ' ' || s[i] == '\t':
+			i++
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||"):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		case isTagRune(rune(s[i])):
+			j := i
+			for j < len(s) && isTagRune(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			tokens = append(tokens, s[i:i+1])
+			i++
+		}
+	}
+	return tokens
+}
+
+type tagParser struct {
+	tokens []string
+}
+
+func (p *tagParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *tagParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *tagParser) or() (TagExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = tagOr{l, r}
+	}
+	return l, nil
+}
+
+func (p *tagParser) and() (TagExpr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = tagAnd{l, r}
+	}
+	return l, nil
+}
+
+func (p *tagParser) unary() (TagExpr, error) {
+	switch tok := p.next(); {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "!":
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{e}, nil
+	case tok == "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return e, nil
+	case isTagRune(rune(tok[0])):
+		return tagName(tok), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+}
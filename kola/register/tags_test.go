@@ -0,0 +1,79 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"testing"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	for _, tt := range []struct {
+		expr  string
+		tags  []string
+		match bool
+	}{
+		{"", nil, true},
+		{"smoke", []string{"smoke"}, true},
+		{"smoke", []string{"slow"}, false},
+		{"smoke && !slow", []string{"smoke"}, true},
+		{"smoke && !slow", []string{"smoke", "slow"}, false},
+		{"storage || network", []string{"network"}, true},
+		{"storage || network", []string{"smoke"}, false},
+		{"storage || network && slow", []string{"storage"}, true},
+		{"(storage || network) && slow", []string{"storage"}, false},
+		{"!!smoke", []string{"smoke"}, true},
+		{"!(smoke&&slow)", []string{"smoke"}, true},
+	} {
+		e, err := ParseTagExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if match := e.Match(tt.tags); match != tt.match {
+			t.Errorf("%q with tags %v: got %v, expected %v", tt.expr, tt.tags, match, tt.match)
+		}
+	}
+
+	for _, expr := range []string{"&&", "smoke &&", "smoke slow", "(smoke", "smoke)", "smoke & slow", "!"} {
+		if _, err := ParseTagExpr(expr); err == nil {
+			t.Errorf("%q: parsed invalid expression", expr)
+		}
+	}
+}
+
+func TestSelectsTag(t *testing.T) {
+	for _, tt := range []struct {
+		expr    string
+		selects bool
+	}{
+		{"", false},
+		{"smoke", false},
+		{"benchmark", true},
+		{"smoke || benchmark", true},
+		{"benchmark && !slow", true},
+		{"!benchmark", false},
+		{"!(smoke || benchmark)", false},
+		{"!!benchmark", true},
+	} {
+		e, err := ParseTagExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if selects := SelectsTag(e, TagBenchmark); selects != tt.selects {
+			t.Errorf("%q: got %v, expected %v", tt.expr, selects, tt.selects)
+		}
+	}
+}
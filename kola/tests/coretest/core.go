@@ -2,6 +2,7 @@ package coretest
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,7 +11,9 @@ import (
 
 	"github.com/pborman/uuid"
 
+	"github.com/coreos/mantle/kola/native"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 )
 
 const (
@@ -46,6 +49,7 @@ var offServices = []string{
 func init() {
 	register.Register(&register.Test{
 		Name:        "cl.basic",
+		Tags:        []string{register.TagSmoke},
 		Run:         LocalTests,
 		ClusterSize: 1,
 		NativeFuncs: map[string]func() error{
@@ -55,12 +59,14 @@ func init() {
 			"DbusPerms":        TestDbusPerms,
 			"Symlink":          TestSymlinkResolvConf,
 			"UpdateEngineKeys": TestInstalledUpdateEngineRsaKeys,
-			"ServicesActive":   TestServicesActive,
 			"ReadOnly":         TestReadOnlyFs,
 			"RandomUUID":       TestFsRandomUUID,
 			"Useradd":          TestUseradd,
 			"MachineID":        TestMachineID,
 		},
+		NativeJSONFuncs: map[string]native.Func{
+			"ServicesActive": ServiceStates,
+		},
 		Distros: []string{"cl"},
 	})
 
@@ -69,7 +75,7 @@ func init() {
 		Name:        "cl.internet",
 		Run:         InternetTests,
 		ClusterSize: 1,
-		Flags:       []register.Flag{register.RequiresInternetAccess},
+		Requires:    []platform.Capability{platform.CapInternet},
 		NativeFuncs: map[string]func() error{
 			"UpdateEngine": TestUpdateEngine,
 			"DockerPing":   TestDockerPing,
@@ -233,13 +239,36 @@ func TestInstalledUpdateEngineRsaKeys() error {
 	}
 }
 
-func TestServicesActive() error {
-	return servicesActive([]string{
-		"multi-user.target",
-		"docker.socket",
-		"systemd-timesyncd.service",
-		"update-engine.service",
-	})
+// units ServicesActive expects active
+var activeServices = []string{
+	"multi-user.target",
+	"docker.socket",
+	"systemd-timesyncd.service",
+	"update-engine.service",
+}
+
+type serviceStatesArgs struct {
+	Units []string `json:"units"`
+}
+
+// ServiceStates returns the ActiveState of each of the units in args.
+func ServiceStates(log *native.Logger, args json.RawMessage) (interface{}, error) {
+	var a serviceStatesArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	states := make(map[string]string)
+	for _, unit := range a.Units {
+		// is-active exits non-zero for inactive units, but still
+		// prints the state
+		out, err := exec.Command("systemctl", "is-active", unit).Output()
+		state := strings.TrimSpace(string(out))
+		if state == "" {
+			return nil, fmt.Errorf("systemctl is-active %s: %v", unit, err)
+		}
+		states[unit] = state
+	}
+	return states, nil
 }
 
 func TestServicesActiveCoreOS() error {
@@ -10,6 +10,16 @@ func LocalTests(c cluster.TestCluster) {
 	for _, name := range tests {
 		c.RunNative(name, c.Machines()[0])
 	}
+
+	var states map[string]string
+	args := serviceStatesArgs{Units: activeServices}
+	if c.RunNativeJSON("ServicesActive", c.Machines()[0], args, &states) {
+		for _, unit := range activeServices {
+			if states[unit] != "active" {
+				c.Errorf("%s is %q, not active", unit, states[unit])
+			}
+		}
+	}
 }
 
 // run internet based tests
@@ -58,10 +58,10 @@ func init() {
 		Run:         dockerNetwork,
 		ClusterSize: 2,
 		Name:        "docker.network",
+		Tags:        []string{register.TagNetwork},
 		Distros:     []string{"cl"},
 
-		// qemu-unpriv machines cannot communicate
-		ExcludePlatforms: []string{"qemu-unpriv"},
+		Requires: []platform.Capability{platform.CapPrivateNetwork},
 	})
 	register.Register(&register.Test{
 		Run:         dockerOldClient,
@@ -100,8 +100,7 @@ passwd:
   users:
   - name: dockremap`),
 
-		// qemu-unpriv machines cannot communicate
-		ExcludePlatforms: []string{"qemu-unpriv"},
+		Requires: []platform.Capability{platform.CapPrivateNetwork},
 	})
 
 	// This test covers all functionality that should be quick to run and can be
@@ -214,10 +213,10 @@ systemd:
      enable: true`),
 
 		// https://github.com/coreos/mantle/issues/999
-		// On the qemu-unpriv platform the DHCP provides no data, pre-systemd 241 the DHCP server sending
+		// On platforms whose DHCP provides no data, pre-systemd 241 the DHCP server sending
 		// no routes to the link to spin in the configuring state. docker.service pulls in the network-online
 		// target which causes the basic machine checks to fail
-		ExcludePlatforms: []string{"qemu-unpriv"},
+		Requires: []platform.Capability{platform.CapNetworkOnline},
 	})
 }
 
@@ -52,7 +52,7 @@ write_files:
 		Distros: []string{"cl"},
 		// tcsd.service can't be effectively disabled from
 		// cloud-config on Packet
-		ExcludePlatforms: []string{"qemu-unpriv", "packet"},
+		ExcludePlatforms: []string{"qemu-unpriv", "libvirt", "packet"},
 	})
 }
 
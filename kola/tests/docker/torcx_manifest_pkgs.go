@@ -33,7 +33,7 @@ func init() {
 		Run:         dockerTorcxManifestPkgs,
 		ClusterSize: 0,
 		Name:        "docker.torcx-manifest-pkgs",
-		Flags:       []register.Flag{register.RequiresInternetAccess}, // Downloads torcx packages
+		Requires:    []platform.Capability{platform.CapInternet}, // Downloads torcx packages
 		// https://github.com/coreos/bugs/issues/2205 for DO
 		ExcludePlatforms: []string{"do"},
 		Distros:          []string{"cl"},
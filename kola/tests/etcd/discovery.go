@@ -21,24 +21,31 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
 var plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "kola/tests/etcd")
 
 func init() {
-	register.Register(&register.Test{
-		Run:         Discovery,
+	// a three member cluster shared by the tests which leave it intact
+	register.RegisterFixture(&register.Fixture{
+		Name:        "cl.etcd-member.cluster",
 		ClusterSize: 3,
-		Name:        "cl.etcd-member.discovery",
 		UserData: conf.ContainerLinuxConfig(`etcd:
   listen_client_urls:          http://0.0.0.0:2379
   advertise_client_urls:       http://{PRIVATE_IPV4}:2379
   listen_peer_urls:            http://{PRIVATE_IPV4}:2380
   initial_advertise_peer_urls: http://{PRIVATE_IPV4}:2380
   discovery:                   $discovery`),
-		Flags:   []register.Flag{register.RequiresInternetAccess}, // etcd-member requires networking
-		Distros: []string{"cl"},
+	})
+
+	register.Register(&register.Test{
+		Run:      Discovery,
+		Name:     "cl.etcd-member.discovery",
+		Fixture:  "cl.etcd-member.cluster",
+		Requires: []platform.Capability{platform.CapInternet}, // etcd-member requires networking
+		Distros:  []string{"cl"},
 	})
 
 	register.Register(&register.Test{
@@ -54,8 +61,8 @@ etcd:
   initial_advertise_peer_urls: http://{PRIVATE_IPV4}:2380
   discovery:                   $discovery
 `),
-		Flags:            []register.Flag{register.RequiresInternetAccess}, // etcd-member requires networking
-		ExcludePlatforms: []string{"esx"},                                  // etcd-member requires ct rendering
+		Requires:         []platform.Capability{platform.CapInternet}, // etcd-member requires networking
+		ExcludePlatforms: []string{"esx"},                             // etcd-member requires ct rendering
 		Distros:          []string{"cl"},
 	})
 
@@ -73,9 +80,39 @@ etcd:
   listen_peer_urls:            http://0.0.0.0:2380
   initial_advertise_peer_urls: http://127.0.0.1:2380
 `),
-		Flags:   []register.Flag{register.RequiresInternetAccess}, // networking to download etcd image
-		Distros: []string{"cl"},
+		Requires: []platform.Capability{platform.CapInternet}, // networking to download etcd image
+		Distros:  []string{"cl"},
 	})
+
+	register.Register(&register.Test{
+		Run:  etcdMemberList,
+		Name: "cl.etcd-member.member-list",
+		// reuse the discovery test's cluster once it's known to be
+		// healthy, instead of booting another
+		Fixture:   "cl.etcd-member.cluster",
+		DependsOn: []string{"cl.etcd-member.discovery"},
+		Requires:  []platform.Capability{platform.CapInternet}, // etcd-member requires networking
+		Distros:   []string{"cl"},
+	})
+}
+
+type etcdMemberOutput struct {
+	Members []struct {
+		ID         uint64
+		Name       string
+		PeerURLs   []string
+		ClientURLs []string
+	}
+}
+
+func getMembers(c cluster.TestCluster, m platform.Machine) etcdMemberOutput {
+	memberJson := c.MustSSH(m, `ETCDCTL_API=3 etcdctl member list --write-out=json`)
+
+	members := etcdMemberOutput{}
+	if err := json.Unmarshal(memberJson, &members); err != nil {
+		c.Fatalf("could not unmarshal %s: %s", memberJson, err)
+	}
+	return members
 }
 
 func Discovery(c cluster.TestCluster) {
@@ -165,21 +202,7 @@ EOF
 func etcdmemberEtcdctlV3(c cluster.TestCluster) {
 	m := c.Machines()[0]
 
-	type etcdMemberOutput struct {
-		Members []struct {
-			ID         uint64
-			Name       string
-			PeerURLs   []string
-			ClientURLs []string
-		}
-	}
-	memberJson := c.MustSSH(m, `ETCDCTL_API=3 etcdctl member list --write-out=json`)
-
-	members := etcdMemberOutput{}
-	if err := json.Unmarshal(memberJson, &members); err != nil {
-		c.Fatalf("could not unmarshal %s: %s", memberJson, err)
-	}
-
+	members := getMembers(c, m)
 	if len(members.Members) != len(c.Machines()) {
 		c.Fatalf("expected %v members; only got %v", len(c.Machines()), len(members.Members))
 	}
@@ -205,3 +228,28 @@ func etcdmemberEtcdctlV3(c cluster.TestCluster) {
 	sudo -E etcdctl snapshot status "${backup_to}/snapshot.db"
 `)
 }
+
+// etcdMemberList checks that every member of a discovered cluster agrees on
+// the membership.  It only reads from the cluster, so it can share the
+// discovery test's machines.
+func etcdMemberList(c cluster.TestCluster) {
+	machines := c.Machines()
+
+	var ids []uint64
+	for i, m := range machines {
+		members := getMembers(c, m)
+		if len(members.Members) != len(machines) {
+			c.Fatalf("%s: expected %v members; only got %v", m.ID(), len(machines), len(members.Members))
+		}
+		for j, member := range members.Members {
+			if len(member.ClientURLs) == 0 {
+				c.Fatalf("%s: member %x has no client URLs", m.ID(), member.ID)
+			}
+			if i == 0 {
+				ids = append(ids, member.ID)
+			} else if member.ID != ids[j] {
+				c.Fatalf("%s: member %d is %x, but %s reported %x", m.ID(), j, member.ID, machines[0].ID(), ids[j])
+			}
+		}
+	}
+}
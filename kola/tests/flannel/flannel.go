@@ -62,7 +62,7 @@ func init() {
 		Run:         udp,
 		ClusterSize: 3,
 		Name:        "cl.flannel.udp",
-		Flags:       []register.Flag{register.RequiresInternetAccess}, // requires networking between nodes
+		Requires:    []platform.Capability{platform.CapInternet, platform.CapPrivateNetwork}, // requires networking between nodes
 		Distros:     []string{"cl"},
 		UserData:    flannelConf.Subst("$type", "udp"),
 	})
@@ -71,7 +71,7 @@ func init() {
 		Run:         vxlan,
 		ClusterSize: 3,
 		Name:        "cl.flannel.vxlan",
-		Flags:       []register.Flag{register.RequiresInternetAccess}, // requires networking between nodes
+		Requires:    []platform.Capability{platform.CapInternet, platform.CapPrivateNetwork}, // requires networking between nodes
 		Distros:     []string{"cl"},
 		UserData:    flannelConf.Subst("$type", "vxlan"),
 	})
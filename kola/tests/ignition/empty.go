@@ -17,22 +17,24 @@ package ignition
 import (
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
 // These tests require the kola key to be passed to the instance via cloud
 // provider metadata since it will not be injected into the config. Platforms
-// where the cloud provider metadata system is not available have been excluded.
+// without a cloud provider metadata service are excluded.
 func init() {
 	// Tests for https://github.com/coreos/bugs/issues/1184
 	register.Register(&register.Test{
 		Name:        "cl.ignition.misc.empty",
 		Run:         empty,
 		ClusterSize: 1,
+		Requires:    []platform.Capability{platform.CapMetadataService},
 		// There's an unfixed Packet flake with tcsd.service, which
 		// we're working around by masking the unit.  But we can't
 		// do that if there's no Ignition config to mask with.
-		ExcludePlatforms: []string{"qemu", "esx", "packet"},
+		ExcludePlatforms: []string{"packet"},
 		Distros:          []string{"cl"},
 		UserData:         conf.Empty(),
 	})
@@ -41,7 +43,8 @@ func init() {
 		Name:             "cl.ignition.v1.noop",
 		Run:              empty,
 		ClusterSize:      1,
-		ExcludePlatforms: []string{"qemu", "esx", "openstack"},
+		Requires:         []platform.Capability{platform.CapMetadataService},
+		ExcludePlatforms: []string{"openstack"},
 		Distros:          []string{"cl"},
 		Flags:            []register.Flag{register.NoSSHKeyInUserData},
 		UserData:         conf.Ignition(`{"ignitionVersion": 1}`),
@@ -50,7 +53,8 @@ func init() {
 		Name:             "cl.ignition.v2.noop",
 		Run:              empty,
 		ClusterSize:      1,
-		ExcludePlatforms: []string{"qemu", "esx", "openstack"},
+		Requires:         []platform.Capability{platform.CapMetadataService},
+		ExcludePlatforms: []string{"openstack"},
 		Distros:          []string{"cl"},
 		Flags:            []register.Flag{register.NoSSHKeyInUserData},
 		UserData:         conf.Ignition(`{"ignition":{"version":"2.0.0"}}`),
@@ -81,7 +81,7 @@ func init() {
 		Name:        "coreos.ignition.resource.remote",
 		Run:         resourceRemote,
 		ClusterSize: 1,
-		Flags:       []register.Flag{register.RequiresInternetAccess},
+		Requires:    []platform.Capability{platform.CapInternet},
 		// https://github.com/coreos/bugs/issues/2205 for DO
 		ExcludePlatforms: []string{"do"},
 		UserData: conf.Ignition(`{
@@ -153,7 +153,7 @@ func init() {
 		Name:        "coreos.ignition.resource.s3.versioned",
 		Run:         resourceS3Versioned,
 		ClusterSize: 1,
-		Flags:       []register.Flag{register.RequiresInternetAccess},
+		Requires:    []platform.Capability{platform.CapInternet},
 		// https://github.com/coreos/bugs/issues/2205 for DO
 		ExcludePlatforms: []string{"do"},
 		MinVersion:       semver.Version{Major: 1995},
@@ -19,6 +19,7 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
@@ -78,6 +79,14 @@ func init() {
 		UserData:         configV2,
 		ExcludePlatforms: []string{"azure"},
 	})
+	// Exercise fetching the config from a remote URL.
+	register.Register(&register.Test{
+		Name:           "coreos.ignition.remote.sethostname",
+		Run:            setHostname,
+		ClusterSize:    1,
+		UserData:       configV2,
+		ConfigDelivery: platform.ConfigDeliveryHTTP,
+	})
 }
 
 func setHostname(c cluster.TestCluster) {
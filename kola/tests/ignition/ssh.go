@@ -16,6 +16,7 @@ package ignition
 
 import (
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
@@ -23,20 +24,20 @@ func init() {
 	// verify that SSH key injection works correctly through Ignition,
 	// without injecting via platform metadata
 	register.Register(&register.Test{
-		Name:             "cl.ignition.v1.ssh.key",
-		Run:              empty,
-		ClusterSize:      1,
-		ExcludePlatforms: []string{"qemu"}, // redundant on qemu
-		Flags:            []register.Flag{register.NoSSHKeyInMetadata},
-		UserData:         conf.Ignition(`{"ignitionVersion": 1}`),
-		Distros:          []string{"cl"},
+		Name:        "cl.ignition.v1.ssh.key",
+		Run:         empty,
+		ClusterSize: 1,
+		Requires:    []platform.Capability{platform.CapMetadataService}, // redundant without one
+		Flags:       []register.Flag{register.NoSSHKeyInMetadata},
+		UserData:    conf.Ignition(`{"ignitionVersion": 1}`),
+		Distros:     []string{"cl"},
 	})
 	register.Register(&register.Test{
-		Name:             "coreos.ignition.ssh.key",
-		Run:              empty,
-		ClusterSize:      1,
-		ExcludePlatforms: []string{"qemu"}, // redundant on qemu
-		Flags:            []register.Flag{register.NoSSHKeyInMetadata},
-		UserData:         conf.Ignition(`{"ignition":{"version":"2.0.0"}}`),
+		Name:        "coreos.ignition.ssh.key",
+		Run:         empty,
+		ClusterSize: 1,
+		Requires:    []platform.Capability{platform.CapMetadataService}, // redundant without one
+		Flags:       []register.Flag{register.NoSSHKeyInMetadata},
+		UserData:    conf.Ignition(`{"ignition":{"version":"2.0.0"}}`),
 	})
 }
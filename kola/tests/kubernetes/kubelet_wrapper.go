@@ -17,6 +17,7 @@ package kubernetes
 import (
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
@@ -52,8 +53,8 @@ systemd:
       [Install]
       WantedBy=multi-user.target
 `),
-		Flags:   []register.Flag{register.RequiresInternetAccess}, // network access for hyperkube
-		Distros: []string{"cl"},
+		Requires: []platform.Capability{platform.CapInternet}, // network access for hyperkube
+		Distros:  []string{"cl"},
 		// tcsd.service can't be effectively disabled from
 		// cloud-config on Packet, and setupCluster() uses one
 		ExcludePlatforms: []string{"packet"},
@@ -45,8 +45,8 @@ etcd:
   initial_advertise_peer_urls: http://{PRIVATE_IPV4}:2380
   listen_peer_urls:            http://{PRIVATE_IPV4}:2380
   discovery:                   $discovery`),
-		Flags:   []register.Flag{register.RequiresInternetAccess}, // etcdctl health-check requires networking
-		Distros: []string{"cl"},
+		Requires: []platform.Capability{platform.CapInternet}, // etcdctl health-check requires networking
+		Distros:  []string{"cl"},
 	})
 	register.Register(&register.Test{
 		Name:        "coreos.locksmith.reboot",
@@ -100,8 +100,8 @@ etcd:
     ]
   }
 }`),
-		Flags:   []register.Flag{register.RequiresInternetAccess}, // Networking required
-		Distros: []string{"cl"},
+		Requires: []platform.Capability{platform.CapInternet}, // Networking required
+		Distros:  []string{"cl"},
 	})
 }
 
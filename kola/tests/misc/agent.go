@@ -0,0 +1,96 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         KoletAgent,
+		ClusterSize: 1,
+		Name:        "coreos.kolet.agent",
+	})
+}
+
+// KoletAgent exercises each of the kolet agent's requests.
+func KoletAgent(c cluster.TestCluster) {
+	m := c.Machines()[0]
+	a := c.Agent(m)
+	defer func() {
+		if err := a.Close(); err != nil {
+			c.Errorf("%v", err)
+		}
+	}()
+
+	if out := c.MustExec(a, "id -u"); strings.TrimSpace(string(out)) != "0" {
+		c.Errorf("agent runs as user %q, not root", out)
+	}
+
+	const path = "/etc/kola-agent-test"
+	if err := a.WriteFile(path, []byte("agent\n"), 0644); err != nil {
+		c.Fatalf("writing %s: %v", path, err)
+	}
+	if data, err := a.ReadFile(path); err != nil {
+		c.Fatalf("reading %s: %v", path, err)
+	} else if string(data) != "agent\n" {
+		c.Errorf("read %q from %s", data, path)
+	}
+	if info, err := a.Stat(path); err != nil {
+		c.Fatalf("stat %s: %v", path, err)
+	} else if info.Size != 6 || info.Mode != 0644 {
+		c.Errorf("unexpected stat of %s: %+v", path, info)
+	}
+
+	cursor, err := a.JournalCursor()
+	if err != nil {
+		c.Fatalf("getting journal cursor: %v", err)
+	}
+	c.MustExec(a, "systemd-run --unit=kola-agent-test sh -c 'echo kola-agent-test; sleep infinity'")
+	if _, err := a.WaitForUnit("kola-agent-test.service", "active", time.Minute); err != nil {
+		c.Fatalf("waiting for unit: %v", err)
+	}
+	if status, err := a.UnitStatus("kola-agent-test.service"); err != nil {
+		c.Fatalf("unit status: %v", err)
+	} else if status.SubState != "running" {
+		c.Errorf("unit is %s, not running", status.SubState)
+	}
+
+	// the message may take a moment to reach the journal
+	for i := 0; ; i++ {
+		entries, err := a.Journal(cursor, "kola-agent-test.service")
+		if err != nil {
+			c.Fatalf("reading journal: %v", err)
+		}
+		found := false
+		for _, e := range entries {
+			if e.Message == "kola-agent-test" {
+				found = true
+			}
+		}
+		if found {
+			break
+		}
+		if i == 10 {
+			c.Fatalf("message not in journal after cursor")
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         BenchmarkBasic,
+		ClusterSize: 0,
+		Name:        "coreos.benchmark.basic",
+		Tags:        []string{register.TagBenchmark, register.TagSlow},
+		Iterations:  3,
+	})
+}
+
+// BenchmarkBasic measures how long a machine takes to boot until it
+// accepts SSH, and how fast it writes to its root disk.
+func BenchmarkBasic(c cluster.TestCluster) {
+	start := time.Now()
+	m, err := c.NewMachine(nil)
+	if err != nil {
+		c.Fatalf("creating machine: %v", err)
+	}
+	defer m.Destroy()
+	c.ReportMetric("boot", time.Since(start).Seconds(), "s")
+
+	// time the write in the machine so SSH latency isn't counted
+	const size = 256
+	out := c.MustSSH(m, fmt.Sprintf(`start=$(date +%%s.%%N)
+dd if=/dev/zero of=/var/tmp/benchmark bs=1M count=%d oflag=direct status=none
+end=$(date +%%s.%%N)
+rm /var/tmp/benchmark
+awk "BEGIN { print $end - $start }"`, size))
+	elapsed, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || elapsed <= 0 {
+		c.Fatalf("parsing write time %q: %v", out, err)
+	}
+	c.ReportMetric("disk-write", size/elapsed, "MB/s")
+}
@@ -19,23 +19,34 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
 func init() {
+	basicConfig := conf.CloudConfig(`#cloud-config
+hostname: "core1"
+write_files:
+  - path: "/foo"
+    content: bar`)
+
 	register.Register(&register.Test{
 		Run:         CloudInitBasic,
 		ClusterSize: 1,
 		Name:        "cl.cloudinit.basic",
-		UserData: conf.CloudConfig(`#cloud-config
-hostname: "core1"
-write_files:
-  - path: "/foo"
-    content: bar`),
-		Distros: []string{"cl"},
+		UserData:    basicConfig,
+		Distros:     []string{"cl"},
 		// tcsd.service can't be effectively disabled from
 		// cloud-config on Packet
-		ExcludePlatforms: []string{"qemu-unpriv", "packet"},
+		ExcludePlatforms: []string{"qemu-unpriv", "libvirt", "packet"},
+	})
+	register.Register(&register.Test{
+		Run:            CloudInitBasic,
+		ClusterSize:    1,
+		Name:           "cl.cloudinit.basic.configdrive",
+		UserData:       basicConfig,
+		Distros:        []string{"cl"},
+		ConfigDelivery: platform.ConfigDeliveryConfigDrive,
 	})
 	register.Register(&register.Test{
 		Run:         CloudInitScript,
@@ -53,7 +64,7 @@ chmod 600 ~core/.ssh/authorized_keys`),
 		Distros: []string{"cl"},
 		// tcsd.service can't be effectively disabled from
 		// script on Packet
-		ExcludePlatforms: []string{"qemu-unpriv", "packet"},
+		ExcludePlatforms: []string{"qemu-unpriv", "libvirt", "packet"},
 	})
 }
 
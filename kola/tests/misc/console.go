@@ -0,0 +1,67 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/util"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         InteractiveConsole,
+		ClusterSize: 0,
+		Name:        "coreos.console.interactive",
+		Requires:    []platform.Capability{platform.CapConsoleReboot},
+	})
+}
+
+// InteractiveConsole checks that the serial console can be read and
+// typed into while the machine runs.
+func InteractiveConsole(c cluster.TestCluster) {
+	m := newQEMUMachine(c, nil, platform.MachineOptions{})
+	console := c.Console(m)
+
+	c.MustSSH(m, "echo kola-console-output | sudo tee /dev/ttyS0")
+	if _, err := console.WaitFor("kola-console-output", time.Minute); err != nil {
+		c.Fatal(err)
+	}
+
+	// take the console from getty and read a line from it
+	c.MustSSH(m, "sudo systemctl stop serial-getty@ttyS0.service")
+	c.MustSSH(m, "sudo systemd-run --unit=kola-console sh -c 'head -n1 /dev/ttyS0 > /tmp/console-input'")
+	check := func() error {
+		if err := console.Send("kola-console-input"); err != nil {
+			return err
+		}
+		out, err := c.SSH(m, "cat /tmp/console-input")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(out), "kola-console-input") {
+			return fmt.Errorf("got %q", out)
+		}
+		return nil
+	}
+	if err := util.Retry(5, 2*time.Second, check); err != nil {
+		c.Fatalf("typing into console: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"fmt"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+	"github.com/coreos/mantle/platform/machine/qemu"
+	"github.com/coreos/mantle/platform/machine/unprivqemu"
+)
+
+// faultySector is far enough into the disk that nothing reads it at
+// boot.
+const faultySector = 65536
+
+func init() {
+	register.Register(&register.Test{
+		Run:         DiskSectorFault,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu", "qemu-unpriv"},
+		Name:        "coreos.disk.fault.sector",
+		Tags:        []string{register.TagStorage, register.TagDestructive},
+	})
+	register.Register(&register.Test{
+		Run:         DiskSurpriseRemoval,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu", "qemu-unpriv"},
+		Name:        "coreos.disk.fault.removal",
+		Tags:        []string{register.TagStorage, register.TagDestructive},
+	})
+}
+
+func newQEMUMachine(c cluster.TestCluster, userdata *conf.UserData, options platform.MachineOptions) platform.Machine {
+	var m platform.Machine
+	var err error
+	switch pc := c.Cluster.(type) {
+	case *qemu.Cluster:
+		m, err = pc.NewMachineWithOptions(userdata, options)
+	case *unprivqemu.Cluster:
+		m, err = pc.NewMachineWithOptions(userdata, options)
+	default:
+		c.Fatal("unknown cluster type")
+	}
+	if err != nil {
+		c.Fatal(err)
+	}
+	return m
+}
+
+func readSector(c cluster.TestCluster, m platform.Machine, disk string, sector int) error {
+	_, err := c.SSH(m, fmt.Sprintf("sudo dd if=/dev/disk/by-id/virtio-%s of=/dev/null bs=512 skip=%d count=1 iflag=direct", disk, sector))
+	return err
+}
+
+// DiskSectorFault checks that reads of a bad sector fail and other
+// reads succeed.
+func DiskSectorFault(c cluster.TestCluster) {
+	m := newQEMUMachine(c, nil, platform.MachineOptions{
+		AdditionalDisks: []platform.Disk{{
+			Size:       "64M",
+			DeviceOpts: []string{"serial=faulty"},
+			Fault: &platform.DiskFault{
+				Op:      platform.DiskFaultRead,
+				Sectors: []int64{faultySector},
+			},
+		}},
+	})
+
+	if err := readSector(c, m, "faulty", 0); err != nil {
+		c.Fatalf("reading good sector: %v", err)
+	}
+	if err := readSector(c, m, "faulty", faultySector); err == nil {
+		c.Fatal("reading bad sector succeeded")
+	}
+}
+
+// DiskSurpriseRemoval checks that I/O fails after a disk is pulled out
+// from under the machine.
+func DiskSurpriseRemoval(c cluster.TestCluster) {
+	m := newQEMUMachine(c, nil, platform.MachineOptions{
+		AdditionalDisks: []platform.Disk{{
+			ID:         "removable",
+			Size:       "64M",
+			DeviceOpts: []string{"serial=removable"},
+		}},
+	})
+	qm, ok := m.(platform.QEMUMachine)
+	if !ok {
+		c.Skip("machine can't be controlled through QMP")
+	}
+
+	if err := readSector(c, m, "removable", 0); err != nil {
+		c.Fatalf("reading disk: %v", err)
+	}
+	if err := qm.SurpriseRemoveDisk("removable"); err != nil {
+		c.Fatalf("removing disk: %v", err)
+	}
+	if err := readSector(c, m, "removable", 0); err == nil {
+		c.Fatal("reading removed disk succeeded")
+	}
+}
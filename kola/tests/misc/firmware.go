@@ -0,0 +1,67 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"strings"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:           SecureBoot,
+		ClusterSize:   0,
+		Platforms:     []string{"qemu", "qemu-unpriv"},
+		Architectures: []string{"amd64"},
+		Name:          "coreos.secureboot",
+	})
+	register.Register(&register.Test{
+		Run:           TPM,
+		ClusterSize:   0,
+		Platforms:     []string{"qemu", "qemu-unpriv"},
+		Architectures: []string{"amd64", "arm64"}, // no TPM device for s390x
+		Name:          "coreos.tpm",
+	})
+}
+
+// SecureBoot checks that the OS boots with Secure Boot enforced.
+func SecureBoot(c cluster.TestCluster) {
+	m := newQEMUMachine(c, nil, platform.MachineOptions{
+		Firmware: platform.FirmwareUEFISecure,
+	})
+
+	// the last byte of the variable is 1 if Secure Boot is enabled
+	out := c.MustSSH(m, "od -An -t u1 /sys/firmware/efi/efivars/SecureBoot-8be4df61-93ca-11d0-aa0d-00a0c904f2b3")
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 || fields[len(fields)-1] != "1" {
+		c.Fatalf("Secure Boot isn't enabled: %q", out)
+	}
+}
+
+// TPM checks that the OS finds a TPM 2.0 device.
+func TPM(c cluster.TestCluster) {
+	m := newQEMUMachine(c, nil, platform.MachineOptions{
+		SWTPM: true,
+	})
+
+	c.MustSSH(m, "test -c /dev/tpmrm0")
+	out := c.MustSSH(m, "cat /sys/class/tpm/tpm0/tpm_version_major")
+	if strings.TrimSpace(string(out)) != "2" {
+		c.Fatalf("expected TPM 2.0, found version %q", out)
+	}
+}
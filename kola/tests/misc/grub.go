@@ -155,7 +155,7 @@ func init() {
 		Distros:     []string{"cl"},
 		// tcsd.service can't be effectively disabled from
 		// cloud-config on Packet
-		ExcludePlatforms: []string{"qemu-unpriv", "packet"},
+		ExcludePlatforms: []string{"qemu-unpriv", "libvirt", "packet"},
 	})
 }
 
@@ -0,0 +1,149 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+	"github.com/coreos/mantle/platform/machine/qemu"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         MultiNIC,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu"},
+		Name:        "coreos.network.multinic",
+		Tags:        []string{register.TagNetwork},
+	})
+	register.Register(&register.Test{
+		Run:         MultiNICIPv6Only,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu"},
+		Name:        "coreos.network.multinic.ipv6only",
+		Tags:        []string{register.TagNetwork},
+	})
+	register.Register(&register.Test{
+		Run:         MultiNICVLAN,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu"},
+		Name:        "coreos.network.multinic.vlan",
+		Tags:        []string{register.TagNetwork},
+	})
+	register.Register(&register.Test{
+		Run:         MultiNICStatic,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu"},
+		Name:        "coreos.network.multinic.static",
+		Tags:        []string{register.TagNetwork},
+	})
+}
+
+// nicConfig returns a config which records the address of the machine's
+// first additional NIC, $nic1_ipv4 or $nic1_ipv6, in /etc/kola-nic1, and
+// writes the systemd-networkd files in networkd.
+func nicConfig(c cluster.TestCluster, addr string, networkd map[string]string) *conf.UserData {
+	files := map[string]string{"/etc/kola-nic1": addr}
+	for name, contents := range networkd {
+		files["/etc/systemd/network/"+name] = contents
+	}
+
+	var entries []string
+	for path, contents := range files {
+		source := "data:," + url.PathEscape(contents)
+		switch c.IgnitionVersion() {
+		case "v2":
+			entries = append(entries, fmt.Sprintf(`{"filesystem": "root", "path": %q, "mode": 420, "contents": {"source": %q}}`, path, source))
+		case "v3":
+			entries = append(entries, fmt.Sprintf(`{"path": %q, "mode": 420, "contents": {"source": %q}}`, path, source))
+		}
+	}
+	switch c.IgnitionVersion() {
+	case "v2":
+		return conf.Ignition(fmt.Sprintf(`{"ignition": {"version": "2.0.0"}, "storage": {"files": [%s]}}`, strings.Join(entries, ", ")))
+	case "v3":
+		return conf.Ignition(fmt.Sprintf(`{"ignition": {"version": "3.0.0"}, "storage": {"files": [%s]}}`, strings.Join(entries, ", ")))
+	}
+	c.Fatalf("unknown ignition version %q", c.IgnitionVersion())
+	return nil
+}
+
+// pingOverNIC creates two machines with nic, configured by userdata,
+// and checks that the first can ping the second's address in
+// /etc/kola-nic1, which isn't its primary address.
+func pingOverNIC(c cluster.TestCluster, userdata *conf.UserData, nic platform.NIC, ping string) {
+	qc, ok := c.Cluster.(*qemu.Cluster)
+	if !ok {
+		c.Fatal("test only works in qemu")
+	}
+
+	var machines []platform.Machine
+	for i := 0; i < 2; i++ {
+		m, err := qc.NewMachineWithOptions(userdata, platform.MachineOptions{
+			NICs: []platform.NIC{nic},
+		})
+		if err != nil {
+			c.Fatalf("creating machine: %v", err)
+		}
+		machines = append(machines, m)
+	}
+
+	addr := strings.TrimSpace(string(c.MustSSH(machines[1], "cat /etc/kola-nic1")))
+	if addr == "" || strings.HasPrefix(addr, "$") || addr == machines[1].PrivateIP() {
+		c.Fatalf("bad private segment address %q", addr)
+	}
+	// addresses configured after boot may take a moment to settle
+	c.MustSSH(machines[0], fmt.Sprintf("for i in $(seq 10); do %s -c 1 -W 2 %s && exit; sleep 1; done; exit 1", ping, addr))
+}
+
+// MultiNIC checks that machines with a second NIC on a private segment
+// can reach each other over it.
+func MultiNIC(c cluster.TestCluster) {
+	pingOverNIC(c, nicConfig(c, "$nic1_ipv4", nil), platform.NIC{Segment: "private"}, "ping")
+}
+
+// MultiNICIPv6Only checks that machines on an IPv6-only segment get
+// IPv6 addresses on it and reach each other.
+func MultiNICIPv6Only(c cluster.TestCluster) {
+	pingOverNIC(c, nicConfig(c, "$nic1_ipv6", nil), platform.NIC{Segment: "v6", IPv6Only: true}, "ping -6")
+}
+
+// MultiNICVLAN checks that machines configuring a VLAN interface on a
+// tagged segment get addresses on it and reach each other.
+func MultiNICVLAN(c cluster.TestCluster) {
+	const id = 42
+	networkd := map[string]string{
+		// the VLAN interface has the same MAC, so only match the NIC
+		"10-kola-nic1.network": "[Match]\nMACAddress=$nic1_mac\nType=ether\n\n[Network]\nLinkLocalAddressing=no\nVLAN=kola-vlan\n",
+		"10-kola-vlan.netdev":  fmt.Sprintf("[NetDev]\nName=kola-vlan\nKind=vlan\n\n[VLAN]\nId=%d\n", id),
+		"10-kola-vlan.network": "[Match]\nName=kola-vlan\n\n[Network]\nDHCP=yes\n",
+	}
+	pingOverNIC(c, nicConfig(c, "$nic1_ipv4", networkd), platform.NIC{Segment: "tagged", VLAN: id}, "ping")
+}
+
+// MultiNICStatic checks that machines assigning their own addresses to
+// NICs left out of DHCP reach each other.
+func MultiNICStatic(c cluster.TestCluster) {
+	networkd := map[string]string{
+		"10-kola-nic1.network": "[Match]\nMACAddress=$nic1_mac\n\n[Network]\nDHCP=no\nAddress=$nic1_ipv4/16\n",
+	}
+	pingOverNIC(c, nicConfig(c, "$nic1_ipv4", networkd), platform.NIC{Segment: "static", Static: true}, "ping")
+}
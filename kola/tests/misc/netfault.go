@@ -0,0 +1,101 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform/local"
+	"github.com/coreos/mantle/platform/machine/qemu"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         NetworkPartition,
+		ClusterSize: 2,
+		Platforms:   []string{"qemu"},
+		Name:        "coreos.network.fault.partition",
+		Tags:        []string{register.TagNetwork, register.TagDestructive},
+	})
+	register.Register(&register.Test{
+		Run:         NetworkLatency,
+		ClusterSize: 2,
+		Platforms:   []string{"qemu"},
+		Name:        "coreos.network.fault.latency",
+		Tags:        []string{register.TagNetwork, register.TagSlow},
+	})
+}
+
+// NetworkPartition checks that partitioned machines can't reach each
+// other until the partition heals.
+func NetworkPartition(c cluster.TestCluster) {
+	qc, ok := c.Cluster.(*qemu.Cluster)
+	if !ok {
+		c.Fatal("test only works in qemu")
+	}
+	m0, m1 := c.Machines()[0], c.Machines()[1]
+	ping := fmt.Sprintf("ping -c 1 -W 2 %s", m1.PrivateIP())
+
+	c.MustSSH(m0, ping)
+
+	if err := qc.Partition(m0, m1); err != nil {
+		c.Fatalf("partitioning machines: %v", err)
+	}
+	if _, err := c.SSH(m0, ping); err == nil {
+		c.Fatal("partitioned machine is reachable")
+	}
+
+	if err := qc.Heal(m0, m1); err != nil {
+		c.Fatalf("healing partition: %v", err)
+	}
+	c.MustSSH(m0, ping)
+}
+
+var pingTimePattern = regexp.MustCompile(`time=([0-9.]+) ms`)
+
+// NetworkLatency checks that link faults delay traffic.
+func NetworkLatency(c cluster.TestCluster) {
+	qc, ok := c.Cluster.(*qemu.Cluster)
+	if !ok {
+		c.Fatal("test only works in qemu")
+	}
+	m0, m1 := c.Machines()[0], c.Machines()[1]
+	const delay = 200 * time.Millisecond
+
+	if err := qc.SetLinkFault(m1, local.LinkFault{Delay: delay}); err != nil {
+		c.Fatalf("setting link fault: %v", err)
+	}
+	out := c.MustSSH(m0, fmt.Sprintf("ping -c 1 -W 5 %s", m1.PrivateIP()))
+	match := pingTimePattern.FindSubmatch(out)
+	if match == nil {
+		c.Fatalf("couldn't parse ping output: %q", out)
+	}
+	ms, err := strconv.ParseFloat(string(match[1]), 64)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if time.Duration(ms*float64(time.Millisecond)) < delay {
+		c.Fatalf("round trip took %vms, expected at least %v", ms, delay)
+	}
+
+	if err := qc.ClearLinkFault(m1); err != nil {
+		c.Fatalf("clearing link fault: %v", err)
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 	"github.com/coreos/mantle/util"
 )
@@ -39,16 +40,16 @@ func init() {
   units:
     - name: docker.service
       enabled: true`),
-		MinVersion:       semver.Version{Major: 1967},
-		ExcludePlatforms: []string{"qemu-unpriv"},
+		MinVersion: semver.Version{Major: 1967},
+		Requires:   []platform.Capability{platform.CapNetworkOnline},
 	})
 	register.Register(&register.Test{
-		Run:              NetworkListeners,
-		ClusterSize:      1,
-		Name:             "cl.network.listeners.legacy",
-		Distros:          []string{"cl"},
-		EndVersion:       semver.Version{Major: 1967},
-		ExcludePlatforms: []string{"qemu-unpriv"},
+		Run:         NetworkListeners,
+		ClusterSize: 1,
+		Name:        "cl.network.listeners.legacy",
+		Distros:     []string{"cl"},
+		EndVersion:  semver.Version{Major: 1967},
+		Requires:    []platform.Capability{platform.CapNetworkOnline},
 	})
 	register.Register(&register.Test{
 		Run:              NetworkInitramfsSecondBoot,
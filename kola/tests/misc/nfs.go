@@ -21,6 +21,7 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 	"github.com/coreos/mantle/util"
 )
@@ -56,8 +57,8 @@ func init() {
 
 		// Disabled on Azure because setting hostname
 		// is required at the instance creation level
-		// qemu-unpriv machines cannot communicate
-		ExcludePlatforms: []string{"azure", "qemu-unpriv"},
+		ExcludePlatforms: []string{"azure"},
+		Requires:         []platform.Capability{platform.CapPrivateNetwork},
 	})
 	// TODO: enable FCOS when FCCT exists
 	register.Register(&register.Test{
@@ -67,8 +68,8 @@ func init() {
 
 		// Disabled on Azure because setting hostname
 		// is required at the instance creation level
-		// qemu-unpriv machines cannot communicate
-		ExcludePlatforms: []string{"azure", "qemu-unpriv"},
+		ExcludePlatforms: []string{"azure"},
+		Requires:         []platform.Capability{platform.CapPrivateNetwork},
 	})
 }
 
@@ -21,22 +21,28 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/util"
 )
 
 func init() {
 	register.Register(&register.Test{
-		Run:              NTP,
-		ClusterSize:      0,
-		Name:             "linux.ntp",
-		Platforms:        []string{"qemu"},
-		ExcludePlatforms: []string{"qemu-unpriv"},
-		Distros:          []string{"cl"},
+		Run:         NTP,
+		ClusterSize: 0,
+		Name:        "linux.ntp",
+		Requires:    []platform.Capability{platform.CapNTPServer},
+		Distros:     []string{"cl"},
 	})
 }
 
 // Test that timesyncd starts using the local NTP server
 func NTP(c cluster.TestCluster) {
+	nc, ok := c.Cluster.(platform.NTPCluster)
+	if !ok {
+		c.Fatal("cluster has no NTP server")
+	}
+	server := nc.NTPServerIP()
+
 	m, err := c.NewMachine(nil)
 	if err != nil {
 		c.Fatalf("Cluster.NewMachine: %s", err)
@@ -44,7 +50,7 @@ func NTP(c cluster.TestCluster) {
 	defer m.Destroy()
 
 	out := c.MustSSH(m, "networkctl status eth0")
-	if !bytes.Contains(out, []byte("NTP: 10.0.0.1")) {
+	if !bytes.Contains(out, []byte("NTP: "+server)) {
 		c.Fatalf("Bad network config:\n%s", out)
 	}
 
@@ -54,8 +60,8 @@ func NTP(c cluster.TestCluster) {
 			return fmt.Errorf("systemctl: %v", err)
 		}
 
-		if !bytes.Contains(out, []byte(`Status: "Synchronized to time server for the first time 10.0.0.1:123 (10.0.0.1)."`)) && // systemd >= 241
-			!bytes.Contains(out, []byte(`Status: "Synchronized to time server 10.0.0.1:123 (10.0.0.1)."`)) {
+		if !bytes.Contains(out, []byte(fmt.Sprintf(`Status: "Synchronized to time server for the first time %s:123 (%s)."`, server, server))) && // systemd >= 241
+			!bytes.Contains(out, []byte(fmt.Sprintf(`Status: "Synchronized to time server %s:123 (%s)."`, server, server))) {
 			return fmt.Errorf("unexpected systemd-timesyncd status: %q", out)
 		}
 
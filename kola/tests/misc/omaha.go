@@ -22,18 +22,17 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
-	"github.com/coreos/mantle/platform/machine/qemu"
 )
 
 func init() {
 	register.Register(&register.Test{
-		Run:              OmahaPing,
-		ClusterSize:      0,
-		Name:             "cl.omaha.ping",
-		Platforms:        []string{"qemu"},
-		ExcludePlatforms: []string{"qemu-unpriv"},
-		Distros:          []string{"cl"},
+		Run:         OmahaPing,
+		ClusterSize: 0,
+		Name:        "cl.omaha.ping",
+		Requires:    []platform.Capability{platform.CapLocalOmaha},
+		Distros:     []string{"cl"},
 	})
 }
 
@@ -48,20 +47,18 @@ func (ps *pingServer) Ping(req *omaha.Request, app *omaha.AppRequest) {
 }
 
 func OmahaPing(c cluster.TestCluster) {
-	qc, ok := c.Cluster.(*qemu.Cluster)
+	oc, ok := c.Cluster.(platform.OmahaCluster)
 	if !ok {
-		c.Fatal("test only works in qemu")
+		c.Fatal("cluster has no Omaha server")
 	}
 
-	omahaserver := qc.LocalCluster.OmahaServer
-
 	svc := &pingServer{
 		ping: make(chan struct{}),
 	}
 
-	omahaserver.Updater = svc
+	oc.SetOmahaUpdater(svc)
 
-	hostport, err := qc.GetOmahaHostPort()
+	hostport, err := oc.GetOmahaHostPort()
 	if err != nil {
 		c.Fatalf("couldn't get Omaha server address: %v", err)
 	}
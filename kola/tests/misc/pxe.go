@@ -0,0 +1,106 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"bytes"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/machine/qemu"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         PXEDiskless,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu"},
+		Name:        "coreos.pxe.diskless",
+	})
+	register.Register(&register.Test{
+		Run:         PXEInstall,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu"},
+		Name:        "cl.install.pxe",
+		Requires:    []platform.Capability{platform.CapInternet},
+		Distros:     []string{"cl"},
+		Tags:        []string{register.TagSlow},
+	})
+}
+
+// PXEDiskless checks that a machine without a disk boots from the
+// network and runs its config.
+func PXEDiskless(c cluster.TestCluster) {
+	qc, ok := c.Cluster.(*qemu.Cluster)
+	if !ok {
+		c.Fatal("test only works in qemu")
+	}
+	if !qc.PXEAvailable() {
+		c.Skip("no PXE kernel and initramfs specified")
+	}
+
+	// the SSH keys in the config prove that it was fetched
+	m, err := qc.NewMachineWithOptions(nil, platform.MachineOptions{
+		Boot: platform.BootPXE,
+	})
+	if err != nil {
+		c.Fatalf("booting from the network: %v", err)
+	}
+
+	if _, err := c.SSH(m, "test -e /dev/disk/by-id/virtio-primary-disk"); err == nil {
+		c.Fatal("diskless machine has a disk")
+	}
+}
+
+// PXEInstall checks that a network-booted machine installs itself to
+// its blank disk with coreos-install, and that the installed system
+// uses the cloud-config given to coreos-install, as in
+// cl.install.cloudinit.
+func PXEInstall(c cluster.TestCluster) {
+	qc, ok := c.Cluster.(*qemu.Cluster)
+	if !ok {
+		c.Fatal("test only works in qemu")
+	}
+	if !qc.PXEAvailable() {
+		c.Skip("no PXE kernel and initramfs specified")
+	}
+
+	m, err := qc.NewMachineWithOptions(nil, platform.MachineOptions{
+		Boot: platform.BootPXEInstall,
+	})
+	if err != nil {
+		c.Fatalf("booting from the network: %v", err)
+	}
+
+	// reuse the live system's Ignition config, which holds the SSH
+	// keys, for the installed system
+	c.MustSSH(m, `set -e
+url=$(tr " " "\n" < /proc/cmdline | sed -n "s/^coreos.config.url=//p")
+curl -fsSo /tmp/config.ign "$url"
+printf "#cloud-config\nhostname: installed-from-pxe\n" > /tmp/cloud-config
+sudo coreos-install -d /dev/disk/by-id/virtio-primary-disk -i /tmp/config.ign -c /tmp/cloud-config`)
+
+	// the machine boots from the network only once
+	if err := m.Reboot(); err != nil {
+		c.Fatalf("rebooting into the installed system: %v", err)
+	}
+	if _, err := c.SSH(m, `[ "$(findmnt -no SOURCE /)" = "$(readlink -f /dev/disk/by-label/ROOT)" ]`); err != nil {
+		c.Fatalf("root isn't on the installed disk: %v", err)
+	}
+	if output := c.MustSSH(m, "hostname"); !bytes.Equal(output, []byte("installed-from-pxe")) {
+		c.Fatalf("hostname: %q", output)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/util"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         PowerButton,
+		ClusterSize: 1,
+		Name:        "coreos.qemu.powerbutton",
+		Requires:    []platform.Capability{platform.CapConsoleReboot},
+	})
+	register.Register(&register.Test{
+		Run:         HotplugDisk,
+		ClusterSize: 1,
+		Platforms:   []string{"qemu", "qemu-unpriv"},
+		Name:        "coreos.qemu.hotplug.disk",
+		Tags:        []string{register.TagStorage},
+	})
+}
+
+func qemuMachine(c cluster.TestCluster) platform.QEMUMachine {
+	qm, ok := c.Machines()[0].(platform.QEMUMachine)
+	if !ok {
+		c.Skip("machine can't be controlled through QMP")
+	}
+	return qm
+}
+
+// PowerButton checks that the machine shuts down cleanly when the ACPI
+// power button is pressed.
+func PowerButton(c cluster.TestCluster) {
+	qm := qemuMachine(c)
+
+	if err := qm.PowerButton(); err != nil {
+		c.Fatalf("pressing power button: %v", err)
+	}
+	if _, err := qm.WaitEvent("SHUTDOWN", 2*time.Minute); err != nil {
+		c.Fatalf("machine didn't shut down: %v", err)
+	}
+}
+
+// HotplugDisk checks that hot-plugged disks appear in and disappear from
+// the machine.
+func HotplugDisk(c cluster.TestCluster) {
+	qm := qemuMachine(c)
+	const id = "hotplug0"
+	check := fmt.Sprintf("test -b /dev/disk/by-id/virtio-%s", id)
+
+	if err := qm.HotplugDisk(id, "64M"); err != nil {
+		c.Fatalf("adding disk: %v", err)
+	}
+	err := util.Retry(10, 3*time.Second, func() error {
+		_, err := c.SSH(qm, check)
+		return err
+	})
+	if err != nil {
+		c.Fatalf("hot-plugged disk didn't appear: %v", err)
+	}
+
+	if err := qm.UnplugDisk(id); err != nil {
+		c.Fatalf("removing disk: %v", err)
+	}
+	if _, err := c.SSH(qm, check); err == nil {
+		c.Fatal("unplugged disk is still present")
+	}
+}
@@ -61,21 +61,23 @@ var (
 
 func init() {
 	register.Register(&register.Test{
-		// This test needs additional disks which is only supported on qemu since Ignition
-		// does not support deleting partitions without wiping the partition table and the
+		// This test needs additional disks since Ignition does not support
+		// deleting partitions without wiping the partition table and the
 		// disk doesn't have room for new partitions.
 		// TODO(ajeddeloh): change this to delete partition 9 and replace it with 9 and 10
 		// once Ignition supports it.
 		Run:         RootOnRaid,
 		ClusterSize: 0,
-		Platforms:   []string{"qemu"},
+		Requires:    []platform.Capability{platform.CapMultipleDisks},
 		Name:        "cl.disk.raid.root",
+		Tags:        []string{register.TagStorage},
 		Distros:     []string{"cl"},
 	})
 	register.Register(&register.Test{
 		Run:         DataOnRaid,
 		ClusterSize: 1,
 		Name:        "cl.disk.raid.data",
+		Tags:        []string{register.TagStorage},
 		UserData: conf.ContainerLinuxConfig(`storage:
   raid:
     - name: "DATA"
@@ -0,0 +1,76 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package misc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+)
+
+func init() {
+	register.Register(&register.Test{
+		Run:         SharedDir9p,
+		ClusterSize: 0,
+		Platforms:   []string{"qemu", "qemu-unpriv"},
+		Name:        "coreos.share.9p",
+		Tags:        []string{register.TagStorage},
+	})
+}
+
+// SharedDir9p checks that a host directory shared with 9p can be read,
+// and written only if it is shared read-write.
+func SharedDir9p(c cluster.TestCluster) {
+	ro, err := ioutil.TempDir(c.H.OutputDir(), "share-ro")
+	if err != nil {
+		c.Fatal(err)
+	}
+	rw, err := ioutil.TempDir(c.H.OutputDir(), "share-rw")
+	if err != nil {
+		c.Fatal(err)
+	}
+	// the guest's root doesn't own the directory
+	if err := os.Chmod(rw, 0777); err != nil {
+		c.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(ro, "hello"), []byte("kola\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+
+	m := newQEMUMachine(c, nil, platform.MachineOptions{
+		SharedDirs: []platform.SharedDir{
+			{HostPath: ro, GuestPath: "/var/mnt/ro", ReadOnly: true},
+			{HostPath: rw, GuestPath: "/var/mnt/rw"},
+		},
+	})
+
+	out := c.MustSSH(m, "cat /var/mnt/ro/hello")
+	if strings.TrimSpace(string(out)) != "kola" {
+		c.Fatalf("read %q from shared directory", out)
+	}
+	if _, err := c.SSH(m, "sudo touch /var/mnt/ro/written"); err == nil {
+		c.Fatal("wrote to read-only shared directory")
+	}
+
+	c.MustSSH(m, "echo guest | sudo tee /var/mnt/rw/written")
+	if data, err := ioutil.ReadFile(filepath.Join(rw, "written")); err != nil || strings.TrimSpace(string(data)) != "guest" {
+		c.Fatalf("host read %q, %v from shared directory", data, err)
+	}
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 )
 
 var (
@@ -34,7 +35,7 @@ func init() {
 		Run:         TestTLSFetchURLs,
 		ClusterSize: 1,
 		Name:        "coreos.tls.fetch-urls",
-		Flags:       []register.Flag{register.RequiresInternetAccess}, // Networking outside cluster required
+		Requires:    []platform.Capability{platform.CapInternet}, // Networking outside cluster required
 	})
 }
 
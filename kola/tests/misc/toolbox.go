@@ -19,6 +19,7 @@ import (
 
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 )
 
 func init() {
@@ -26,7 +27,7 @@ func init() {
 		Run:         dnfInstall,
 		ClusterSize: 1,
 		Name:        "cl.toolbox.dnf-install",
-		Flags:       []register.Flag{register.RequiresInternetAccess}, // Network access for toolbox
+		Requires:    []platform.Capability{platform.CapInternet}, // Network access for toolbox
 		Distros:     []string{"cl"},
 	})
 }
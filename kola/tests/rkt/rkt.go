@@ -44,7 +44,7 @@ func init() {
 		Name:        "cl.rkt.etcd3",
 		Run:         rktEtcd,
 		ClusterSize: 1,
-		Flags:       []register.Flag{register.RequiresInternetAccess}, // etcdctl health-check requires networking
+		Requires:    []platform.Capability{platform.CapInternet}, // etcdctl health-check requires networking
 		Distros:     []string{"cl"},
 		UserData:    config,
 	})
@@ -17,6 +17,7 @@ package torcx
 import (
 	"github.com/coreos/mantle/kola/cluster"
 	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
@@ -37,10 +38,10 @@ systemd:
 		Distros: []string{"cl"},
 
 		// https://github.com/coreos/mantle/issues/999
-		// On the qemu-unpriv platform the DHCP provides no data, pre-systemd 241 the DHCP server sending
+		// On platforms whose DHCP provides no data, pre-systemd 241 the DHCP server sending
 		// no routes to the link to spin in the configuring state. docker.service pulls in the network-online
 		// target which causes the basic machine checks to fail
-		ExcludePlatforms: []string{"qemu-unpriv"},
+		Requires: []platform.Capability{platform.CapNetworkOnline},
 	})
 }
 
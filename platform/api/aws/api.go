@@ -103,7 +103,7 @@ func New(opts *Options) (*API, error) {
 // GC removes AWS resources that are at least gracePeriod old.
 // It attempts to only operate on resources that were created by a mantle tool.
 func (a *API) GC(gracePeriod time.Duration) error {
-	return a.gcEC2(gracePeriod)
+	return a.GCWithOptions(&platform.GCOptions{GracePeriod: gracePeriod}).Err()
 }
 
 // PreflightCheck validates that the aws configuration provided has valid
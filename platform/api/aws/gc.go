@@ -0,0 +1,175 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/coreos/mantle/platform"
+)
+
+// mantleSecurityGroupDescription is set on security groups created by
+// createSecurityGroup and is used to recognize them during GC.
+const mantleSecurityGroupDescription = "mantle security group for testing"
+
+// GCWithOptions collects AWS resources matching opts.  Instances must be
+// tagged as created by mantle.  Key pairs and security groups carry no
+// creation time, so they are only collected once no instance uses them.
+func (a *API) GCWithOptions(opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
+
+	// every instance in the region, to find keys and security
+	// groups which are still in use
+	var instances []*ec2.Instance
+	err := a.ec2.DescribeInstancesPages(&ec2.DescribeInstancesInput{}, func(page *ec2.DescribeInstancesOutput, last bool) bool {
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+		return true
+	})
+	if err != nil {
+		report.Fail("aws", platform.GCInstance, fmt.Errorf("error describing instances: %v", err))
+		return report
+	}
+
+	usedKeys := map[string]bool{}
+	usedGroups := map[string]bool{}
+	for _, instance := range instances {
+		if instance.State != nil && *instance.State.Name == ec2.InstanceStateNameTerminated {
+			continue
+		}
+		garbage := opts.Wants(platform.GCInstance) && a.gcInstance(opts, report, instance)
+		if garbage && !opts.DryRun {
+			// the instance is going away
+			continue
+		}
+		if instance.KeyName != nil {
+			usedKeys[*instance.KeyName] = true
+		}
+		for _, group := range instance.SecurityGroups {
+			usedGroups[*group.GroupId] = true
+		}
+	}
+
+	if opts.Wants(platform.GCKey) {
+		a.gcKeys(opts, report, usedKeys)
+	}
+	if opts.Wants(platform.GCSecurityGroup) {
+		a.gcSecurityGroups(opts, report, usedGroups)
+	}
+	if opts.Wants(platform.GCImage) {
+		plog.Infof("gc: image collection is not supported on aws")
+	}
+
+	return report
+}
+
+// gcInstance collects an instance if it is garbage and reports whether
+// it was.
+func (a *API) gcInstance(opts *platform.GCOptions, report *platform.GCReport, instance *ec2.Instance) bool {
+	tags := map[string]string{}
+	for _, tag := range instance.Tags {
+		tags[*tag.Key] = *tag.Value
+	}
+	if tags["CreatedBy"] != "mantle" {
+		return false
+	}
+	if !opts.MatchName(tags["Name"]) || !opts.MatchTags(tags) {
+		return false
+	}
+	if !opts.Expired(*instance.LaunchTime) {
+		plog.Debugf("ec2: skipping instance %s due to being too new", *instance.InstanceId)
+		return false
+	}
+	if instance.State == nil {
+		plog.Warningf("ec2 instance had no state: %s", *instance.InstanceId)
+		return false
+	}
+	switch *instance.State.Name {
+	case ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning, ec2.InstanceStateNameStopped:
+	default:
+		plog.Infof("ec2: skipping instance in state %s", *instance.State.Name)
+		return false
+	}
+
+	id := *instance.InstanceId
+	report.Collect(platform.GCResource{
+		Platform: "aws",
+		Type:     platform.GCInstance,
+		ID:       id,
+		Name:     tags["Name"],
+		Created:  instance.LaunchTime,
+	}, func() error {
+		return a.TerminateInstances([]string{id})
+	})
+	return true
+}
+
+func (a *API) gcKeys(opts *platform.GCOptions, report *platform.GCReport, used map[string]bool) {
+	keys, err := a.ec2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		report.Fail("aws", platform.GCKey, fmt.Errorf("error describing key pairs: %v", err))
+		return
+	}
+	for _, key := range keys.KeyPairs {
+		name := *key.KeyName
+		if used[name] || !opts.MatchDefaultName(name) {
+			continue
+		}
+		if !opts.NameExpired(platform.GCKey, name) {
+			plog.Debugf("ec2: skipping key %s due to being too new", name)
+			continue
+		}
+		report.Collect(platform.GCResource{
+			Platform: "aws",
+			Type:     platform.GCKey,
+			ID:       name,
+		}, func() error {
+			return a.DeleteKey(name)
+		})
+	}
+}
+
+func (a *API) gcSecurityGroups(opts *platform.GCOptions, report *platform.GCReport, used map[string]bool) {
+	groups, err := a.ec2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("description"),
+				Values: aws.StringSlice([]string{mantleSecurityGroupDescription}),
+			},
+		},
+	})
+	if err != nil {
+		report.Fail("aws", platform.GCSecurityGroup, fmt.Errorf("error describing security groups: %v", err))
+		return
+	}
+	for _, group := range groups.SecurityGroups {
+		id := *group.GroupId
+		if used[id] || !opts.MatchName(*group.GroupName) {
+			continue
+		}
+		report.Collect(platform.GCResource{
+			Platform: "aws",
+			Type:     platform.GCSecurityGroup,
+			ID:       id,
+			Name:     *group.GroupName,
+		}, func() error {
+			return a.DeleteSecurityGroup(id)
+		})
+	}
+}
@@ -25,6 +25,17 @@ import (
 // getSecurityGroupID gets a security group matching the given name.
 // If the security group does not exist, it's created.
 func (a *API) getSecurityGroupID(name string) (string, error) {
+	id, _, err := a.ensureSecurityGroup(name)
+	return id, err
+}
+
+// EnsureSecurityGroup gets the security group named in the options,
+// creating it if it doesn't exist, and reports whether it was created.
+func (a *API) EnsureSecurityGroup() (string, bool, error) {
+	return a.ensureSecurityGroup(a.opts.SecurityGroup)
+}
+
+func (a *API) ensureSecurityGroup(name string) (string, bool, error) {
 	// using a Filter on group-name rather than the explicit GroupNames parameter
 	// disentangles this call from checking only inside of the default VPC
 	sgIds, err := a.ec2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
@@ -35,16 +46,24 @@ func (a *API) getSecurityGroupID(name string) (string, error) {
 			},
 		},
 	})
+	if err != nil {
+		return "", false, fmt.Errorf("unable to get security group named %v: %v", name, err)
+	}
 
 	if len(sgIds.SecurityGroups) == 0 {
-		return a.createSecurityGroup(name)
+		id, err := a.createSecurityGroup(name)
+		return id, err == nil, err
 	}
 
-	if err != nil {
-		return "", fmt.Errorf("unable to get security group named %v: %v", name, err)
-	}
+	return *sgIds.SecurityGroups[0].GroupId, false, nil
+}
 
-	return *sgIds.SecurityGroups[0].GroupId, nil
+// DeleteSecurityGroup deletes the security group with the given ID.
+func (a *API) DeleteSecurityGroup(id string) error {
+	_, err := a.ec2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+		GroupId: aws.String(id),
+	})
+	return err
 }
 
 // createSecurityGroup creates a security group with tcp/22 access allowed from the
@@ -56,7 +75,7 @@ func (a *API) createSecurityGroup(name string) (string, error) {
 	}
 	sg, err := a.ec2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
 		GroupName:   aws.String(name),
-		Description: aws.String("mantle security group for testing"),
+		Description: aws.String(mantleSecurityGroupDescription),
 		VpcId:       aws.String(vpcId),
 	})
 	if err != nil {
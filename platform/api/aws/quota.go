@@ -0,0 +1,214 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/coreos/mantle/platform"
+)
+
+// standardVCPUQuotaCode is the Service Quotas code of the limit on the
+// vCPUs of running On-Demand instances in the standard (A, C, D, H, I,
+// M, R, T and Z) families.
+const standardVCPUQuotaCode = "L-1216C47A"
+
+// InstanceQuota returns the number of instances of the configured type
+// the account may run in the region, by its On-Demand Standard vCPU
+// quota, and the number of instances counting against that quota.
+func (a *API) InstanceQuota() (platform.InstanceQuota, error) {
+	instanceType := a.opts.InstanceType
+	if !standardInstanceType(instanceType) {
+		return platform.InstanceQuota{}, fmt.Errorf("instance type %s isn't limited by the On-Demand Standard vCPU quota", instanceType)
+	}
+	typeVCPUs, err := instanceTypeVCPUs(instanceType)
+	if err != nil {
+		return platform.InstanceQuota{}, err
+	}
+
+	limit, err := a.serviceQuota("ec2", standardVCPUQuotaCode)
+	if err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("getting On-Demand Standard vCPU quota: %v", err)
+	}
+
+	var used, usedVCPUs int
+	err = a.ec2.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning}),
+			},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, last bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, inst := range reservation.Instances {
+				// spot and scheduled instances have quotas of their own
+				if inst.InstanceLifecycle != nil || !standardInstanceType(aws.StringValue(inst.InstanceType)) {
+					continue
+				}
+				used++
+				usedVCPUs += instanceVCPUs(inst)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("describing instances: %v", err)
+	}
+
+	available := (int(limit) - usedVCPUs) / typeVCPUs
+	if available < 0 {
+		available = 0
+	}
+	return platform.InstanceQuota{
+		Limit:       used + available,
+		Used:        used,
+		Description: fmt.Sprintf("%s instances by On-Demand Standard vCPUs", instanceType),
+	}, nil
+}
+
+// instanceVCPUs returns the number of vCPUs of a running instance.
+func instanceVCPUs(inst *ec2.Instance) int {
+	if opts := inst.CpuOptions; opts != nil && opts.CoreCount != nil && opts.ThreadsPerCore != nil {
+		return int(*opts.CoreCount * *opts.ThreadsPerCore)
+	}
+	if n, err := instanceTypeVCPUs(aws.StringValue(inst.InstanceType)); err == nil {
+		return n
+	}
+	return 1
+}
+
+// standardInstanceType reports whether instances of a type count
+// against the On-Demand Standard vCPU quota.
+func standardInstanceType(instanceType string) bool {
+	if instanceType == "" || strings.HasPrefix(instanceType, "inf") || strings.HasPrefix(instanceType, "mac") {
+		return false
+	}
+	return strings.ContainsRune("acdhimrtz", rune(instanceType[0]))
+}
+
+var instanceSizePattern = regexp.MustCompile(`^([0-9]*)xlarge$`)
+
+// instanceTypeVCPUs returns the number of vCPUs of an instance type.
+// The SDK can't describe instance types, so it relies on the sizes
+// being named consistently across the families.
+func instanceTypeVCPUs(instanceType string) (int, error) {
+	parts := strings.SplitN(instanceType, ".", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("malformed instance type %q", instanceType)
+	}
+	family, size := parts[0], parts[1]
+	switch size {
+	case "nano", "micro", "small":
+		if family == "t2" {
+			return 1, nil
+		}
+		if strings.HasPrefix(family, "t") {
+			return 2, nil
+		}
+	case "medium":
+		if strings.HasPrefix(family, "t") {
+			return 2, nil
+		}
+		return 1, nil
+	case "large":
+		return 2, nil
+	}
+	if m := instanceSizePattern.FindStringSubmatch(size); m != nil {
+		if m[1] == "" {
+			return 4, nil
+		}
+		n, err := strconv.Atoi(m[1])
+		if err == nil {
+			return 4 * n, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown number of vCPUs for instance type %s", instanceType)
+}
+
+const serviceQuotasName = "servicequotas"
+
+type getServiceQuotaInput struct {
+	_ struct{} `type:"structure"`
+
+	ServiceCode *string `type:"string"`
+	QuotaCode   *string `type:"string"`
+}
+
+type getServiceQuotaOutput struct {
+	_ struct{} `type:"structure"`
+
+	Quota *serviceQuota `type:"structure"`
+}
+
+type serviceQuota struct {
+	_ struct{} `type:"structure"`
+
+	Value *float64 `type:"double"`
+}
+
+// serviceQuota returns the value of a quota from the Service Quotas
+// API.  The SDK predates the API, so this builds the client the way the
+// SDK's generated ones are built.
+func (a *API) serviceQuota(serviceCode, quotaCode string) (float64, error) {
+	cfg := a.session.ClientConfig(serviceQuotasName)
+	signingName := cfg.SigningName
+	if signingName == "" {
+		signingName = serviceQuotasName
+	}
+	c := client.New(*cfg.Config, metadata.ClientInfo{
+		ServiceName:   serviceQuotasName,
+		SigningName:   signingName,
+		SigningRegion: cfg.SigningRegion,
+		Endpoint:      cfg.Endpoint,
+		APIVersion:    "2019-06-24",
+		JSONVersion:   "1.1",
+		TargetPrefix:  "ServiceQuotasV20190624",
+	}, cfg.Handlers)
+	c.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	c.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	c.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	c.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	c.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+
+	op := &request.Operation{
+		Name:       "GetServiceQuota",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+	input := &getServiceQuotaInput{
+		ServiceCode: aws.String(serviceCode),
+		QuotaCode:   aws.String(quotaCode),
+	}
+	output := &getServiceQuotaOutput{}
+	if err := c.NewRequest(op, input, output).Send(); err != nil {
+		return 0, err
+	}
+	if output.Quota == nil || output.Quota.Value == nil {
+		return 0, fmt.Errorf("quota %s of %s has no value", quotaCode, serviceCode)
+	}
+	return *output.Quota.Value, nil
+}
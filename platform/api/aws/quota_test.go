@@ -0,0 +1,65 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"testing"
+)
+
+func TestInstanceTypeVCPUs(t *testing.T) {
+	for _, tt := range []struct {
+		instanceType string
+		vcpus        int
+	}{
+		{"t2.micro", 1},
+		{"t2.medium", 2},
+		{"t3.nano", 2},
+		{"a1.medium", 1},
+		{"m4.large", 2},
+		{"c5.xlarge", 4},
+		{"m5.24xlarge", 96},
+		{"m5.metal", 0},
+		{"m4", 0},
+	} {
+		vcpus, err := instanceTypeVCPUs(tt.instanceType)
+		if tt.vcpus == 0 {
+			if err == nil {
+				t.Errorf("%s: got %d vCPUs, expected an error", tt.instanceType, vcpus)
+			}
+			continue
+		}
+		if err != nil || vcpus != tt.vcpus {
+			t.Errorf("%s: got %d vCPUs, %v, expected %d", tt.instanceType, vcpus, err, tt.vcpus)
+		}
+	}
+}
+
+func TestStandardInstanceType(t *testing.T) {
+	for instanceType, standard := range map[string]bool{
+		"m4.large":     true,
+		"t3.micro":     true,
+		"z1d.large":    true,
+		"p3.2xlarge":   false,
+		"g4dn.xlarge":  false,
+		"inf1.xlarge":  false,
+		"mac1.metal":   false,
+		"x1e.16xlarge": false,
+		"":             false,
+	} {
+		if standardInstanceType(instanceType) != standard {
+			t.Errorf("%q: expected standard %v", instanceType, standard)
+		}
+	}
+}
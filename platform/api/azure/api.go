@@ -33,6 +33,7 @@ import (
 	"github.com/coreos/pkg/capnslog"
 
 	internalAuth "github.com/coreos/mantle/auth"
+	"github.com/coreos/mantle/platform"
 )
 
 var (
@@ -172,28 +173,63 @@ func randomName(prefix string) string {
 	return fmt.Sprintf("%s-%x", prefix, b)
 }
 
+// GC removes kola resource groups that are at least gracePeriod old.
+// Every kola cluster on Azure lives in its own resource group, so this is
+// how its instances have always been collected.
 func (a *API) GC(gracePeriod time.Duration) error {
-	durationAgo := time.Now().Add(-1 * gracePeriod)
+	return a.GCWithOptions(&platform.GCOptions{
+		GracePeriod: gracePeriod,
+		Types:       []string{platform.GCResourceGroup},
+	}).Err()
+}
+
+// GCWithOptions collects kola resource groups matching opts.  Deleting a
+// resource group deletes the instances, disks and networks inside it.
+func (a *API) GCWithOptions(opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
+	if !opts.Wants(platform.GCResourceGroup) {
+		return report
+	}
 
 	listGroups, err := a.ListResourceGroups("")
 	if err != nil {
-		return fmt.Errorf("listing resource groups: %v", err)
+		report.Fail("azure", platform.GCResourceGroup, fmt.Errorf("listing resource groups: %v", err))
+		return report
 	}
 
 	for _, l := range *listGroups.Value {
-		if strings.HasPrefix(*l.Name, "kola-cluster") {
-			createdAt := *(*l.Tags)["createdAt"]
-			timeCreated, err := time.Parse(time.RFC3339, createdAt)
-			if err != nil {
-				return fmt.Errorf("error parsing time: %v", err)
-			}
-			if !timeCreated.After(durationAgo) {
-				if err = a.TerminateResourceGroup(*l.Name); err != nil {
-					return err
+		name := *l.Name
+		if !strings.HasPrefix(name, "kola-cluster") || !opts.MatchName(name) {
+			continue
+		}
+		tags := map[string]string{}
+		if l.Tags != nil {
+			for key, value := range *l.Tags {
+				if value != nil {
+					tags[key] = *value
 				}
 			}
 		}
+		if !opts.MatchTags(tags) {
+			continue
+		}
+		timeCreated, err := time.Parse(time.RFC3339, tags["createdAt"])
+		if err != nil {
+			report.Fail("azure", platform.GCResourceGroup, fmt.Errorf("error parsing time for %s: %v", name, err))
+			continue
+		}
+		if !opts.Expired(timeCreated) {
+			continue
+		}
+		report.Collect(platform.GCResource{
+			Platform: "azure",
+			Type:     platform.GCResourceGroup,
+			ID:       name,
+			Created:  &timeCreated,
+		}, func() error {
+			return a.TerminateResourceGroup(name)
+		})
 	}
 
-	return nil
+	return report
 }
@@ -127,6 +127,35 @@ func (a *API) PreflightCheck(ctx context.Context) error {
 	return nil
 }
 
+// InstanceQuota returns the account's droplet limit and the number of
+// droplets which currently exist.
+func (a *API) InstanceQuota(ctx context.Context) (platform.InstanceQuota, error) {
+	account, _, err := a.c.Account.Get(ctx)
+	if err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("querying account: %v", err)
+	}
+	quota := platform.InstanceQuota{
+		Limit:       account.DropletLimit,
+		Description: "droplets",
+	}
+
+	page := godo.ListOptions{
+		Page:    1,
+		PerPage: 200,
+	}
+	for {
+		droplets, _, err := a.c.Droplets.List(ctx, &page)
+		if err != nil {
+			return platform.InstanceQuota{}, fmt.Errorf("listing droplets: %v", err)
+		}
+		quota.Used += len(droplets)
+		if len(droplets) < page.PerPage {
+			return quota, nil
+		}
+		page.Page += 1
+	}
+}
+
 func (a *API) CreateDroplet(ctx context.Context, name string, sshKeyID int, userdata string) (*godo.Droplet, error) {
 	var droplet *godo.Droplet
 	var err error
@@ -326,12 +355,21 @@ func (a *API) ListKeys(ctx context.Context) ([]godo.Key, error) {
 }
 
 func (a *API) GC(ctx context.Context, gracePeriod time.Duration) error {
-	threshold := time.Now().Add(-gracePeriod)
+	return a.GCWithOptions(ctx, &platform.GCOptions{GracePeriod: gracePeriod}).Err()
+}
+
+// GCWithOptions collects droplets, SSH keys and user images matching
+// opts.  DigitalOcean doesn't record which keys a droplet was created
+// with, so keys are only collected when no mantle droplets remain.
+func (a *API) GCWithOptions(ctx context.Context, opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
 
 	droplets, err := a.listDropletsWithTag(ctx, "mantle")
 	if err != nil {
-		return fmt.Errorf("listing droplets: %v", err)
+		report.Fail("do", platform.GCInstance, fmt.Errorf("listing droplets: %v", err))
+		return report
 	}
+	remaining := 0
 	for _, droplet := range droplets {
 		if droplet.Status == "archive" {
 			continue
@@ -339,17 +377,109 @@ func (a *API) GC(ctx context.Context, gracePeriod time.Duration) error {
 
 		created, err := time.Parse(time.RFC3339, droplet.Created)
 		if err != nil {
-			return fmt.Errorf("couldn't parse %q: %v", droplet.Created, err)
+			report.Fail("do", platform.GCInstance, fmt.Errorf("couldn't parse %q: %v", droplet.Created, err))
+			remaining++
+			continue
 		}
-		if created.After(threshold) {
+		if !opts.Wants(platform.GCInstance) || !opts.Expired(created) ||
+			!opts.MatchName(droplet.Name) || !opts.MatchTagList(droplet.Tags) {
+			remaining++
 			continue
 		}
 
-		if err := a.DeleteDroplet(ctx, droplet.ID); err != nil {
-			return fmt.Errorf("couldn't delete droplet %d: %v", droplet.ID, err)
+		id := droplet.ID
+		report.Collect(platform.GCResource{
+			Platform: "do",
+			Type:     platform.GCInstance,
+			ID:       strconv.Itoa(id),
+			Name:     droplet.Name,
+			Created:  &created,
+		}, func() error {
+			return a.DeleteDroplet(ctx, id)
+		})
+	}
+
+	if opts.Wants(platform.GCKey) {
+		if remaining > 0 {
+			plog.Infof("gc: skipping keys; %d mantle droplets remain", remaining)
+		} else {
+			a.gcKeys(ctx, opts, report)
 		}
 	}
-	return nil
+	if opts.Wants(platform.GCImage) {
+		a.gcImages(ctx, opts, report)
+	}
+
+	return report
+}
+
+func (a *API) gcKeys(ctx context.Context, opts *platform.GCOptions, report *platform.GCReport) {
+	keys, err := a.ListKeys(ctx)
+	if err != nil {
+		report.Fail("do", platform.GCKey, fmt.Errorf("listing keys: %v", err))
+		return
+	}
+	for _, key := range keys {
+		if !opts.MatchDefaultName(key.Name) {
+			continue
+		}
+		if !opts.NameExpired(platform.GCKey, key.Name) {
+			plog.Debugf("do: skipping key %s due to being too new", key.Name)
+			continue
+		}
+		id := key.ID
+		report.Collect(platform.GCResource{
+			Platform: "do",
+			Type:     platform.GCKey,
+			ID:       strconv.Itoa(id),
+			Name:     key.Name,
+		}, func() error {
+			return a.DeleteKey(ctx, id)
+		})
+	}
+}
+
+func (a *API) gcImages(ctx context.Context, opts *platform.GCOptions, report *platform.GCReport) {
+	// images carry no record of who created them
+	if !opts.Filtered() {
+		report.Fail("do", platform.GCImage, platform.ErrGCUnfiltered)
+		return
+	}
+	page := godo.ListOptions{
+		Page:    1,
+		PerPage: 200,
+	}
+	for {
+		images, _, err := a.c.Images.ListUser(ctx, &page)
+		if err != nil {
+			report.Fail("do", platform.GCImage, fmt.Errorf("listing images: %v", err))
+			return
+		}
+		for _, image := range images {
+			created, err := time.Parse(time.RFC3339, image.Created)
+			if err != nil {
+				report.Fail("do", platform.GCImage, fmt.Errorf("couldn't parse %q: %v", image.Created, err))
+				continue
+			}
+			if !opts.Expired(created) || !opts.MatchName(image.Name) {
+				continue
+			}
+			id := image.ID
+			report.Collect(platform.GCResource{
+				Platform: "do",
+				Type:     platform.GCImage,
+				ID:       strconv.Itoa(id),
+				Name:     image.Name,
+				Created:  &created,
+			}, func() error {
+				return a.DeleteImage(ctx, id)
+			})
+		}
+		if len(images) < page.PerPage {
+			return
+		}
+		page.Page += 1
+	}
 }
 
 type tokenSource struct {
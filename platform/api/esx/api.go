@@ -215,6 +215,9 @@ func (a *API) buildCloneSpec(baseVM *object.VirtualMachine, folder *object.Folde
 			Pool:      &poolRef,
 			Datastore: &datastoreRef,
 		},
+		Config: &types.VirtualMachineConfigSpec{
+			Annotation: createdAnnotation + time.Now().UTC().Format(time.RFC3339),
+		},
 		PowerOn:  false,
 		Template: false,
 	}
@@ -672,3 +675,128 @@ func (a *API) PreflightCheck() error {
 
 	return mo.RetrieveProperties(context.Background(), c, c.ServiceContent.PropertyCollector, *c.ServiceContent.SessionManager, &mgr)
 }
+
+// InstanceQuota estimates how many VMs the default resource pool can
+// hold.  ESX has no instance limit, so the limit is the number of
+// existing VMs plus the number of copies of the base VM which fit in the
+// pool's unreserved memory.
+func (a *API) InstanceQuota() (platform.InstanceQuota, error) {
+	if a.options.BaseVMName == "" {
+		return platform.InstanceQuota{}, fmt.Errorf("Base VM Name must be supplied")
+	}
+
+	defaults, err := a.getServerDefaults()
+	if err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("couldn't get server defaults: %v", err)
+	}
+
+	baseVM, err := defaults.finder.VirtualMachine(a.ctx, a.options.BaseVMName)
+	if err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("couldn't find base VM: %v", err)
+	}
+	var mvm mo.VirtualMachine
+	if err := baseVM.Properties(a.ctx, baseVM.Reference(), []string{"summary"}, &mvm); err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("getting base VM summary: %v", err)
+	}
+	vmMemory := int64(mvm.Summary.Config.MemorySizeMB) * 1024 * 1024
+	if vmMemory <= 0 {
+		return platform.InstanceQuota{}, fmt.Errorf("base VM has no memory size")
+	}
+
+	var pool mo.ResourcePool
+	if err := defaults.resourcePool.Properties(a.ctx, defaults.resourcePool.Reference(), []string{"runtime"}, &pool); err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("getting resource pool runtime: %v", err)
+	}
+
+	vms, err := defaults.finder.VirtualMachineList(a.ctx, "*")
+	if err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("listing vms: %v", err)
+	}
+	used := len(vms) - 1 // the base VM
+
+	return platform.InstanceQuota{
+		Limit:       used + int(pool.Runtime.Memory.UnreservedForVm/vmMemory),
+		Used:        used,
+		Description: "VMs by resource pool memory",
+	}, nil
+}
+
+// GC removes kola VMs that were booted at least gracePeriod ago.
+func (a *API) GC(gracePeriod time.Duration) error {
+	return a.GCWithOptions(&platform.GCOptions{GracePeriod: gracePeriod}).Err()
+}
+
+// createdAnnotation prefixes the creation time which CreateDevice records
+// in a VM's annotation, since ESX doesn't record it.
+const createdAnnotation = "Created by mantle at "
+
+// vmCreated returns when a VM was created according to its annotation.
+func vmCreated(annotation string) (time.Time, bool) {
+	if !strings.HasPrefix(annotation, createdAnnotation) {
+		return time.Time{}, false
+	}
+	created, err := time.Parse(time.RFC3339, strings.TrimPrefix(annotation, createdAnnotation))
+	return created, err == nil
+}
+
+// GCWithOptions collects VMs matching opts.  ESX has no tags, so only VMs
+// named like kola clusters are considered.  Their age is taken from the
+// creation time CreateDevice records, or for older VMs from the last
+// boot; VMs of unknown age are skipped.
+func (a *API) GCWithOptions(opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
+	if !opts.Wants(platform.GCInstance) {
+		return report
+	}
+
+	defaults, err := a.getServerDefaults()
+	if err != nil {
+		report.Fail("esx", platform.GCInstance, fmt.Errorf("couldn't get server defaults: %v", err))
+		return report
+	}
+
+	vms, err := defaults.finder.VirtualMachineList(a.ctx, "*")
+	if err != nil {
+		report.Fail("esx", platform.GCInstance, fmt.Errorf("listing vms: %v", err))
+		return report
+	}
+
+	for _, vm := range vms {
+		var mvm mo.VirtualMachine
+		if err := vm.Properties(a.ctx, vm.Reference(), []string{"summary"}, &mvm); err != nil {
+			report.Fail("esx", platform.GCInstance, fmt.Errorf("getting machine reference: %v", err))
+			continue
+		}
+		name := mvm.Summary.Config.Name
+		if name == a.options.BaseVMName || !opts.MatchDefaultName(name) {
+			continue
+		}
+		created, ok := vmCreated(mvm.Summary.Config.Annotation)
+		if !ok {
+			boot := mvm.Summary.Runtime.BootTime
+			if boot == nil {
+				plog.Debugf("esx: skipping vm %s of unknown age", name)
+				continue
+			}
+			created = *boot
+		}
+		if !opts.Expired(created) {
+			continue
+		}
+
+		vm := vm
+		report.Collect(platform.GCResource{
+			Platform: "esx",
+			Type:     platform.GCInstance,
+			ID:       name,
+			Created:  &created,
+		}, func() error {
+			if err := a.deleteDevice(vm); err != nil {
+				return err
+			}
+			return a.CleanupDevice(name)
+		})
+	}
+
+	return report
+}
@@ -16,6 +16,7 @@
 package gcloud
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -105,5 +106,18 @@ func (a *API) Client() *http.Client {
 }
 
 func (a *API) GC(gracePeriod time.Duration) error {
-	return a.gcInstances(gracePeriod)
+	return a.GCWithOptions(&platform.GCOptions{GracePeriod: gracePeriod}).Err()
+}
+
+// GCWithOptions collects instances created by mantle and, if requested,
+// images matching opts.
+func (a *API) GCWithOptions(opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
+	if opts.Wants(platform.GCInstance) {
+		a.gcInstances(opts, report)
+	}
+	if opts.Wants(platform.GCImage) {
+		a.gcImages(context.Background(), opts, report)
+	}
+	return report
 }
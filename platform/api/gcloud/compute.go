@@ -22,6 +22,8 @@ import (
 
 	"golang.org/x/crypto/ssh/agent"
 	"google.golang.org/api/compute/v1"
+
+	"github.com/coreos/mantle/platform"
 )
 
 func (a *API) vmname() string {
@@ -157,6 +159,30 @@ func (a *API) ListInstances(prefix string) ([]*compute.Instance, error) {
 	return instances, nil
 }
 
+// InstanceQuota returns the project's instance quota in the region
+// containing the configured zone.
+func (a *API) InstanceQuota() (platform.InstanceQuota, error) {
+	zone := a.options.Zone
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	r, err := a.compute.Regions.Get(a.options.Project, region).Do()
+	if err != nil {
+		return platform.InstanceQuota{}, fmt.Errorf("getting region %s: %v", region, err)
+	}
+	for _, q := range r.Quotas {
+		if q.Metric == "INSTANCES" {
+			return platform.InstanceQuota{
+				Limit:       int(q.Limit),
+				Used:        int(q.Usage),
+				Description: "instances in " + region,
+			}, nil
+		}
+	}
+	return platform.InstanceQuota{}, fmt.Errorf("region %s has no INSTANCES quota", region)
+}
+
 func (a *API) GetConsoleOutput(name string) (string, error) {
 	out, err := a.compute.Instances.GetSerialPortOutput(a.options.Project, a.options.Zone, name).Do()
 	if err != nil {
@@ -180,12 +206,11 @@ func InstanceIPs(inst *compute.Instance) (intIP, extIP string) {
 	return
 }
 
-func (a *API) gcInstances(gracePeriod time.Duration) error {
-	threshold := time.Now().Add(-gracePeriod)
-
+func (a *API) gcInstances(opts *platform.GCOptions, report *platform.GCReport) {
 	list, err := a.compute.Instances.List(a.options.Project, a.options.Zone).Do()
 	if err != nil {
-		return err
+		report.Fail("gce", platform.GCInstance, err)
+		return
 	}
 	for _, instance := range list.Items {
 		// check metadata because our vendored Go binding
@@ -193,22 +218,25 @@ func (a *API) gcInstances(gracePeriod time.Duration) error {
 		if instance.Metadata == nil {
 			continue
 		}
-		isMantle := false
+		metadata := map[string]string{}
 		for _, item := range instance.Metadata.Items {
-			if item.Key == "created-by" && item.Value != nil && *item.Value == "mantle" {
-				isMantle = true
-				break
+			if item.Value != nil {
+				metadata[item.Key] = *item.Value
 			}
 		}
-		if !isMantle {
+		if metadata["created-by"] != "mantle" {
+			continue
+		}
+		if !opts.MatchName(instance.Name) || !opts.MatchTags(metadata) {
 			continue
 		}
 
 		created, err := time.Parse(time.RFC3339, instance.CreationTimestamp)
 		if err != nil {
-			return fmt.Errorf("couldn't parse %q: %v", instance.CreationTimestamp, err)
+			report.Fail("gce", platform.GCInstance, fmt.Errorf("couldn't parse %q: %v", instance.CreationTimestamp, err))
+			continue
 		}
-		if created.After(threshold) {
+		if !opts.Expired(created) {
 			continue
 		}
 
@@ -217,10 +245,14 @@ func (a *API) gcInstances(gracePeriod time.Duration) error {
 			continue
 		}
 
-		if err := a.TerminateInstance(instance.Name); err != nil {
-			return fmt.Errorf("couldn't terminate instance %q: %v", instance.Name, err)
-		}
+		name := instance.Name
+		report.Collect(platform.GCResource{
+			Platform: "gce",
+			Type:     platform.GCInstance,
+			ID:       name,
+			Created:  &created,
+		}, func() error {
+			return a.TerminateInstance(name)
+		})
 	}
-
-	return nil
 }
@@ -17,9 +17,12 @@ package gcloud
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/api/compute/v1"
+
+	"github.com/coreos/mantle/platform"
 )
 
 type DeprecationState string
@@ -148,3 +151,39 @@ func (a *API) DeleteImage(name string) (*Pending, error) {
 	opReq := a.compute.GlobalOperations.Get(a.options.Project, op.Name)
 	return a.NewPending(op.Name, opReq), nil
 }
+
+func (a *API) gcImages(ctx context.Context, opts *platform.GCOptions, report *platform.GCReport) {
+	// images carry no record of who created them
+	if !opts.Filtered() {
+		report.Fail("gce", platform.GCImage, platform.ErrGCUnfiltered)
+		return
+	}
+	images, err := a.ListImages(ctx, opts.NamePrefix)
+	if err != nil {
+		report.Fail("gce", platform.GCImage, err)
+		return
+	}
+	for _, image := range images {
+		created, err := time.Parse(time.RFC3339, image.CreationTimestamp)
+		if err != nil {
+			report.Fail("gce", platform.GCImage, fmt.Errorf("couldn't parse %q: %v", image.CreationTimestamp, err))
+			continue
+		}
+		if !opts.Expired(created) || !opts.MatchTags(image.Labels) {
+			continue
+		}
+		name := image.Name
+		report.Collect(platform.GCResource{
+			Platform: "gce",
+			Type:     platform.GCImage,
+			ID:       name,
+			Created:  &created,
+		}, func() error {
+			pending, err := a.DeleteImage(name)
+			if err != nil {
+				return err
+			}
+			return pending.Wait()
+		})
+	}
+}
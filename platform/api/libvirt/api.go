@@ -0,0 +1,186 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package libvirt drives libvirt through virsh, so that kola can run
+// machines on hosts whose VMs are already managed by libvirtd.
+package libvirt
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/pkg/capnslog"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/system/exec"
+	"github.com/coreos/mantle/util"
+)
+
+var plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "platform/api/libvirt")
+
+// Networking modes.
+const (
+	// NetworkAuto uses NetworkNAT on privileged connections and
+	// NetworkUser otherwise.
+	NetworkAuto = "auto"
+	// NetworkNAT creates a NAT network for each flight.  This needs a
+	// privileged connection such as qemu:///system.
+	NetworkNAT = "nat"
+	// NetworkUser gives each machine QEMU user networking with SSH
+	// forwarded from a port on localhost.
+	NetworkUser = "user"
+)
+
+type Options struct {
+	*platform.Options
+
+	// URI is the libvirt connection URI.
+	URI string
+	// DiskImage is the image to boot.  Each machine gets a qcow2
+	// overlay backed by it.
+	DiskImage string
+	Board     string
+	// Memory is the machine memory in MiB.
+	Memory int
+	CPUs   int
+	// Network is one of the Network* modes.
+	Network string
+}
+
+type API struct {
+	opts *Options
+
+	diskImage  string
+	diskFormat string
+}
+
+// New checks that virsh is available and the disk image is usable.
+func New(opts *Options) (*API, error) {
+	if _, err := exec.LookPath("virsh"); err != nil {
+		return nil, fmt.Errorf("virsh is required: %v", err)
+	}
+	if opts.URI == "" {
+		opts.URI = "qemu:///session"
+	}
+	if opts.Memory == 0 {
+		opts.Memory = 1024
+	}
+	if opts.CPUs == 0 {
+		opts.CPUs = 1
+	}
+	switch opts.Network {
+	case "", NetworkAuto:
+		opts.Network = NetworkUser
+		if privileged(opts.URI) {
+			opts.Network = NetworkNAT
+		}
+	case NetworkNAT, NetworkUser:
+	default:
+		return nil, fmt.Errorf("unknown network mode %q", opts.Network)
+	}
+
+	api := &API{opts: opts}
+	if opts.DiskImage != "" {
+		// overlays live in other directories, so the backing file
+		// must be absolute, and pinned in case "latest" moves
+		image, err := filepath.Abs(opts.DiskImage)
+		if err != nil {
+			return nil, err
+		}
+		if api.diskImage, err = filepath.EvalSymlinks(image); err != nil {
+			return nil, err
+		}
+		info, err := util.GetImageInfo(api.diskImage)
+		if err != nil {
+			return nil, fmt.Errorf("inspecting disk image: %v", err)
+		}
+		api.diskFormat = info.Format
+	}
+	return api, nil
+}
+
+// privileged reports whether uri connects to a system libvirtd, which
+// can create networks.
+func privileged(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return u.Path == "/system"
+}
+
+// NetworkMode returns the networking mode machines use.
+func (a *API) NetworkMode() string {
+	return a.opts.Network
+}
+
+// PreflightCheck validates that the libvirt connection works.
+func (a *API) PreflightCheck() error {
+	_, err := a.virsh("uri")
+	return err
+}
+
+// virsh runs a virsh command against the configured connection and
+// returns its output.
+func (a *API) virsh(args ...string) ([]byte, error) {
+	args = append([]string{"--connect", a.opts.URI, "--quiet"}, args...)
+	plog.Debugf("Running virsh %q", args)
+	cmd := exec.Command("virsh", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("virsh %s: %v: %s", args[3], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// virshXML writes doc to a temporary file and runs the virsh command
+// with the file as its last argument.
+func (a *API) virshXML(doc []byte, args ...string) error {
+	f, err := ioutil.TempFile("", "mantle-libvirt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(doc)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	_, err = a.virsh(append(args, f.Name())...)
+	return err
+}
+
+// CloneDisk creates a qcow2 image at path backed by the disk image, so
+// that machines share the base image and only store their changes.
+func (a *API) CloneDisk(path string) error {
+	if a.diskImage == "" {
+		return fmt.Errorf("no disk image specified")
+	}
+	cmd := exec.Command("qemu-img", "create", "-f", "qcow2",
+		"-o", fmt.Sprintf("backing_file=%s,backing_fmt=%s,lazy_refcounts=on", a.diskImage, a.diskFormat),
+		path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("creating disk: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// metadataNamespace identifies the mantle element in domain metadata.
+const metadataNamespace = "https://github.com/coreos/mantle"
+
+// DomainSpec describes a machine to create.
+type DomainSpec struct {
+	Name string
+	// DiskPath is the machine's disk, normally created by CloneDisk.
+	DiskPath string
+	// ConsolePath receives the serial console output.
+	ConsolePath string
+	// IgnitionPath is passed to the machine with fw_cfg, if set.
+	IgnitionPath string
+	// Network is the libvirt network to attach to, with the given MAC
+	// address.  If empty, QEMU user networking is used instead and SSH
+	// is forwarded from SSHPort on localhost.
+	Network string
+	MAC     string
+	SSHPort int
+	// Segment, if set, is a private network to attach a second
+	// interface to, with the given MAC and static address.
+	Segment    *Segment
+	SegmentMAC string
+	SegmentIP  string
+}
+
+// domainMetadata is stored in each domain created by mantle.
+type domainMetadata struct {
+	XMLName xml.Name  `xml:"instance"`
+	Created time.Time `xml:"created"`
+	Network string    `xml:"network,omitempty"`
+}
+
+// Domain is a domain created by mantle.
+type Domain struct {
+	Name    string
+	Created time.Time
+	Network string
+}
+
+var domainTemplate = template.Must(template.New("domain").Funcs(template.FuncMap{
+	"x": xmlEscape,
+}).Parse(`<domain type='{{.Type}}' xmlns:qemu='http://libvirt.org/schemas/domain/qemu/1.0'>
+  <name>{{x .Spec.Name}}</name>
+  <metadata>
+    <mantle:instance xmlns:mantle='{{.Namespace}}'>
+      <mantle:created>{{.Created}}</mantle:created>
+      {{- if .Spec.Network}}
+      <mantle:network>{{x .Spec.Network}}</mantle:network>
+      {{- end}}
+    </mantle:instance>
+  </metadata>
+  <memory unit='MiB'>{{.Memory}}</memory>
+  <vcpu>{{.CPUs}}</vcpu>
+  <os{{if .EFI}} firmware='efi'{{end}}>
+    <type arch='{{.Arch}}' machine='{{.Machine}}'>hvm</type>
+  </os>
+  <features>
+    <acpi/>
+  </features>
+  {{- if eq .Type "kvm"}}
+  <cpu mode='host-passthrough'/>
+  {{- end}}
+  <devices>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source file='{{x .Spec.DiskPath}}'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    {{- if .Spec.Network}}
+    <interface type='network'>
+      <source network='{{x .Spec.Network}}'/>
+      <mac address='{{x .Spec.MAC}}'/>
+      <model type='virtio'/>
+    </interface>
+    {{- end}}
+    {{- if .Spec.Segment}}
+    <interface type='mcast'>
+      <mac address='{{x .Spec.SegmentMAC}}'/>
+      <source address='{{.Spec.Segment.Group}}' port='{{.Spec.Segment.Port}}'/>
+      <model type='virtio'/>
+    </interface>
+    {{- end}}
+    <serial type='file'>
+      <source path='{{x .Spec.ConsolePath}}'/>
+      <target port='0'/>
+    </serial>
+    <rng model='virtio'>
+      <backend model='random'>/dev/urandom</backend>
+    </rng>
+  </devices>
+  <qemu:commandline>
+    {{- if .Spec.IgnitionPath}}
+    <qemu:arg value='-fw_cfg'/>
+    <qemu:arg value='name=opt/com.coreos/config,file={{x .Spec.IgnitionPath}}'/>
+    {{- end}}
+    {{- if not .Spec.Network}}
+    <qemu:arg value='-netdev'/>
+    <qemu:arg value='user,id=mantle0,hostfwd=tcp:127.0.0.1:{{.Spec.SSHPort}}-:22'/>
+    <qemu:arg value='-device'/>
+    <qemu:arg value='virtio-net-pci,netdev=mantle0'/>
+    {{- end}}
+  </qemu:commandline>
+</domain>
+`))
+
+func xmlEscape(s string) (string, error) {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// hostArchs maps GOARCH to libvirt's architecture names.
+var hostArchs = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+	"s390x": "s390x",
+}
+
+// domainType returns "kvm" if a domain of the architecture can use KVM,
+// and otherwise "qemu" for emulation.  KVM is assumed on remote hosts.
+func (a *API) domainType(arch string) string {
+	if u, err := url.Parse(a.opts.URI); err != nil || u.Host != "" {
+		return "kvm"
+	}
+	if hostArchs[runtime.GOARCH] != arch {
+		return "qemu"
+	}
+	if _, err := os.Stat("/dev/kvm"); err != nil {
+		return "qemu"
+	}
+	return "kvm"
+}
+
+// CreateDomain defines and starts a transient domain.
+func (a *API) CreateDomain(spec *DomainSpec) error {
+	doc, err := a.domainXML(spec)
+	if err != nil {
+		return err
+	}
+	return a.virshXML(doc, "create")
+}
+
+func (a *API) domainXML(spec *DomainSpec) ([]byte, error) {
+	params := struct {
+		Spec      *DomainSpec
+		Type      string
+		Namespace string
+		Created   string
+		Memory    int
+		CPUs      int
+		Arch      string
+		Machine   string
+		EFI       bool
+	}{
+		Spec:      spec,
+		Namespace: metadataNamespace,
+		Created:   time.Now().UTC().Format(time.RFC3339),
+		Memory:    a.opts.Memory,
+		CPUs:      a.opts.CPUs,
+	}
+	switch a.opts.Board {
+	case "amd64-usr", "":
+		params.Arch = "x86_64"
+		params.Machine = "q35"
+	case "arm64-usr":
+		params.Arch = "aarch64"
+		params.Machine = "virt"
+		params.EFI = true
+	default:
+		return nil, fmt.Errorf("unsupported board %q", a.opts.Board)
+	}
+	params.Type = a.domainType(params.Arch)
+
+	var doc bytes.Buffer
+	if err := domainTemplate.Execute(&doc, params); err != nil {
+		return nil, err
+	}
+	return doc.Bytes(), nil
+}
+
+// DestroyDomain stops a domain.  Domains created by CreateDomain are
+// transient, so this also deletes them.
+func (a *API) DestroyDomain(name string) error {
+	_, err := a.virsh("destroy", name)
+	return err
+}
+
+// ListDomains returns the running domains created by mantle.
+func (a *API) ListDomains() ([]Domain, error) {
+	out, err := a.virsh("list", "--name")
+	if err != nil {
+		return nil, err
+	}
+	var domains []Domain
+	for _, name := range strings.Fields(string(out)) {
+		out, err := a.virsh("metadata", name, metadataNamespace)
+		if err != nil {
+			// not ours
+			continue
+		}
+		var meta domainMetadata
+		if err := xml.Unmarshal(out, &meta); err != nil {
+			return nil, fmt.Errorf("parsing metadata of %s: %v", name, err)
+		}
+		domains = append(domains, Domain{
+			Name:    name,
+			Created: meta.Created,
+			Network: meta.Network,
+		})
+	}
+	return domains, nil
+}
+
+// FreePort returns a TCP port on localhost which is currently unused,
+// for forwarding SSH to machines with user networking.
+func FreePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/mantle/platform"
+)
+
+// GC removes domains created by mantle at least gracePeriod ago.
+func (a *API) GC(gracePeriod time.Duration) error {
+	return a.GCWithOptions(&platform.GCOptions{GracePeriod: gracePeriod}).Err()
+}
+
+// GCWithOptions collects domains matching opts, and kola networks which
+// no remaining domain uses.  Networks carry no creation time, so their
+// age is taken from their names.
+func (a *API) GCWithOptions(opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
+
+	domains, err := a.ListDomains()
+	if err != nil {
+		report.Fail("libvirt", platform.GCInstance, fmt.Errorf("listing domains: %v", err))
+		return report
+	}
+
+	usedNetworks := map[string]bool{}
+	for _, domain := range domains {
+		if !opts.Wants(platform.GCInstance) || !opts.MatchName(domain.Name) || !opts.Expired(domain.Created) {
+			usedNetworks[domain.Network] = true
+			continue
+		}
+		name, created := domain.Name, domain.Created
+		report.Collect(platform.GCResource{
+			Platform: "libvirt",
+			Type:     platform.GCInstance,
+			ID:       name,
+			Created:  &created,
+		}, func() error {
+			return a.DestroyDomain(name)
+		})
+		if opts.DryRun {
+			usedNetworks[domain.Network] = true
+		}
+	}
+
+	if !opts.Wants(platform.GCNetwork) || a.opts.Network != NetworkNAT {
+		return report
+	}
+	networks, err := a.ListNetworks()
+	if err != nil {
+		report.Fail("libvirt", platform.GCNetwork, fmt.Errorf("listing networks: %v", err))
+		return report
+	}
+	for _, network := range networks {
+		if usedNetworks[network] || !opts.MatchDefaultName(network) {
+			continue
+		}
+		if !opts.NameExpired(platform.GCNetwork, network) {
+			plog.Debugf("libvirt: skipping network %s due to being too new", network)
+			continue
+		}
+		network := network
+		report.Collect(platform.GCResource{
+			Platform: "libvirt",
+			Type:     platform.GCNetwork,
+			ID:       network,
+		}, func() error {
+			return a.DeleteNetwork(network)
+		})
+	}
+
+	return report
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseLease(t *testing.T) {
+	out := []byte(`
+ 2019-10-01 12:00:00   52:54:00:aa:bb:cc   ipv6     fd00::10/64          -          -
+ 2019-10-01 12:00:00   52:54:00:aa:bb:cc   ipv4     192.168.10.20/24     localhost  01:52:54:00:aa:bb:cc
+ 2019-10-01 12:00:00   52:54:00:dd:ee:ff   ipv4     192.168.10.21/24     -          -
+`)
+	for _, tt := range []struct {
+		mac      string
+		expected string
+	}{
+		{"52:54:00:aa:bb:cc", "192.168.10.20"},
+		{"52:54:00:DD:EE:FF", "192.168.10.21"},
+		{"52:54:00:00:00:00", ""},
+	} {
+		ip, err := parseLease(out, tt.mac)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("parseLease(%s) = %s, expected an error", tt.mac, ip)
+			}
+		} else if err != nil || ip != tt.expected {
+			t.Errorf("parseLease(%s) = %s, %v, expected %s", tt.mac, ip, err, tt.expected)
+		}
+	}
+
+	if _, err := parseLease([]byte(" 2019-10-01 12:00:00 52:54:00:aa:bb:cc ipv4 bogus - -"), "52:54:00:aa:bb:cc"); err == nil {
+		t.Error("parseLease accepted an invalid address")
+	}
+}
+
+func TestPrivileged(t *testing.T) {
+	for uri, expected := range map[string]bool{
+		"qemu:///system":                 true,
+		"qemu+ssh://host/system":         true,
+		"qemu:///session":                false,
+		"qemu+unix:///session?socket=/x": false,
+	} {
+		if p := privileged(uri); p != expected {
+			t.Errorf("privileged(%s) = %v, expected %v", uri, p, expected)
+		}
+	}
+}
+
+func TestDomainXML(t *testing.T) {
+	segment := NewSegment()
+	spec := &DomainSpec{
+		Name:       "kola-test",
+		DiskPath:   "/tmp/disk.qcow2",
+		SSHPort:    2222,
+		Segment:    segment,
+		SegmentMAC: "52:54:00:aa:bb:cc",
+		SegmentIP:  "172.30.0.2",
+	}
+
+	// remote hosts are assumed to have KVM
+	a := &API{opts: &Options{URI: "qemu+ssh://host/system", Board: "amd64-usr", Memory: 1024, CPUs: 1}}
+	doc, err := a.domainXML(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"<domain type='kvm'",
+		"<cpu mode='host-passthrough'/>",
+		"<interface type='mcast'>",
+		"<mac address='52:54:00:aa:bb:cc'/>",
+		"address='" + segment.Group + "'",
+	} {
+		if !strings.Contains(string(doc), s) {
+			t.Errorf("domain XML lacks %q:\n%s", s, doc)
+		}
+	}
+
+	// a foreign architecture is emulated locally
+	board := "arm64-usr"
+	if runtime.GOARCH == "arm64" {
+		board = "amd64-usr"
+	}
+	a = &API{opts: &Options{URI: "qemu:///session", Board: board, Memory: 1024, CPUs: 1}}
+	doc, err = a.domainXML(&DomainSpec{Name: "kola-test", SSHPort: 2222})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(doc), "<domain type='qemu'") || strings.Contains(string(doc), "host-passthrough") {
+		t.Errorf("emulated domain XML uses KVM:\n%s", doc)
+	}
+	if strings.Contains(string(doc), "mcast") {
+		t.Errorf("domain XML without a segment has a segment interface:\n%s", doc)
+	}
+
+	a.opts.Board = "bogus"
+	if _, err := a.domainXML(spec); err == nil {
+		t.Error("domainXML accepted an unknown board")
+	}
+}
+
+func TestSegmentAddress(t *testing.T) {
+	s := NewSegment()
+	seen := make(map[string]bool)
+	for i := 0; i < 254; i++ {
+		addr, err := s.Address()
+		if err != nil {
+			t.Fatalf("allocating address %d: %v", i, err)
+		}
+		if seen[addr] || addr == segmentPrefix+"0" {
+			t.Fatalf("bad address %s", addr)
+		}
+		seen[addr] = true
+	}
+	if addr, err := s.Address(); err == nil {
+		t.Errorf("allocated %s beyond the end of the segment", addr)
+	}
+}
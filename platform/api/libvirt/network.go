@@ -0,0 +1,194 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+type networkXML struct {
+	XMLName xml.Name `xml:"network"`
+	Name    string   `xml:"name"`
+	Forward struct {
+		Mode string `xml:"mode,attr"`
+	} `xml:"forward"`
+	IP struct {
+		Address string `xml:"address,attr"`
+		Netmask string `xml:"netmask,attr"`
+		Range   struct {
+			Start string `xml:"start,attr"`
+			End   string `xml:"end,attr"`
+		} `xml:"dhcp>range"`
+	} `xml:"ip"`
+}
+
+// CreateNetwork creates a transient NAT network with DHCP.  The subnet
+// is chosen at random from 192.168.100.0-192.168.254.0/24; libvirt
+// refuses subnets which are already routed, in which case another is
+// tried.
+func (a *API) CreateNetwork(name string) error {
+	var err error
+	for i := 0; i < 10; i++ {
+		subnet := 100 + int(randomBytes(1)[0])%155
+		var n networkXML
+		n.Name = name
+		n.Forward.Mode = "nat"
+		n.IP.Address = fmt.Sprintf("192.168.%d.1", subnet)
+		n.IP.Netmask = "255.255.255.0"
+		n.IP.Range.Start = fmt.Sprintf("192.168.%d.2", subnet)
+		n.IP.Range.End = fmt.Sprintf("192.168.%d.254", subnet)
+
+		var doc []byte
+		doc, err = xml.MarshalIndent(n, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = a.virshXML(doc, "net-create"); err == nil {
+			return nil
+		}
+		plog.Debugf("Creating network on 192.168.%d.0/24 failed: %v", subnet, err)
+	}
+	return err
+}
+
+// DeleteNetwork destroys a transient network.
+func (a *API) DeleteNetwork(name string) error {
+	_, err := a.virsh("net-destroy", name)
+	return err
+}
+
+// ListNetworks returns the names of the active networks.
+func (a *API) ListNetworks() ([]string, error) {
+	out, err := a.virsh("net-list", "--name")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// LeaseIP returns the address leased by the network's DHCP server to
+// the interface with the given MAC address.
+func (a *API) LeaseIP(network, mac string) (string, error) {
+	out, err := a.virsh("net-dhcp-leases", network, "--mac", mac)
+	if err != nil {
+		return "", err
+	}
+	ip, err := parseLease(out, mac)
+	if err != nil {
+		return "", fmt.Errorf("%v on network %s", err, network)
+	}
+	return ip, nil
+}
+
+// parseLease returns the IPv4 address leased to mac in the output of
+// virsh net-dhcp-leases.
+func parseLease(out []byte, mac string) (string, error) {
+	// columns are expiry date and time, MAC, protocol, address/prefix,
+	// hostname and client ID
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.EqualFold(fields[2], mac) || fields[3] != "ipv4" {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(fields[4])
+		if err != nil {
+			return "", fmt.Errorf("parsing lease address %q: %v", fields[4], err)
+		}
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("no lease for %s", mac)
+}
+
+// segmentPrefix is the /24 subnet of every Segment.  Segments are
+// isolated from each other, so they can share it.
+const segmentPrefix = "172.30.0."
+
+// Segment is a private network which a flight's machines share over
+// multicast, for connections which can't create networks.  It has no
+// DHCP server, so machines get static addresses with SegmentConfig.
+type Segment struct {
+	Group string // multicast group
+	Port  int
+
+	lock     sync.Mutex
+	lastHost int
+}
+
+// NewSegment returns a segment with a random group and port, so that
+// concurrent flights don't share it.
+func NewSegment() *Segment {
+	b := randomBytes(4)
+	return &Segment{
+		Group: fmt.Sprintf("239.255.%d.%d", b[0], b[1]),
+		Port:  1024 + (int(b[2])<<8|int(b[3]))%(65536-1024),
+	}
+}
+
+// Address allocates an address on the segment.
+func (s *Segment) Address() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.lastHost >= 254 {
+		return "", fmt.Errorf("no addresses left on segment %s:%d", s.Group, s.Port)
+	}
+	s.lastHost++
+	return fmt.Sprintf("%s%d", segmentPrefix, s.lastHost), nil
+}
+
+// SegmentConfig returns the files, keyed by path, which give the
+// interface with the MAC address a static address on a segment.  It
+// includes configs for both systemd-networkd and NetworkManager, since
+// each ignores the other's.
+func SegmentConfig(mac, address string) map[string]string {
+	return map[string]string{
+		"/etc/systemd/network/10-mantle-segment.network": fmt.Sprintf(`[Match]
+MACAddress=%s
+
+[Network]
+Address=%s/24
+`, mac, address),
+		"/etc/NetworkManager/system-connections/mantle-segment.nmconnection": fmt.Sprintf(`[connection]
+id=mantle-segment
+type=ethernet
+
+[ethernet]
+mac-address=%s
+
+[ipv4]
+method=manual
+address1=%s/24
+
+[ipv6]
+method=ignore
+`, mac, address),
+	}
+}
+
+// RandomMAC returns a random address in the QEMU OUI.
+func RandomMAC() string {
+	b := randomBytes(3)
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", b[0], b[1], b[2])
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
@@ -577,22 +577,89 @@ func (a *API) listServersWithMetadata(metadata map[string]string) ([]servers.Ser
 }
 
 func (a *API) GC(gracePeriod time.Duration) error {
-	threshold := time.Now().Add(-gracePeriod)
+	return a.GCWithOptions(&platform.GCOptions{GracePeriod: gracePeriod}).Err()
+}
 
-	servers, err := a.listServersWithMetadata(map[string]string{
-		"CreatedBy": "mantle",
-	})
+// GCWithOptions collects servers created by mantle and key pairs that no
+// remaining server uses.
+func (a *API) GCWithOptions(opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
+
+	allServers, err := a.listServersWithMetadata(nil)
 	if err != nil {
-		return err
+		report.Fail("openstack", platform.GCInstance, err)
+		return report
 	}
-	for _, server := range servers {
-		if strings.Contains(server.Status, "DELETED") || server.Created.After(threshold) {
+
+	usedKeys := map[string]bool{}
+	for _, server := range allServers {
+		if strings.Contains(server.Status, "DELETED") {
+			continue
+		}
+		if opts.Wants(platform.GCInstance) && a.gcServer(opts, report, server) && !opts.DryRun {
 			continue
 		}
+		usedKeys[server.KeyName] = true
+	}
+
+	if opts.Wants(platform.GCKey) {
+		a.gcKeys(opts, report, usedKeys)
+	}
+	if opts.Wants(platform.GCImage) || opts.Wants(platform.GCSecurityGroup) {
+		plog.Infof("gc: image and security group collection is not supported on openstack")
+	}
+
+	return report
+}
 
-		if err := a.DeleteServer(server.ID); err != nil {
-			return fmt.Errorf("couldn't delete server %s: %v", server.ID, err)
+// gcServer collects a server if it is garbage and reports whether it was.
+func (a *API) gcServer(opts *platform.GCOptions, report *platform.GCReport, server servers.Server) bool {
+	if server.Metadata["CreatedBy"] != "mantle" {
+		return false
+	}
+	if !opts.Expired(server.Created) || !opts.MatchName(server.Name) || !opts.MatchTags(server.Metadata) {
+		return false
+	}
+
+	id := server.ID
+	report.Collect(platform.GCResource{
+		Platform: "openstack",
+		Type:     platform.GCInstance,
+		ID:       id,
+		Name:     server.Name,
+		Created:  &server.Created,
+	}, func() error {
+		return a.DeleteServer(id)
+	})
+	return true
+}
+
+func (a *API) gcKeys(opts *platform.GCOptions, report *platform.GCReport, used map[string]bool) {
+	pages, err := unwrapPages(keypairs.List(a.computeClient), true)
+	if err != nil {
+		report.Fail("openstack", platform.GCKey, fmt.Errorf("keypairs: %v", err))
+		return
+	}
+	keys, err := keypairs.ExtractKeyPairs(pages)
+	if err != nil {
+		report.Fail("openstack", platform.GCKey, fmt.Errorf("extracting keypairs: %v", err))
+		return
+	}
+	for _, key := range keys {
+		name := key.Name
+		if used[name] || !opts.MatchDefaultName(name) {
+			continue
 		}
+		if !opts.NameExpired(platform.GCKey, name) {
+			plog.Debugf("openstack: skipping key %s due to being too new", name)
+			continue
+		}
+		report.Collect(platform.GCResource{
+			Platform: "openstack",
+			Type:     platform.GCKey,
+			ID:       name,
+		}, func() error {
+			return a.DeleteKey(name)
+		})
 	}
-	return nil
 }
@@ -584,8 +584,26 @@ func waitForInstall(address string) (err error) {
 }
 
 func (a *API) GC(gracePeriod time.Duration) error {
-	threshold := time.Now().Add(-gracePeriod)
+	return a.GCWithOptions(&platform.GCOptions{GracePeriod: gracePeriod}).Err()
+}
+
+// GCWithOptions collects devices tagged by mantle and SSH keys matching
+// opts.
+func (a *API) GCWithOptions(opts *platform.GCOptions) *platform.GCReport {
+	report := platform.NewGCReport(opts)
+	if opts.Wants(platform.GCInstance) {
+		a.gcDevices(opts, report)
+	}
+	if opts.Wants(platform.GCKey) {
+		a.gcKeys(opts, report)
+	}
+	if opts.Wants(platform.GCImage) || opts.Wants(platform.GCSecurityGroup) {
+		plog.Infof("gc: image and security group collection is not supported on packet")
+	}
+	return report
+}
 
+func (a *API) gcDevices(opts *platform.GCOptions, report *platform.GCReport) {
 	page := packngo.ListOptions{
 		Page:    1,
 		PerPage: 1000,
@@ -594,7 +612,8 @@ func (a *API) GC(gracePeriod time.Duration) error {
 	for {
 		devices, _, err := a.c.Devices.List(a.opts.Project, &page)
 		if err != nil {
-			return fmt.Errorf("listing devices: %v", err)
+			report.Fail("packet", platform.GCInstance, fmt.Errorf("listing devices: %v", err))
+			return
 		}
 		for _, device := range devices {
 			tagged := false
@@ -604,7 +623,7 @@ func (a *API) GC(gracePeriod time.Duration) error {
 					break
 				}
 			}
-			if !tagged {
+			if !tagged || !opts.MatchName(device.Hostname) || !opts.MatchTagList(device.Tags) {
 				continue
 			}
 
@@ -619,19 +638,58 @@ func (a *API) GC(gracePeriod time.Duration) error {
 
 			created, err := time.Parse(time.RFC3339, device.Created)
 			if err != nil {
-				return fmt.Errorf("couldn't parse %q: %v", device.Created, err)
+				report.Fail("packet", platform.GCInstance, fmt.Errorf("couldn't parse %q: %v", device.Created, err))
+				continue
 			}
-			if created.After(threshold) {
+			if !opts.Expired(created) {
 				continue
 			}
 
-			if err := a.DeleteDevice(device.ID); err != nil {
-				return fmt.Errorf("couldn't delete device %v: %v", device.ID, err)
-			}
+			id := device.ID
+			report.Collect(platform.GCResource{
+				Platform: "packet",
+				Type:     platform.GCInstance,
+				ID:       id,
+				Name:     device.Hostname,
+				Created:  &created,
+			}, func() error {
+				return a.DeleteDevice(id)
+			})
 		}
 		if len(devices) < page.PerPage {
-			return nil
+			return
 		}
 		page.Page += 1
 	}
 }
+
+func (a *API) gcKeys(opts *platform.GCOptions, report *platform.GCReport) {
+	keys, err := a.ListKeys()
+	if err != nil {
+		report.Fail("packet", platform.GCKey, err)
+		return
+	}
+	for _, key := range keys {
+		if !opts.MatchDefaultName(key.Label) {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, key.Created)
+		if err != nil {
+			report.Fail("packet", platform.GCKey, fmt.Errorf("couldn't parse %q: %v", key.Created, err))
+			continue
+		}
+		if !opts.Expired(created) {
+			continue
+		}
+		id := key.ID
+		report.Collect(platform.GCResource{
+			Platform: "packet",
+			Type:     platform.GCKey,
+			ID:       id,
+			Name:     key.Label,
+			Created:  &created,
+		}, func() error {
+			return a.DeleteKey(id)
+		})
+	}
+}
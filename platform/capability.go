@@ -0,0 +1,126 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"github.com/coreos/go-omaha/omaha"
+)
+
+// Capability is something a platform offers its machines, which tests
+// may require.
+type Capability string
+
+const (
+	CapInternet        Capability = "internet"         // machines can reach the Internet
+	CapLocalOmaha      Capability = "local-omaha"      // the flight runs an Omaha server for machines
+	CapNTPServer       Capability = "ntp-server"       // the flight runs an NTP server for machines
+	CapMultipleDisks   Capability = "multiple-disks"   // machines can be created with extra disks
+	CapPrivateNetwork  Capability = "private-network"  // a cluster's machines can reach each other
+	CapNetworkOnline   Capability = "network-online"   // DHCP configures machines fully enough to reach network-online.target
+	CapConsoleReboot   Capability = "console-reboot"   // machines can be reset, powered off and driven through their serial console without SSH
+	CapMetadataService Capability = "metadata-service" // machines get SSH keys and other details from a platform metadata service
+)
+
+// OmahaCluster is implemented by the clusters of platforms with
+// CapLocalOmaha.
+type OmahaCluster interface {
+	// SetOmahaUpdater makes the flight's Omaha server answer machines
+	// with u.
+	SetOmahaUpdater(u omaha.Updater)
+
+	// GetOmahaHostPort returns the address at which machines reach the
+	// Omaha server.
+	GetOmahaHostPort() (string, error)
+}
+
+// NTPCluster is implemented by the clusters of platforms with
+// CapNTPServer.
+type NTPCluster interface {
+	// NTPServerIP returns the address at which machines reach the NTP
+	// server.
+	NTPServerIP() string
+}
+
+// Capabilities describes what a platform offers.
+type Capabilities struct {
+	Features      []Capability
+	Architectures []string // machine architectures the platform can run, or empty if unknown
+}
+
+// Has reports whether the platform offers c.
+func (c Capabilities) Has(capability Capability) bool {
+	for _, f := range c.Features {
+		if f == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsArchitecture reports whether the platform can run machines of
+// the architecture arch.  Any architecture is assumed to work if the
+// platform's architectures are unknown.
+func (c Capabilities) SupportsArchitecture(arch string) bool {
+	if len(c.Architectures) == 0 {
+		return true
+	}
+	for _, a := range c.Architectures {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
+
+var cloudCapabilities = Capabilities{
+	Features:      []Capability{CapInternet, CapPrivateNetwork, CapNetworkOnline, CapMetadataService},
+	Architectures: []string{"amd64"},
+}
+
+var platformCapabilities = map[string]Capabilities{
+	"aws":       cloudCapabilities,
+	"azure":     cloudCapabilities,
+	"do":        cloudCapabilities,
+	"gce":       cloudCapabilities,
+	"openstack": cloudCapabilities,
+	"esx": {
+		Features:      []Capability{CapInternet, CapPrivateNetwork, CapNetworkOnline},
+		Architectures: []string{"amd64"},
+	},
+	"libvirt": {
+		Features:      []Capability{CapInternet, CapPrivateNetwork, CapNetworkOnline},
+		Architectures: []string{"amd64"},
+	},
+	"packet": {
+		Features:      []Capability{CapInternet, CapPrivateNetwork, CapNetworkOnline, CapMetadataService},
+		Architectures: []string{"amd64", "arm64"},
+	},
+	"qemu": {
+		Features:      []Capability{CapLocalOmaha, CapNTPServer, CapMultipleDisks, CapPrivateNetwork, CapNetworkOnline, CapConsoleReboot},
+		Architectures: []string{"amd64", "arm64", "s390x"},
+	},
+	// qemu-unpriv has no local network, so its machines only reach the
+	// host and not each other, and its DHCP server sends no routes
+	"qemu-unpriv": {
+		Features:      []Capability{CapMultipleDisks, CapConsoleReboot},
+		Architectures: []string{"amd64", "arm64", "s390x"},
+	},
+}
+
+// PlatformCapabilities returns the capabilities of the platform called
+// name, which are empty if it is unknown.
+func PlatformCapabilities(name string) Capabilities {
+	return platformCapabilities[name]
+}
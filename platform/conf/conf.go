@@ -312,6 +312,36 @@ func (c *Conf) String() string {
 	return ""
 }
 
+// IgnitionPointer returns an Ignition config of the same spec version
+// which replaces itself with the config at url.
+func (c *Conf) IgnitionPointer(url string) ([]byte, error) {
+	var version string
+	switch {
+	case c.ignitionV2 != nil:
+		version = "2.0.0"
+	case c.ignitionV21 != nil:
+		version = "2.1.0"
+	case c.ignitionV22 != nil:
+		version = "2.2.0"
+	case c.ignitionV23 != nil:
+		version = "2.3.0"
+	case c.ignitionV3 != nil:
+		version = "3.0.0"
+	default:
+		return nil, fmt.Errorf("config doesn't support remote configs")
+	}
+	return json.Marshal(map[string]interface{}{
+		"ignition": map[string]interface{}{
+			"version": version,
+			"config": map[string]interface{}{
+				"replace": map[string]interface{}{
+					"source": url,
+				},
+			},
+		},
+	})
+}
+
 // MergeV3 merges a config with the ignitionV3 config via Ignition's merging function.
 func (c *Conf) MergeV3(newConfig v3types.Config) {
 	mergeConfig := v3.Merge(*c.ignitionV3, newConfig)
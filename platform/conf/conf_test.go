@@ -59,3 +59,48 @@ func TestConfCopyKey(t *testing.T) {
 		}
 	}
 }
+
+func TestIgnitionPointer(t *testing.T) {
+	const url = "http://10.0.2.2:8080/config.ign"
+
+	tests := []struct {
+		userdata *UserData
+		version  string
+	}{
+		{Ignition(`{ "ignition": { "version": "2.0.0" } }`), "2.0.0"},
+		{Ignition(`{ "ignition": { "version": "2.2.0" } }`), "2.2.0"},
+		{Ignition(`{ "ignition": { "version": "3.0.0" } }`), "3.0.0"},
+		{Ignition(`{ "ignitionVersion": 1 }`), ""},
+		{CloudConfig("#cloud-config"), ""},
+	}
+
+	for i, tt := range tests {
+		conf, err := tt.userdata.Render("")
+		if err != nil {
+			t.Errorf("failed to parse config %d: %v", i, err)
+			continue
+		}
+
+		pointer, err := conf.IgnitionPointer(url)
+		if tt.version == "" {
+			if err == nil {
+				t.Errorf("expected error for config %d, got %s", i, pointer)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to make pointer for config %d: %v", i, err)
+			continue
+		}
+
+		parsed, err := Ignition(string(pointer)).Render("")
+		if err != nil {
+			t.Errorf("pointer for config %d is invalid: %v: %s", i, err, pointer)
+			continue
+		}
+		str := parsed.String()
+		if !strings.Contains(str, `"version":"`+tt.version+`"`) || !strings.Contains(str, url) {
+			t.Errorf("unexpected pointer for config %d: %s", i, str)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxConsoleOutput is the most console output kept in memory.  Once it's
+// exceeded, the oldest half is dropped; the full output is still logged
+// to the machine's console file.
+const maxConsoleOutput = 4 << 20
+
+// Console is the serial console of a running QEMU machine.  Recent
+// output is kept so that it can be searched, and input is sent to the
+// machine's serial port.
+type Console struct {
+	conn    *os.File
+	qemuEnd *os.File
+
+	lock    sync.Mutex
+	output  []byte
+	dropped int           // bytes of output dropped from the front
+	changed chan struct{} // closed when output grows or reading stops
+	err     error
+	tee     io.Writer
+}
+
+// SetupQEMUConsole makes CreateQEMUCommand connect the machine's serial
+// console to the returned Console.  Call Started once QEMU has started,
+// so that the console stops when QEMU exits.
+func SetupQEMUConsole(options *MachineOptions) (*Console, error) {
+	c, qemuEnd, err := newConsole()
+	if err != nil {
+		return nil, err
+	}
+	options.console = qemuEnd
+	return c, nil
+}
+
+func newConsole() (*Console, *os.File, error) {
+	// a socket pair avoids the length limit on socket paths
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating console socket: %v", err)
+	}
+	c := &Console{
+		conn:    os.NewFile(uintptr(fds[0]), "console"),
+		qemuEnd: os.NewFile(uintptr(fds[1]), "console-qemu"),
+		changed: make(chan struct{}),
+	}
+	go c.run()
+	return c, c.qemuEnd, nil
+}
+
+func (c *Console) run() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := c.conn.Read(buf)
+		c.lock.Lock()
+		c.output = append(c.output, buf[:n]...)
+		if len(c.output) > maxConsoleOutput {
+			drop := len(c.output) - maxConsoleOutput/2
+			c.output = append([]byte(nil), c.output[drop:]...)
+			c.dropped += drop
+		}
+		if n > 0 && c.tee != nil {
+			c.tee.Write(buf[:n])
+		}
+		if err != nil {
+			c.err = err
+		}
+		close(c.changed)
+		c.changed = make(chan struct{})
+		c.lock.Unlock()
+		if err != nil {
+			// QEMU has exited, or the machine was never started
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+// Write sends p to the machine's serial port.
+func (c *Console) Write(p []byte) (int, error) {
+	return c.conn.Write(p)
+}
+
+// Output returns the machine's recent output.
+func (c *Console) Output() []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]byte(nil), c.output...)
+}
+
+// Attach copies the output so far, and all further output, to w until
+// the returned function is called.  Only one writer can be attached.
+func (c *Console) Attach(w io.Writer) (detach func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w.Write(c.output)
+	c.tee = w
+	return func() {
+		c.lock.Lock()
+		c.tee = nil
+		c.lock.Unlock()
+	}
+}
+
+// Expect waits for re to match the output after its first offset bytes.
+// It returns the match and its subexpressions, and the offset of the end
+// of the match.  Offsets count all output since boot, including any
+// which has been dropped.
+func (c *Console) Expect(re *regexp.Regexp, offset int, timeout time.Duration) ([]string, int, error) {
+	deadline := time.After(timeout)
+	for {
+		c.lock.Lock()
+		start := offset - c.dropped
+		if start < 0 {
+			start = 0
+		} else if start > len(c.output) {
+			start = len(c.output)
+		}
+		offset = c.dropped + start
+		loc := re.FindSubmatchIndex(c.output[start:])
+		var match []string
+		if loc != nil {
+			for i := 0; i < len(loc); i += 2 {
+				if loc[i] < 0 {
+					match = append(match, "")
+				} else {
+					match = append(match, string(c.output[start+loc[i]:start+loc[i+1]]))
+				}
+			}
+		}
+		changed, err := c.changed, c.err
+		c.lock.Unlock()
+
+		if loc != nil {
+			return match, offset + loc[1], nil
+		}
+		if err != nil {
+			return nil, offset, fmt.Errorf("console closed before %q appeared: %v", re, err)
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil, offset, fmt.Errorf("timed out waiting for %q on console", re)
+		}
+	}
+}
+
+// Started closes QEMU's end of the console once QEMU holds its own copy,
+// so that reading stops, and Expect fails, when QEMU exits.
+func (c *Console) Started() {
+	c.qemuEnd.Close()
+}
+
+// Close disconnects from the console.
+func (c *Console) Close() {
+	c.conn.Close()
+	c.qemuEnd.Close()
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestConsoleExpect(t *testing.T) {
+	c, qemuEnd, err := newConsole()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go func() {
+		qemuEnd.Write([]byte("GNU GRUB\n"))
+		time.Sleep(10 * time.Millisecond)
+		qemuEnd.Write([]byte("localhost login: "))
+	}()
+
+	match, offset, err := c.Expect(regexp.MustCompile(`(\w+) login: `), 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(match) != 2 || match[1] != "localhost" {
+		t.Errorf("bad match %q", match)
+	}
+	if offset != len("GNU GRUB\nlocalhost login: ") {
+		t.Errorf("bad offset %d", offset)
+	}
+
+	// earlier output isn't matched again
+	if _, _, err := c.Expect(regexp.MustCompile("GRUB"), offset, 10*time.Millisecond); err == nil {
+		t.Error("matched output before offset")
+	}
+
+	if _, err := c.Write([]byte("core\r")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(qemuEnd).ReadString('\r')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "core\r" {
+		t.Errorf("QEMU read %q", line)
+	}
+}
+
+func TestConsoleClosed(t *testing.T) {
+	c, qemuEnd, err := newConsole()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var buf bytes.Buffer
+	detach := c.Attach(&buf)
+	qemuEnd.Write([]byte("bye\n"))
+	qemuEnd.Close()
+
+	if _, _, err := c.Expect(regexp.MustCompile("never"), 0, time.Second); err == nil {
+		t.Fatal("expected error after QEMU exited")
+	}
+	detach()
+	if buf.String() != "bye\n" {
+		t.Errorf("attached writer got %q", buf.String())
+	}
+}
+
+func TestConsoleDropsOldOutput(t *testing.T) {
+	c, qemuEnd, err := newConsole()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go func() {
+		chunk := bytes.Repeat([]byte("x"), 64<<10)
+		for written := 0; written <= maxConsoleOutput; written += len(chunk) {
+			qemuEnd.Write(chunk)
+		}
+		qemuEnd.Write([]byte("login: "))
+		c.Started()
+	}()
+
+	_, offset, err := c.Expect(regexp.MustCompile("login: "), 0, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset <= maxConsoleOutput {
+		t.Errorf("offset %d doesn't count dropped output", offset)
+	}
+	if n := len(c.Output()); n > maxConsoleOutput {
+		t.Errorf("kept %d bytes of output", n)
+	}
+	if _, _, err := c.Expect(regexp.MustCompile("never"), offset, time.Second); err == nil {
+		t.Error("expected error after QEMU's end was closed")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Operations a DiskFault applies to.
+const (
+	DiskFaultAny   = ""
+	DiskFaultRead  = "read"
+	DiskFaultWrite = "write"
+)
+
+// maxDiskFaultAfter bounds DiskFault.After, since blkdebug counts with
+// one rule per operation.
+const maxDiskFaultAfter = 10000
+
+// DiskFault makes I/O to a QEMU disk fail, using QEMU's blkdebug
+// driver.  The fault is deterministic: it depends only on the order
+// and location of the guest's I/O requests.
+type DiskFault struct {
+	// Op is the kind of operation which fails, DiskFaultRead,
+	// DiskFaultWrite or DiskFaultAny.
+	Op string
+	// After is the number of Op operations which succeed before the
+	// fault starts.
+	After int
+	// Sectors restricts the fault to requests touching these 512-byte
+	// sectors.  If empty, all requests fail.
+	Sectors []int64
+	// Errno is the error returned to the guest.  The default is EIO.
+	Errno syscall.Errno
+	// Once makes the fault clear after the first failed request.
+	Once bool
+}
+
+// ReadOnlyAfter returns a fault which makes a disk refuse writes, as if
+// it had flipped to read-only, after n writes.
+func ReadOnlyAfter(n int) *DiskFault {
+	return &DiskFault{
+		Op:    DiskFaultWrite,
+		After: n,
+		Errno: syscall.EROFS,
+	}
+}
+
+func (f *DiskFault) events() ([]string, error) {
+	switch f.Op {
+	case DiskFaultAny:
+		return []string{"read_aio", "write_aio"}, nil
+	case DiskFaultRead:
+		return []string{"read_aio"}, nil
+	case DiskFaultWrite:
+		return []string{"write_aio"}, nil
+	default:
+		return nil, fmt.Errorf("unknown disk fault operation %q", f.Op)
+	}
+}
+
+// blkdebugConfig returns the blkdebug configuration for the fault.
+// blkdebug starts in state 1; each counted operation advances the
+// state, and the errors are injected in state After+1.
+func (f *DiskFault) blkdebugConfig() ([]byte, error) {
+	events, err := f.events()
+	if err != nil {
+		return nil, err
+	}
+	if f.After < 0 || f.After > maxDiskFaultAfter {
+		return nil, fmt.Errorf("disk fault After must be between 0 and %d", maxDiskFaultAfter)
+	}
+	errno := f.Errno
+	if errno == 0 {
+		errno = syscall.EIO
+	}
+	once := "off"
+	if f.Once {
+		once = "on"
+	}
+
+	var buf bytes.Buffer
+	for state := 1; state <= f.After; state++ {
+		for _, event := range events {
+			fmt.Fprintf(&buf, "[set-state]\nevent = \"%s\"\nstate = \"%d\"\nnew_state = \"%d\"\n\n",
+				event, state, state+1)
+		}
+	}
+	sectors := f.Sectors
+	if len(sectors) == 0 {
+		// any sector
+		sectors = []int64{-1}
+	}
+	for _, event := range events {
+		for _, sector := range sectors {
+			fmt.Fprintf(&buf, "[inject-error]\nevent = \"%s\"\n", event)
+			if f.After > 0 {
+				fmt.Fprintf(&buf, "state = \"%d\"\n", f.After+1)
+			}
+			fmt.Fprintf(&buf, "errno = \"%d\"\nsector = \"%d\"\nonce = \"%s\"\nimmediately = \"off\"\n\n",
+				int(errno), sector, once)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// setupBlkdebugConfig writes the fault's blkdebug configuration to a
+// deleted file and returns it.  QEMU reads the config with fopen, so it
+// must be passed as /proc/self/fd/N.
+func (f *DiskFault) setupBlkdebugConfig() (*os.File, error) {
+	config, err := f.blkdebugConfig()
+	if err != nil {
+		return nil, err
+	}
+	path, err := mkpath("")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(config); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlkdebugConfig(t *testing.T) {
+	config, err := (&DiskFault{Op: DiskFaultRead, Sectors: []int64{8, 16}, Once: true}).blkdebugConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[inject-error]
+event = "read_aio"
+errno = "5"
+sector = "8"
+once = "on"
+immediately = "off"
+
+[inject-error]
+event = "read_aio"
+errno = "5"
+sector = "16"
+once = "on"
+immediately = "off"
+
+`
+	if string(config) != expected {
+		t.Errorf("got config:\n%s\nexpected:\n%s", config, expected)
+	}
+
+	config, err = ReadOnlyAfter(2).blkdebugConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `[set-state]
+event = "write_aio"
+state = "1"
+new_state = "2"
+
+[set-state]
+event = "write_aio"
+state = "2"
+new_state = "3"
+
+[inject-error]
+event = "write_aio"
+state = "3"
+errno = "30"
+sector = "-1"
+once = "off"
+immediately = "off"
+
+`
+	if string(config) != expected {
+		t.Errorf("got config:\n%s\nexpected:\n%s", config, expected)
+	}
+
+	config, err = (&DiskFault{After: 1}).blkdebugConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(config), "[set-state]"); n != 2 {
+		t.Errorf("got %d set-state rules for reads and writes, expected 2", n)
+	}
+
+	for _, f := range []*DiskFault{{Op: "flush"}, {After: -1}, {After: maxDiskFaultAfter + 1}} {
+		if _, err := f.blkdebugConfig(); err == nil {
+			t.Errorf("invalid fault %+v accepted", f)
+		}
+	}
+}
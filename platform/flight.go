@@ -34,14 +34,17 @@ type BaseFlight struct {
 	ctPlatform string
 	baseopts   *Options
 
-	agent *network.SSHAgent
+	agent   *network.SSHAgent
+	journal *ResourceJournal
 }
 
-func NewBaseFlight(opts *Options, platform Name, ctPlatform string) (*BaseFlight, error) {
-	return NewBaseFlightWithDialer(opts, platform, ctPlatform, network.NewRetryDialer())
+// NewBaseFlight creates a BaseFlight which records the resources it
+// creates in journal, which may be nil.
+func NewBaseFlight(opts *Options, journal *ResourceJournal, platform Name, ctPlatform string) (*BaseFlight, error) {
+	return NewBaseFlightWithDialer(opts, journal, platform, ctPlatform, network.NewRetryDialer())
 }
 
-func NewBaseFlightWithDialer(opts *Options, platform Name, ctPlatform string, dialer network.Dialer) (*BaseFlight, error) {
+func NewBaseFlightWithDialer(opts *Options, journal *ResourceJournal, platform Name, ctPlatform string, dialer network.Dialer) (*BaseFlight, error) {
 	agent, err := network.NewSSHAgent(dialer)
 	if err != nil {
 		return nil, err
@@ -54,6 +57,7 @@ func NewBaseFlightWithDialer(opts *Options, platform Name, ctPlatform string, di
 		ctPlatform: ctPlatform,
 		baseopts:   opts,
 		agent:      agent,
+		journal:    journal,
 	}
 
 	return bf, nil
@@ -93,6 +97,28 @@ func (bf *BaseFlight) Keys() ([]*agent.Key, error) {
 	return bf.agent.List()
 }
 
+// TrackResource records a resource created by the flight in the
+// resource journal, so that it can be cleaned up if kola dies before
+// deleting it.  context holds any details needed to delete it.
+func (bf *BaseFlight) TrackResource(typ, id string, context map[string]string) {
+	err := bf.journal.Add(Resource{
+		Platform: bf.platform,
+		Type:     typ,
+		ID:       id,
+		Context:  context,
+	})
+	if err != nil {
+		plog.Errorf("Error recording %s %s in resource journal: %v", typ, id, err)
+	}
+}
+
+// ReleaseResource marks a resource recorded by TrackResource as deleted.
+func (bf *BaseFlight) ReleaseResource(typ, id string) {
+	if err := bf.journal.Remove(bf.platform, typ, id); err != nil {
+		plog.Errorf("Error removing %s %s from resource journal: %v", typ, id, err)
+	}
+}
+
 // Destroy destroys each Cluster in the Flight and closes the SSH agent.
 func (bf *BaseFlight) Destroy() {
 	for _, c := range bf.Clusters() {
@@ -0,0 +1,290 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource types understood by garbage collection.
+const (
+	GCInstance      = "instance"
+	GCImage         = "image"
+	GCKey           = "key"
+	GCNetwork       = "network"
+	GCResourceGroup = "resource-group"
+	GCSecurityGroup = "security-group"
+)
+
+// GCTypes lists every resource type garbage collection knows about.
+var GCTypes = []string{GCInstance, GCImage, GCKey, GCNetwork, GCResourceGroup, GCSecurityGroup}
+
+// DefaultGCNamePrefix is the name prefix of kola flights and clusters.
+// Resources which can't otherwise be identified as mantle's, such as SSH
+// keys, are only considered if they have this prefix, unless
+// GCOptions.NamePrefix is set.
+const DefaultGCNamePrefix = "kola-"
+
+// defaultGCTypes are collected when GCOptions.Types is empty. Every other
+// type must be requested explicitly, so existing callers only ever delete
+// instances.
+var defaultGCTypes = []string{GCInstance}
+
+// GCOptions controls which resources are considered garbage.
+type GCOptions struct {
+	// GracePeriod is how old a resource must be before it is collected.
+	GracePeriod time.Duration
+	// DryRun lists candidates without deleting anything.
+	DryRun bool
+	// NamePrefix restricts collection to resources whose name starts
+	// with the prefix.  Empty matches everything.
+	NamePrefix string
+	// Tag restricts collection to resources carrying the tag.  On
+	// platforms with key/value tags it is either "key" or "key=value";
+	// on platforms with plain tags it is compared verbatim.
+	Tag string
+	// Types restricts collection to the listed resource types.  If
+	// empty, only instances are collected.
+	Types []string
+}
+
+// Wants reports whether resources of type t should be collected.
+func (o *GCOptions) Wants(t string) bool {
+	types := o.Types
+	if len(types) == 0 {
+		types = defaultGCTypes
+	}
+	for _, want := range types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether a resource created at the given time is older
+// than the grace period.
+func (o *GCOptions) Expired(created time.Time) bool {
+	return !created.After(time.Now().Add(-o.GracePeriod))
+}
+
+// ErrGCUnfiltered is reported for resources which can't be identified
+// as mantle's, when neither a name prefix nor a tag restricts collection.
+var ErrGCUnfiltered = errors.New("refusing to collect resources which can't be identified as mantle's without a name prefix or tag")
+
+// Filtered reports whether collection is restricted by name prefix or
+// tag.
+func (o *GCOptions) Filtered() bool {
+	return o.NamePrefix != "" || o.Tag != ""
+}
+
+// MatchName reports whether name matches the configured name prefix.
+func (o *GCOptions) MatchName(name string) bool {
+	return strings.HasPrefix(name, o.NamePrefix)
+}
+
+// MatchDefaultName reports whether name matches the configured name
+// prefix, falling back to DefaultGCNamePrefix.
+func (o *GCOptions) MatchDefaultName(name string) bool {
+	prefix := o.NamePrefix
+	if prefix == "" {
+		prefix = DefaultGCNamePrefix
+	}
+	return strings.HasPrefix(name, prefix)
+}
+
+// GCTimedName returns the name for a resource created now for the flight
+// called name.  Some platforms don't record when SSH keys or networks
+// were created, so the name records it for NameExpired.
+func GCTimedName(name string) string {
+	return fmt.Sprintf("%s-%d", name, time.Now().Unix())
+}
+
+// NameExpired reports whether a resource of type typ named by
+// GCTimedName is older than the grace period.  A resource without a
+// creation time in its name may belong to a flight which hasn't started
+// its first instance yet, so it is only considered expired if its type
+// was requested explicitly in Types.
+func (o *GCOptions) NameExpired(typ, name string) bool {
+	i := strings.LastIndex(name, "-")
+	suffix := name[i+1:]
+	if secs, err := strconv.ParseInt(suffix, 10, 64); i >= 0 && len(suffix) == 10 && err == nil {
+		return o.Expired(time.Unix(secs, 0))
+	}
+	for _, t := range o.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTags reports whether a key/value tag set satisfies the configured
+// tag filter.
+func (o *GCOptions) MatchTags(tags map[string]string) bool {
+	if o.Tag == "" {
+		return true
+	}
+	parts := strings.SplitN(o.Tag, "=", 2)
+	value, ok := tags[parts[0]]
+	if !ok {
+		return false
+	}
+	return len(parts) == 1 || value == parts[1]
+}
+
+// MatchTagList reports whether a plain tag list satisfies the configured
+// tag filter.
+func (o *GCOptions) MatchTagList(tags []string) bool {
+	if o.Tag == "" {
+		return true
+	}
+	for _, tag := range tags {
+		if tag == o.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GCResource identifies a single resource considered by garbage collection.
+type GCResource struct {
+	Platform string     `json:"platform"`
+	Type     string     `json:"type"`
+	ID       string     `json:"id"`
+	Name     string     `json:"name,omitempty"`
+	Created  *time.Time `json:"created,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+func (r GCResource) String() string {
+	s := r.Platform
+	for _, field := range []string{r.Type, r.ID} {
+		if field != "" {
+			s += " " + field
+		}
+	}
+	if r.Name != "" && r.Name != r.ID {
+		s += fmt.Sprintf(" (%s)", r.Name)
+	}
+	return s
+}
+
+// GCReport records the outcome of a garbage collection run.  It is safe
+// for concurrent use.
+type GCReport struct {
+	lock sync.Mutex
+
+	DryRun     bool         `json:"dryRun"`
+	Candidates []GCResource `json:"candidates,omitempty"`
+	Deleted    []GCResource `json:"deleted"`
+	Failed     []GCResource `json:"failed"`
+	// Skipped lists platforms that were not collected and why.
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+// NewGCReport returns an empty report for the given options.
+func NewGCReport(opts *GCOptions) *GCReport {
+	return &GCReport{
+		DryRun:  opts.DryRun,
+		Deleted: []GCResource{},
+		Failed:  []GCResource{},
+	}
+}
+
+// Collect records res as garbage and, unless this is a dry run, calls
+// del to delete it.
+func (r *GCReport) Collect(res GCResource, del func() error) {
+	if r.DryRun {
+		plog.Infof("gc: would delete %v", res)
+		r.lock.Lock()
+		r.Candidates = append(r.Candidates, res)
+		r.lock.Unlock()
+		return
+	}
+
+	err := del()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err != nil {
+		plog.Errorf("gc: failed deleting %v: %v", res, err)
+		res.Error = err.Error()
+		r.Failed = append(r.Failed, res)
+		return
+	}
+	plog.Infof("gc: deleted %v", res)
+	r.Deleted = append(r.Deleted, res)
+}
+
+// Fail records a failure that prevented collecting resources of the
+// given type, such as a failed listing call.  typ is empty if the whole
+// platform couldn't be collected.
+func (r *GCReport) Fail(platform, typ string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	plog.Errorf("gc: %s %s: %v", platform, typ, err)
+	r.Failed = append(r.Failed, GCResource{
+		Platform: platform,
+		Type:     typ,
+		Error:    err.Error(),
+	})
+}
+
+// Skip records that a platform was not collected.
+func (r *GCReport) Skip(platform, reason string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.Skipped == nil {
+		r.Skipped = make(map[string]string)
+	}
+	r.Skipped[platform] = reason
+}
+
+// Merge appends the contents of other to the report.
+func (r *GCReport) Merge(other *GCReport) {
+	other.lock.Lock()
+	defer other.lock.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Candidates = append(r.Candidates, other.Candidates...)
+	r.Deleted = append(r.Deleted, other.Deleted...)
+	r.Failed = append(r.Failed, other.Failed...)
+	for platform, reason := range other.Skipped {
+		if r.Skipped == nil {
+			r.Skipped = make(map[string]string)
+		}
+		r.Skipped[platform] = reason
+	}
+}
+
+// Err returns an error summarizing any failures in the report.
+func (r *GCReport) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(r.Failed))
+	for _, res := range r.Failed {
+		msgs = append(msgs, fmt.Sprintf("%v: %s", res, res.Error))
+	}
+	return fmt.Errorf("%d resources failed: %s", len(r.Failed), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNameExpired(t *testing.T) {
+	old := fmt.Sprintf("kola-flight-%d", time.Now().Add(-2*time.Hour).Unix())
+	for _, tt := range []struct {
+		name     string
+		types    []string
+		expected bool
+	}{
+		{GCTimedName("kola-flight"), nil, false},
+		{old, nil, true},
+		{"kola-2b4a1c3e-0b7e-4f4e-9d1a-5e6f7a8b9c0d", nil, false},
+		{"kola-2b4a1c3e-0b7e-4f4e-9d1a-5e6f7a8b9c0d", []string{GCKey}, true},
+		{"kola-2b4a1c3e-0b7e-4f4e-9d1a-123456789012", nil, false},
+	} {
+		opts := GCOptions{GracePeriod: time.Hour, Types: tt.types}
+		if expired := opts.NameExpired(GCKey, tt.name); expired != tt.expected {
+			t.Errorf("NameExpired(key, %q) with types %v = %v, expected %v", tt.name, tt.types, expired, tt.expected)
+		}
+	}
+}
+
+func TestWants(t *testing.T) {
+	for _, tt := range []struct {
+		types    []string
+		typ      string
+		expected bool
+	}{
+		{nil, GCInstance, true},
+		{nil, GCKey, false},
+		{nil, GCNetwork, false},
+		{nil, GCResourceGroup, false},
+		{nil, GCImage, false},
+		{[]string{GCKey, GCNetwork}, GCKey, true},
+		{[]string{GCKey, GCNetwork}, GCInstance, false},
+	} {
+		opts := GCOptions{Types: tt.types}
+		if wants := opts.Wants(tt.typ); wants != tt.expected {
+			t.Errorf("Wants(%q) with types %v = %v, expected %v", tt.typ, tt.types, wants, tt.expected)
+		}
+	}
+}
+
+func TestGCResourceString(t *testing.T) {
+	for _, tt := range []struct {
+		res      GCResource
+		expected string
+	}{
+		{GCResource{Platform: "aws", Type: GCInstance, ID: "i-0123", Name: "kola-a"}, "aws instance i-0123 (kola-a)"},
+		{GCResource{Platform: "aws", Type: GCKey, ID: "kola-key", Name: "kola-key"}, "aws key kola-key"},
+		{GCResource{Platform: "aws", Type: GCImage}, "aws image"},
+		{GCResource{Platform: "aws"}, "aws"},
+	} {
+		if s := tt.res.String(); s != tt.expected {
+			t.Errorf("String() = %q, expected %q", s, tt.expected)
+		}
+	}
+}
+
+func TestGCResourceCreated(t *testing.T) {
+	data, err := json.Marshal(GCResource{Platform: "aws", Type: GCKey, ID: "kola-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "created") {
+		t.Errorf("resource without a creation time encoded as %s", data)
+	}
+}
@@ -106,7 +106,7 @@ func (j *Journal) Start(ctx context.Context, m Machine) error {
 	}
 
 	// Retry for a while because this should be run before CheckMachine
-	if err := util.Retry(sshRetries, sshTimeout, start); err != nil {
+	if err := util.Retry(sshRetriesFor(m), sshTimeout, start); err != nil {
 		cancel()
 		return fmt.Errorf("ssh journalctl failed: %v", err)
 	}
@@ -22,7 +22,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/coreos/go-omaha/omaha"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 
@@ -36,8 +38,16 @@ import (
 type LocalCluster struct {
 	destructor.MultiDestructor
 	*platform.BaseCluster
-	flight      *LocalFlight
-	OmahaServer OmahaWrapper
+	flight       *LocalFlight
+	OmahaServer  OmahaWrapper
+	ConfigServer *ConfigServer
+
+	faultLock sync.Mutex
+	links     map[string]machineLink
+	rules     []*faultRule
+
+	segmentLock sync.Mutex
+	segments    map[string]*clusterSegment
 }
 
 func (lc *LocalCluster) NewCommand(name string, arg ...string) exec.Cmd {
@@ -93,6 +103,14 @@ func (lc *LocalCluster) GetOmahaHostPort() (string, error) {
 	return net.JoinHostPort(lc.hostIP(), port), nil
 }
 
+func (lc *LocalCluster) SetOmahaUpdater(u omaha.Updater) {
+	lc.OmahaServer.Updater = u
+}
+
+func (lc *LocalCluster) NTPServerIP() string {
+	return lc.hostIP()
+}
+
 func (lc *LocalCluster) NewTap(bridge string) (*TunTap, error) {
 	nsExit, err := ns.Enter(lc.flight.nshandle)
 	if err != nil {
@@ -15,10 +15,14 @@
 package local
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/coreos/mantle/platform/conf"
+	"github.com/coreos/mantle/system/exec"
 )
 
 // MakeConfigDrive creates a config drive directory tree under outputDir
@@ -39,3 +43,65 @@ func MakeConfigDrive(userdata *conf.Conf, outputDir string) (string, error) {
 
 	return drivePath, nil
 }
+
+// MakeConfigDriveISO creates a config drive ISO image in outputDir and
+// returns its path.
+func MakeConfigDriveISO(userdata *conf.Conf, outputDir string) (string, error) {
+	drivePath, err := MakeConfigDrive(userdata, outputDir)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(drivePath)
+
+	return makeISO(drivePath, "config-2", path.Join(outputDir, "config-2.iso"))
+}
+
+// MakeNoCloudISO creates a cloud-init NoCloud seed ISO image in
+// outputDir and returns its path.  instanceID is used as the instance
+// ID and hostname.
+func MakeNoCloudISO(userdata *conf.Conf, instanceID, outputDir string) (string, error) {
+	seedPath := path.Join(outputDir, "cidata")
+	defer os.RemoveAll(seedPath)
+	if err := writeNoCloudSeed(userdata, instanceID, seedPath); err != nil {
+		return "", err
+	}
+
+	return makeISO(seedPath, "cidata", path.Join(outputDir, "cidata.iso"))
+}
+
+// writeNoCloudSeed writes the user-data and meta-data files of a
+// NoCloud seed to seedPath.
+func writeNoCloudSeed(userdata *conf.Conf, instanceID, seedPath string) error {
+	if err := os.MkdirAll(seedPath, 0777); err != nil {
+		return err
+	}
+	if err := userdata.WriteFile(path.Join(seedPath, "user-data")); err != nil {
+		return err
+	}
+	metadata := fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", instanceID, instanceID)
+	return ioutil.WriteFile(path.Join(seedPath, "meta-data"), []byte(metadata), 0666)
+}
+
+// isoTool returns the first available tool which can create ISO
+// images, or "" if there is none.
+func isoTool() string {
+	for _, tool := range []string{"genisoimage", "mkisofs", "xorrisofs"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			return tool
+		}
+	}
+	return ""
+}
+
+// makeISO creates an ISO 9660 image of dir with the given volume label.
+func makeISO(dir, label, isoPath string) (string, error) {
+	tool := isoTool()
+	if tool == "" {
+		return "", fmt.Errorf("creating %s image requires genisoimage, mkisofs or xorrisofs", label)
+	}
+	cmd := exec.Command(tool, "-output", isoPath, "-volid", label, "-joliet", "-rock", dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", tool, err, strings.TrimSpace(string(out)))
+	}
+	return isoPath, nil
+}
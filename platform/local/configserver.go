@@ -0,0 +1,96 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ConfigServer serves machine configs over HTTP, so that Ignition's
+// remote config fetching can be tested on local platforms.
+type ConfigServer struct {
+	// Host is the server's address as seen by machines.  It defaults
+	// to the listening address.
+	Host string
+
+	listener net.Listener
+	server   http.Server
+
+	lock    sync.Mutex
+	configs map[string][]byte
+}
+
+// NewConfigServer listens on addr and starts serving.
+func NewConfigServer(addr string) (*ConfigServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &ConfigServer{
+		listener: listener,
+		configs:  make(map[string][]byte),
+	}
+	cs.Host, _, _ = net.SplitHostPort(listener.Addr().String())
+	cs.server.Handler = cs
+	go cs.server.Serve(listener)
+
+	return cs, nil
+}
+
+// Port returns the port the server listens on.
+func (cs *ConfigServer) Port() int {
+	return cs.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Add publishes the config of the machine with the given ID and returns
+// the URL machines can fetch it from.
+func (cs *ConfigServer) Add(id string, data []byte) string {
+	path := "/" + id
+	cs.lock.Lock()
+	cs.configs[path] = data
+	cs.lock.Unlock()
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(cs.Host, fmt.Sprint(cs.Port())), path)
+}
+
+// Remove withdraws the config of the machine with the given ID, if any.
+func (cs *ConfigServer) Remove(id string) {
+	cs.lock.Lock()
+	delete(cs.configs, "/"+id)
+	cs.lock.Unlock()
+}
+
+func (cs *ConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cs.lock.Lock()
+	data, ok := cs.configs[r.URL.Path]
+	cs.lock.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	plog.Debugf("Serving config %s to %s", r.URL.Path, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Destroy stops the server.
+func (cs *ConfigServer) Destroy() {
+	if err := cs.server.Close(); err != nil {
+		plog.Errorf("Error closing config server: %v", err)
+	}
+}
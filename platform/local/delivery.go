@@ -0,0 +1,73 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+)
+
+// WriteQEMUConfig writes conf under dir in the form needed by delivery
+// and returns the concrete delivery method and the path to pass to
+// platform.CreateQEMUCommand.  server is only needed for
+// platform.ConfigDeliveryHTTP.  By default, Ignition configs use fw_cfg
+// and anything else, even an empty config, a 9p config drive.
+func WriteQEMUConfig(conf *conf.Conf, dir, id string, delivery platform.ConfigDelivery, server *ConfigServer) (platform.ConfigDelivery, string, error) {
+	if delivery == platform.ConfigDeliveryDefault {
+		switch {
+		case conf.IsIgnition():
+			delivery = platform.ConfigDeliveryFwCfg
+		default:
+			delivery = platform.ConfigDelivery9p
+		}
+	}
+
+	switch delivery {
+	case platform.ConfigDeliveryFwCfg:
+		if !conf.IsIgnition() {
+			return "", "", fmt.Errorf("fw_cfg only supports Ignition configs")
+		}
+		confPath := filepath.Join(dir, "ignition.json")
+		return delivery, confPath, conf.WriteFile(confPath)
+	case platform.ConfigDeliveryHTTP:
+		if server == nil {
+			return "", "", fmt.Errorf("no config server available")
+		}
+		pointer, err := conf.IgnitionPointer(server.Add(id, conf.Bytes()))
+		if err != nil {
+			return "", "", err
+		}
+		// keep the real config with the machine's other output
+		if err := conf.WriteFile(filepath.Join(dir, "ignition.json")); err != nil {
+			return "", "", err
+		}
+		confPath := filepath.Join(dir, "ignition-pointer.json")
+		return delivery, confPath, ioutil.WriteFile(confPath, pointer, 0666)
+	case platform.ConfigDelivery9p:
+		confPath, err := MakeConfigDrive(conf, dir)
+		return delivery, confPath, err
+	case platform.ConfigDeliveryConfigDrive:
+		confPath, err := MakeConfigDriveISO(conf, dir)
+		return delivery, confPath, err
+	case platform.ConfigDeliveryNoCloud:
+		confPath, err := MakeNoCloudISO(conf, id, dir)
+		return delivery, confPath, err
+	}
+	return "", "", fmt.Errorf("unsupported config delivery %q", delivery)
+}
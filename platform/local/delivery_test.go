@@ -0,0 +1,109 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+)
+
+func TestWriteQEMUConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-delivery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		name      string
+		userdata  *conf.UserData
+		requested platform.ConfigDelivery
+		delivery  platform.ConfigDelivery
+		path      string
+		ok        bool
+	}{
+		{"ignition", conf.Ignition(`{"ignition": {"version": "2.0.0"}}`), platform.ConfigDeliveryDefault, platform.ConfigDeliveryFwCfg, "ignition.json", true},
+		{"cloud-config", conf.CloudConfig("#cloud-config\n"), platform.ConfigDeliveryDefault, platform.ConfigDelivery9p, "config-2", true},
+		{"empty", conf.Empty(), platform.ConfigDeliveryDefault, platform.ConfigDelivery9p, "config-2", true},
+		{"cloud-config over fw_cfg", conf.CloudConfig("#cloud-config\n"), platform.ConfigDeliveryFwCfg, "", "", false},
+		{"http without a server", conf.Ignition(`{"ignition": {"version": "2.0.0"}}`), platform.ConfigDeliveryHTTP, "", "", false},
+		{"config drive", conf.CloudConfig("#cloud-config\n"), platform.ConfigDeliveryConfigDrive, platform.ConfigDeliveryConfigDrive, "config-2.iso", true},
+		{"nocloud", conf.CloudConfig("#cloud-config\n"), platform.ConfigDeliveryNoCloud, platform.ConfigDeliveryNoCloud, "cidata.iso", true},
+	} {
+		if filepath.Ext(tt.path) == ".iso" && isoTool() == "" {
+			t.Logf("%s: skipping, no tool to create ISO images", tt.name)
+			continue
+		}
+		c, err := tt.userdata.Render("")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		machineDir := filepath.Join(dir, tt.name)
+		if err := os.Mkdir(machineDir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		delivery, path, err := WriteQEMUConfig(c, machineDir, "id", tt.requested, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if delivery != tt.delivery {
+			t.Errorf("%s: got delivery %q, expected %q", tt.name, delivery, tt.delivery)
+		}
+		if expected := filepath.Join(machineDir, tt.path); path != expected {
+			t.Errorf("%s: got path %q, expected %q", tt.name, path, expected)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestWriteNoCloudSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-nocloud")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := conf.CloudConfig("#cloud-config\nhostname: core1\n").Render("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeNoCloudSeed(c, "id", dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"user-data": c.String(),
+		"meta-data": "instance-id: id\nlocal-hostname: id\n",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(data) != expected {
+			t.Errorf("%s is %q, expected %q", name, data, expected)
+		}
+	}
+}
@@ -38,16 +38,44 @@ type Segment struct {
 	BridgeIf   *Interface
 	Interfaces []*Interface
 	nextIf     int
+
+	// HostIfName is the interface dnsmasq serves the segment on: the
+	// bridge, or a VLAN on top of it.
+	HostIfName string
+	nextStatic uint16
+	num        byte
+}
+
+// SegmentOptions describes a network segment.
+type SegmentOptions struct {
+	// IPv6Only leaves out IPv4 addressing.
+	IPv6Only bool
+	// VLAN is the 802.1Q tag of the segment's traffic.  Zero is
+	// untagged.
+	VLAN int
 }
 
 type Dnsmasq struct {
 	Segments []*Segment
+	PXE      *DnsmasqPXE
 	dnsmasq  *exec.ExecCmd
 }
 
+// DnsmasqPXE configures network booting.  iPXE clients, including
+// QEMU's NIC boot ROMs, are sent to an HTTP server on the bridge.
+type DnsmasqPXE struct {
+	// HTTPPort is the port of the server on the bridge which serves
+	// /boot.ipxe.
+	HTTPPort int
+	// TFTPRoot is a directory containing undionly.kpxe, which is
+	// served to PXE clients without iPXE.  Optional.
+	TFTPRoot string
+}
+
 const (
-	numInterfaces = 500 // affects dnsmasq startup time
-	numSegments   = 1
+	numInterfaces        = 500 // affects dnsmasq startup time
+	numClusterInterfaces = 100
+	numSegments          = 1
 
 	debugConfig = `
 log-queries
@@ -70,6 +98,13 @@ no-resolv
 no-hosts
 enable-ra
 
+# other dnsmasq instances serve the segments of clusters
+bind-interfaces
+except-interface=lo
+{{range .Segments}}
+interface={{.HostIfName}}
+{{end}}
+
 # point NTP at this host (0.0.0.0 and :: are special)
 dhcp-option=option:ntp-server,0.0.0.0
 dhcp-option=option6:ntp-server,[::]
@@ -88,6 +123,21 @@ dhcp-range={{.IP}},ra-names,slaac
 {{range .Interfaces}}
 dhcp-host={{.HardwareAddr}}{{template "ips" .DHCPv4}}{{template "ips" .DHCPv6}}
 {{end}}
+
+{{if $.PXE}}
+{{range .BridgeIf.DHCPv4}}
+dhcp-boot=tag:ipxe,http://{{.IP}}:{{$.PXE.HTTPPort}}/boot.ipxe
+{{end}}
+{{end}}
+{{end}}
+
+{{if .PXE}}
+dhcp-userclass=set:ipxe,iPXE
+{{if .PXE.TFTPRoot}}
+enable-tftp
+tftp-root={{.PXE.TFTPRoot}}
+dhcp-boot=tag:!ipxe,undionly.kpxe
+{{end}}
 {{end}}
 
 {{define "ips"}}{{range .}}{{printf ",%s" .IP}}{{end}}{{end}}
@@ -108,16 +158,32 @@ func newInterface(s byte, i uint16) *Interface {
 	}
 }
 
-func newSegment(s byte) (*Segment, error) {
+// planSegment returns the addresses of segment s with numIf DHCP
+// interfaces, without creating it.
+func planSegment(s byte, numIf uint16, opts SegmentOptions) *Segment {
 	seg := &Segment{
 		BridgeName: fmt.Sprintf("br%d", s),
 		BridgeIf:   newInterface(s, 1),
+		nextStatic: 2 + numIf,
+		num:        s,
 	}
 
-	for i := uint16(2); i < 2+numInterfaces; i++ {
+	for i := uint16(2); i < 2+numIf; i++ {
 		seg.Interfaces = append(seg.Interfaces, newInterface(s, i))
 	}
 
+	if opts.IPv6Only {
+		seg.BridgeIf.DHCPv4 = nil
+		for _, in := range seg.Interfaces {
+			in.DHCPv4 = nil
+		}
+	}
+	return seg
+}
+
+func newSegment(s byte, numIf uint16, opts SegmentOptions) (*Segment, error) {
+	seg := planSegment(s, numIf, opts)
+
 	br := netlink.Bridge{
 		LinkAttrs: netlink.LinkAttrs{
 			Name:         seg.BridgeName,
@@ -129,31 +195,90 @@ func newSegment(s byte) (*Segment, error) {
 		return nil, fmt.Errorf("LinkAdd() failed: %v", err)
 	}
 
-	for _, addr := range seg.BridgeIf.DHCPv4 {
+	var hostIf netlink.Link = &br
+	seg.HostIfName = seg.BridgeName
+	if opts.VLAN != 0 {
+		if opts.VLAN < 1 || opts.VLAN > 4094 {
+			netlink.LinkDel(&br)
+			return nil, fmt.Errorf("invalid VLAN ID %d", opts.VLAN)
+		}
+		seg.HostIfName = fmt.Sprintf("%s.%d", seg.BridgeName, opts.VLAN)
+		vlan := netlink.Vlan{
+			LinkAttrs: netlink.LinkAttrs{
+				Name:        seg.HostIfName,
+				ParentIndex: br.Index,
+			},
+			VlanId: opts.VLAN,
+		}
+		if err := netlink.LinkAdd(&vlan); err != nil {
+			netlink.LinkDel(&br)
+			return nil, fmt.Errorf("VLAN LinkAdd() failed: %v", err)
+		}
+		if err := netlink.LinkSetUp(&br); err != nil {
+			netlink.LinkDel(&br)
+			return nil, fmt.Errorf("LinkSetUp() failed: %v", err)
+		}
+		hostIf = &vlan
+	}
+
+	if err := setupHostIf(hostIf, seg.BridgeIf); err != nil {
+		netlink.LinkDel(&br)
+		return nil, err
+	}
+
+	return seg, nil
+}
+
+func setupHostIf(link netlink.Link, in *Interface) error {
+	for _, addr := range in.DHCPv4 {
 		nladdr := netlink.Addr{IPNet: &addr}
-		if err := netlink.AddrAdd(&br, &nladdr); err != nil {
-			return nil, fmt.Errorf("DHCPv4 AddrAdd() failed: %v", err)
+		if err := netlink.AddrAdd(link, &nladdr); err != nil {
+			return fmt.Errorf("DHCPv4 AddrAdd() failed: %v", err)
 		}
 	}
 
-	for _, addr := range seg.BridgeIf.DHCPv6 {
+	for _, addr := range in.DHCPv6 {
 		nladdr := netlink.Addr{IPNet: &addr}
-		if err := netlink.AddrAdd(&br, &nladdr); err != nil {
-			return nil, fmt.Errorf("DHCPv6 AddrAdd() failed: %v", err)
+		if err := netlink.AddrAdd(link, &nladdr); err != nil {
+			return fmt.Errorf("DHCPv6 AddrAdd() failed: %v", err)
 		}
 	}
 
-	if err := netlink.LinkSetUp(&br); err != nil {
-		return nil, fmt.Errorf("LinkSetUp() failed: %v", err)
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("LinkSetUp() failed: %v", err)
 	}
+	return nil
+}
 
-	return seg, nil
+// GetInterface reserves an interface on the segment.  dnsmasq ignores
+// static interfaces, so machines must configure their addresses.
+func (seg *Segment) GetInterface(static bool) (*Interface, error) {
+	if !static {
+		if seg.nextIf >= len(seg.Interfaces) {
+			return nil, fmt.Errorf("no interfaces left on %s", seg.BridgeName)
+		}
+		in := seg.Interfaces[seg.nextIf]
+		seg.nextIf++
+		return in, nil
+	}
+
+	if seg.nextStatic == 0xffff {
+		return nil, fmt.Errorf("no static interfaces left on %s", seg.BridgeName)
+	}
+	in := newInterface(seg.num, seg.nextStatic)
+	seg.nextStatic++
+	if len(seg.BridgeIf.DHCPv4) == 0 {
+		in.DHCPv4 = nil
+	}
+	return in, nil
 }
 
-func NewDnsmasq() (*Dnsmasq, error) {
-	dm := &Dnsmasq{}
+// NewDnsmasq creates the flight's network and starts dnsmasq on it.
+// pxe enables network booting, if non-nil.
+func NewDnsmasq(pxe *DnsmasqPXE) (*Dnsmasq, error) {
+	dm := &Dnsmasq{PXE: pxe}
 	for s := byte(0); s < numSegments; s++ {
-		seg, err := newSegment(s)
+		seg, err := newSegment(s, numInterfaces, SegmentOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("Network setup failed: %v", err)
 		}
@@ -170,21 +295,28 @@ func NewDnsmasq() (*Dnsmasq, error) {
 		return nil, fmt.Errorf("Network loopback setup failed: %v", err)
 	}
 
+	if err := dm.start(); err != nil {
+		return nil, err
+	}
+	return dm, nil
+}
+
+func (dm *Dnsmasq) start() error {
 	dm.dnsmasq = exec.Command("dnsmasq", "--conf-file=-")
 	cfg, err := dm.dnsmasq.StdinPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	out, err := dm.dnsmasq.StdoutPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	dm.dnsmasq.Stderr = dm.dnsmasq.Stdout
 	go util.LogFrom(capnslog.INFO, out)
 
 	if err = dm.dnsmasq.Start(); err != nil {
 		cfg.Close()
-		return nil, err
+		return err
 	}
 
 	var configTemplate *template.Template
@@ -200,22 +332,21 @@ func NewDnsmasq() (*Dnsmasq, error) {
 	if err = configTemplate.Execute(cfg, dm); err != nil {
 		cfg.Close()
 		dm.Destroy()
-		return nil, err
+		return err
 	}
 	cfg.Close()
 
-	return dm, nil
+	return nil
 }
 
-func (dm *Dnsmasq) GetInterface(bridge string) (in *Interface) {
+func (dm *Dnsmasq) GetInterface(bridge string) *Interface {
 	for _, seg := range dm.Segments {
 		if bridge == seg.BridgeName {
-			if seg.nextIf >= len(seg.Interfaces) {
+			in, err := seg.GetInterface(false)
+			if err != nil {
 				panic("Not enough interfaces!")
 			}
-			in = seg.Interfaces[seg.nextIf]
-			seg.nextIf++
-			return
+			return in
 		}
 	}
 	panic("Not a valid bridge!")
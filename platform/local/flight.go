@@ -17,6 +17,7 @@ package local
 
 import (
 	"fmt"
+	"sync"
 	"sync/atomic"
 
 	"github.com/coreos/go-omaha/omaha"
@@ -37,20 +38,28 @@ type LocalFlight struct {
 	destructor.MultiDestructor
 	*platform.BaseFlight
 	Dnsmasq    *Dnsmasq
+	PXEServer  *PXEServer // nil unless the flight serves network boots
 	SimpleEtcd *SimpleEtcd
 	NTPServer  *ntp.Server
 	nshandle   netns.NsHandle
 	listenPort int32
+
+	// segmentLock protects usedSegments, the numbers of clusters'
+	// segments, which are reused once freed
+	segmentLock  sync.Mutex
+	usedSegments map[byte]bool
 }
 
-func NewLocalFlight(opts *platform.Options, platformName platform.Name) (*LocalFlight, error) {
+// NewLocalFlight creates a flight's network namespace and services.  If
+// pxe is set, it also serves network boots.
+func NewLocalFlight(opts *platform.Options, journal *platform.ResourceJournal, platformName platform.Name, pxe bool) (*LocalFlight, error) {
 	nshandle, err := ns.Create()
 	if err != nil {
 		return nil, err
 	}
 
 	nsdialer := network.NewNsDialer(nshandle)
-	bf, err := platform.NewBaseFlightWithDialer(opts, platformName, "", nsdialer)
+	bf, err := platform.NewBaseFlightWithDialer(opts, journal, platformName, "", nsdialer)
 	if err != nil {
 		nshandle.Close()
 		return nil, err
@@ -72,12 +81,29 @@ func NewLocalFlight(opts *platform.Options, platformName platform.Name) (*LocalF
 	}
 	defer nsExit()
 
-	lf.Dnsmasq, err = NewDnsmasq()
+	var dnsmasqPXE *DnsmasqPXE
+	if pxe {
+		lf.PXEServer, err = NewPXEServer(fmt.Sprintf(":%d", lf.newListenPort()))
+		if err != nil {
+			lf.Destroy()
+			return nil, err
+		}
+		lf.AddDestructor(lf.PXEServer)
+		dnsmasqPXE = &DnsmasqPXE{
+			HTTPPort: lf.PXEServer.Port(),
+			TFTPRoot: findIPXE(),
+		}
+	}
+
+	lf.Dnsmasq, err = NewDnsmasq(dnsmasqPXE)
 	if err != nil {
 		lf.Destroy()
 		return nil, err
 	}
 	lf.AddDestructor(lf.Dnsmasq)
+	if lf.PXEServer != nil {
+		lf.PXEServer.Host = lf.Dnsmasq.Segments[0].BridgeIf.DHCPv4[0].IP.String()
+	}
 
 	lf.SimpleEtcd, err = NewSimpleEtcd()
 	if err != nil {
@@ -127,6 +153,14 @@ func (lf *LocalFlight) NewCluster(rconf *platform.RuntimeConfig) (*LocalCluster,
 	lc.AddDestructor(lc.OmahaServer)
 	go lc.OmahaServer.Serve()
 
+	lc.ConfigServer, err = NewConfigServer(fmt.Sprintf(":%d", lf.newListenPort()))
+	if err != nil {
+		lc.Destroy()
+		return nil, err
+	}
+	lc.ConfigServer.Host = lc.hostIP()
+	lc.AddDestructor(lc.ConfigServer)
+
 	// does not lf.AddCluster() since we are not the top-level object
 
 	return lc, nil
@@ -139,3 +173,26 @@ func (lf *LocalFlight) newListenPort() int {
 func (lf *LocalFlight) Destroy() {
 	lf.MultiDestructor.Destroy()
 }
+
+// allocSegment reserves a number for a cluster's network segment.
+func (lf *LocalFlight) allocSegment() (byte, error) {
+	lf.segmentLock.Lock()
+	defer lf.segmentLock.Unlock()
+	for s := numSegments; s <= 255; s++ {
+		if !lf.usedSegments[byte(s)] {
+			if lf.usedSegments == nil {
+				lf.usedSegments = make(map[byte]bool)
+			}
+			lf.usedSegments[byte(s)] = true
+			return byte(s), nil
+		}
+	}
+	return 0, fmt.Errorf("too many network segments")
+}
+
+// freeSegment releases a number reserved by allocSegment.
+func (lf *LocalFlight) freeSegment(s byte) {
+	lf.segmentLock.Lock()
+	delete(lf.usedSegments, s)
+	lf.segmentLock.Unlock()
+}
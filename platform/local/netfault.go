@@ -0,0 +1,301 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/platform"
+)
+
+// LinkFault describes impairments applied with netem to the traffic a
+// machine receives.  Zero fields are not impaired.
+type LinkFault struct {
+	// Delay is added to each packet, varying by up to Jitter.
+	Delay  time.Duration
+	Jitter time.Duration
+	// Loss is the percentage of packets dropped.
+	Loss float64
+	// Rate limits bandwidth, in tc units such as "1mbit".
+	Rate string
+}
+
+func (f LinkFault) netemArgs() []string {
+	var args []string
+	if f.Delay > 0 || f.Jitter > 0 {
+		args = append(args, "delay", tcTime(f.Delay))
+		if f.Jitter > 0 {
+			args = append(args, tcTime(f.Jitter))
+		}
+	}
+	if f.Loss > 0 {
+		args = append(args, "loss", fmt.Sprintf("%g%%", f.Loss))
+	}
+	if f.Rate != "" {
+		args = append(args, "rate", f.Rate)
+	}
+	return args
+}
+
+func tcTime(d time.Duration) string {
+	return fmt.Sprintf("%dus", d/time.Microsecond)
+}
+
+// machineLink is a machine's connection to the flight's bridge.
+type machineLink struct {
+	tap string
+	mac net.HardwareAddr
+}
+
+// faultRule is a firewall rule installed to inject a fault.
+type faultRule struct {
+	// machines are the IDs of the machines the rule affects
+	machines []string
+	cmd      string
+	chain    string
+	spec     []string
+}
+
+func (r *faultRule) key() string {
+	return r.cmd + " " + r.chain + " " + strings.Join(r.spec, " ")
+}
+
+func (r *faultRule) affects(id string) bool {
+	for _, m := range r.machines {
+		if m == id {
+			return true
+		}
+	}
+	return false
+}
+
+// AddMachineLink records that a machine is attached to the bridge via
+// tap, so that faults can be injected into its network.
+func (lc *LocalCluster) AddMachineLink(id string, tap *TunTap, mac net.HardwareAddr) {
+	lc.faultLock.Lock()
+	defer lc.faultLock.Unlock()
+	if lc.links == nil {
+		lc.links = make(map[string]machineLink)
+	}
+	lc.links[id] = machineLink{
+		tap: tap.LinkAttrs.Name,
+		mac: mac,
+	}
+}
+
+func (lc *LocalCluster) link(m platform.Machine) (machineLink, error) {
+	link, ok := lc.links[m.ID()]
+	if !ok {
+		return machineLink{}, fmt.Errorf("machine %s has no known network link", m.ID())
+	}
+	return link, nil
+}
+
+// run runs a command in the flight's network namespace.
+func (lc *LocalCluster) run(name string, args ...string) error {
+	plog.Debugf("Running %s %q", name, args)
+	out, err := lc.NewCommand(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// SetLinkFault impairs the traffic sent to a machine, replacing any
+// previous impairment.
+func (lc *LocalCluster) SetLinkFault(m platform.Machine, f LinkFault) error {
+	lc.faultLock.Lock()
+	defer lc.faultLock.Unlock()
+	link, err := lc.link(m)
+	if err != nil {
+		return err
+	}
+	netem := f.netemArgs()
+	if len(netem) == 0 {
+		return lc.clearLinkFault(link)
+	}
+	return lc.run("tc", append([]string{"qdisc", "replace", "dev", link.tap, "root", "netem"}, netem...)...)
+}
+
+// ClearLinkFault removes the impairment set by SetLinkFault.
+func (lc *LocalCluster) ClearLinkFault(m platform.Machine) error {
+	lc.faultLock.Lock()
+	defer lc.faultLock.Unlock()
+	link, err := lc.link(m)
+	if err != nil {
+		return err
+	}
+	return lc.clearLinkFault(link)
+}
+
+func (lc *LocalCluster) clearLinkFault(link machineLink) error {
+	// deleting the default qdisc fails, so replace it
+	return lc.run("tc", "qdisc", "replace", "dev", link.tap, "root", "pfifo_fast")
+}
+
+// Partition blocks all traffic between two machines until Heal is
+// called.
+func (lc *LocalCluster) Partition(a, b platform.Machine) error {
+	return lc.partition(a, b, true)
+}
+
+// Heal restores traffic between two machines partitioned by Partition.
+func (lc *LocalCluster) Heal(a, b platform.Machine) error {
+	return lc.partition(a, b, false)
+}
+
+func (lc *LocalCluster) partition(a, b platform.Machine, block bool) error {
+	lc.faultLock.Lock()
+	defer lc.faultLock.Unlock()
+	linkA, err := lc.link(a)
+	if err != nil {
+		return err
+	}
+	linkB, err := lc.link(b)
+	if err != nil {
+		return err
+	}
+	ids := []string{a.ID(), b.ID()}
+	for _, dir := range [][2]string{{linkA.tap, linkB.tap}, {linkB.tap, linkA.tap}} {
+		r := &faultRule{
+			machines: ids,
+			cmd:      "ebtables",
+			chain:    "FORWARD",
+			spec:     []string{"-i", dir[0], "-o", dir[1], "-j", "DROP"},
+		}
+		if err := lc.setRule(r, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlockDNS makes the flight's DNS server ignore queries from a machine,
+// so its lookups time out.
+func (lc *LocalCluster) BlockDNS(m platform.Machine, block bool) error {
+	lc.faultLock.Lock()
+	defer lc.faultLock.Unlock()
+	link, err := lc.link(m)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		for _, proto := range []string{"udp", "tcp"} {
+			if err := lc.setRule(lc.inputRule(m, link, cmd, proto, "53"), block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WithholdDHCP makes the flight's DHCP server ignore requests from a
+// machine, so it can't obtain or renew leases.
+func (lc *LocalCluster) WithholdDHCP(m platform.Machine, withhold bool) error {
+	lc.faultLock.Lock()
+	defer lc.faultLock.Unlock()
+	link, err := lc.link(m)
+	if err != nil {
+		return err
+	}
+	if err := lc.setRule(lc.inputRule(m, link, "iptables", "udp", "67"), withhold); err != nil {
+		return err
+	}
+	return lc.setRule(lc.inputRule(m, link, "ip6tables", "udp", "547"), withhold)
+}
+
+// inputRule returns a rule dropping packets from a machine to a local
+// service on the bridge.
+func (lc *LocalCluster) inputRule(m platform.Machine, link machineLink, cmd, proto, port string) *faultRule {
+	return &faultRule{
+		machines: []string{m.ID()},
+		cmd:      cmd,
+		chain:    "INPUT",
+		spec: []string{
+			"-m", "mac", "--mac-source", link.mac.String(),
+			"-p", proto, "--dport", port,
+			"-j", "DROP",
+		},
+	}
+}
+
+// setRule installs or removes a rule, unless it is already in that
+// state.
+func (lc *LocalCluster) setRule(r *faultRule, install bool) error {
+	key := r.key()
+	for i, existing := range lc.rules {
+		if existing.key() != key {
+			continue
+		}
+		if install {
+			return nil
+		}
+		if err := lc.run(r.cmd, append([]string{"-D", r.chain}, r.spec...)...); err != nil {
+			return err
+		}
+		lc.rules = append(lc.rules[:i], lc.rules[i+1:]...)
+		return nil
+	}
+	if !install {
+		return nil
+	}
+	if err := lc.run(r.cmd, append([]string{"-I", r.chain}, r.spec...)...); err != nil {
+		return err
+	}
+	lc.rules = append(lc.rules, r)
+	return nil
+}
+
+// ClearFaults removes all faults injected into a machine's network.
+func (lc *LocalCluster) ClearFaults(m platform.Machine) error {
+	lc.faultLock.Lock()
+	defer lc.faultLock.Unlock()
+	return lc.clearFaults(m.ID(), true)
+}
+
+func (lc *LocalCluster) clearFaults(id string, clearLink bool) error {
+	var firstErr error
+	for _, r := range append([]*faultRule(nil), lc.rules...) {
+		if !r.affects(id) {
+			continue
+		}
+		if err := lc.setRule(r, false); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if link, ok := lc.links[id]; ok && clearLink {
+		if err := lc.clearLinkFault(link); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// DelMach removes a machine's faults as well as the machine.  The tap
+// device, and with it any netem qdisc, is gone once the machine is, but
+// tap names are reused so firewall rules must be removed.
+func (lc *LocalCluster) DelMach(m platform.Machine) {
+	lc.faultLock.Lock()
+	if err := lc.clearFaults(m.ID(), false); err != nil {
+		plog.Errorf("Removing network faults of %v: %v", m.ID(), err)
+	}
+	delete(lc.links, m.ID())
+	lc.faultLock.Unlock()
+
+	lc.BaseCluster.DelMach(m)
+}
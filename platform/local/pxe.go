@@ -0,0 +1,167 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ipxeSearchPaths are the locations of iPXE's chainloader for PXE
+// clients in common distros.
+var ipxeSearchPaths = []string{
+	"/usr/share/ipxe/undionly.kpxe",
+	"/usr/lib/ipxe/undionly.kpxe",
+}
+
+// findIPXE returns the directory holding undionly.kpxe, or "" if it
+// isn't installed.
+func findIPXE() string {
+	for _, path := range ipxeSearchPaths {
+		if _, err := os.Stat(path); err == nil {
+			return filepath.Dir(path)
+		}
+	}
+	return ""
+}
+
+// PXEServer serves iPXE scripts, and the kernel, initramfs and rootfs
+// they boot, to machines on the flight's network.  dnsmasq directs
+// iPXE to /boot.ipxe, which chains to a script for the machine's MAC
+// address.
+type PXEServer struct {
+	// Host is the server's address as seen by machines.
+	Host string
+
+	// Kernel, Initrd and Rootfs are the files to serve.  Rootfs is
+	// optional.
+	Kernel string
+	Initrd string
+	Rootfs string
+
+	listener net.Listener
+	server   http.Server
+
+	lock    sync.Mutex
+	scripts map[string]string
+}
+
+// NewPXEServer listens on addr and starts serving.
+func NewPXEServer(addr string) (*PXEServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &PXEServer{
+		listener: listener,
+		scripts:  make(map[string]string),
+	}
+	ps.Host, _, _ = net.SplitHostPort(listener.Addr().String())
+	ps.server.Handler = ps
+	go ps.server.Serve(listener)
+
+	return ps, nil
+}
+
+// Port returns the port the server listens on.
+func (ps *PXEServer) Port() int {
+	return ps.listener.Addr().(*net.TCPAddr).Port
+}
+
+// URL returns the URL of path as seen by machines.
+func (ps *PXEServer) URL(path string) string {
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(ps.Host, fmt.Sprint(ps.Port())), path)
+}
+
+// Available reports whether there is a kernel and initramfs to boot.
+func (ps *PXEServer) Available() bool {
+	return ps.Kernel != "" && ps.Initrd != ""
+}
+
+// KernelArgs returns the arguments for the live system to find its
+// rootfs, if there is one.
+func (ps *PXEServer) KernelArgs() []string {
+	if ps.Rootfs == "" {
+		return nil
+	}
+	return []string{"coreos.live.rootfs_url=" + ps.URL("/rootfs")}
+}
+
+// AddMachine makes the machine with the given MAC address boot the
+// kernel with args.
+func (ps *PXEServer) AddMachine(mac net.HardwareAddr, args []string) {
+	script := fmt.Sprintf("#!ipxe\nkernel %s initrd=initrd %s\ninitrd --name initrd %s\nboot\n",
+		ps.URL("/kernel"), strings.Join(args, " "), ps.URL("/initrd"))
+	ps.lock.Lock()
+	ps.scripts[macPath(mac)] = script
+	ps.lock.Unlock()
+}
+
+// RemoveMachine stops serving a script to the machine.
+func (ps *PXEServer) RemoveMachine(mac net.HardwareAddr) {
+	ps.lock.Lock()
+	delete(ps.scripts, macPath(mac))
+	ps.lock.Unlock()
+}
+
+// macPath returns the script path for mac, in the form produced by
+// iPXE's ${net0/mac:hexhyp}.
+func macPath(mac net.HardwareAddr) string {
+	return "/machine/" + strings.Replace(mac.String(), ":", "-", -1)
+}
+
+func (ps *PXEServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	plog.Debugf("Serving %s to %s", r.URL.Path, r.RemoteAddr)
+	switch r.URL.Path {
+	case "/boot.ipxe":
+		fmt.Fprintf(w, "#!ipxe\nchain %s\n", ps.URL("/machine/${net0/mac:hexhyp}"))
+	case "/kernel":
+		ps.serveFile(w, r, ps.Kernel)
+	case "/initrd":
+		ps.serveFile(w, r, ps.Initrd)
+	case "/rootfs":
+		ps.serveFile(w, r, ps.Rootfs)
+	default:
+		ps.lock.Lock()
+		script, ok := ps.scripts[r.URL.Path]
+		ps.lock.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(script))
+	}
+}
+
+func (ps *PXEServer) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+// Destroy stops the server.
+func (ps *PXEServer) Destroy() {
+	if err := ps.server.Close(); err != nil {
+		plog.Errorf("Error closing PXE server: %v", err)
+	}
+}
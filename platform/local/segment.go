@@ -0,0 +1,113 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/coreos/mantle/system/ns"
+)
+
+// clusterSegment is a network segment created for a cluster.  It has
+// its own bridge and dnsmasq, so machines on it can only reach each
+// other and the host.
+type clusterSegment struct {
+	*Segment
+	opts    SegmentOptions
+	dnsmasq *Dnsmasq
+	lc      *LocalCluster
+}
+
+// GetSegment returns the cluster's network segment called name,
+// creating it with opts if it doesn't exist.  "" is the flight's
+// network, which every machine's primary interface is attached to.
+func (lc *LocalCluster) GetSegment(name string, opts SegmentOptions) (*Segment, error) {
+	if name == "" {
+		if opts != (SegmentOptions{}) {
+			return nil, fmt.Errorf("the flight's network segment can't be IPv6-only or tagged")
+		}
+		return lc.flight.Dnsmasq.Segments[0], nil
+	}
+
+	lc.segmentLock.Lock()
+	defer lc.segmentLock.Unlock()
+
+	if cs, ok := lc.segments[name]; ok {
+		if cs.opts != opts {
+			return nil, fmt.Errorf("network segment %q already exists with different options", name)
+		}
+		return cs.Segment, nil
+	}
+
+	s, err := lc.flight.allocSegment()
+	if err != nil {
+		return nil, err
+	}
+
+	nsExit, err := ns.Enter(lc.flight.nshandle)
+	if err != nil {
+		lc.flight.freeSegment(s)
+		return nil, err
+	}
+	defer nsExit()
+
+	seg, err := newSegment(s, numClusterInterfaces, opts)
+	if err != nil {
+		lc.flight.freeSegment(s)
+		return nil, fmt.Errorf("network segment %q setup failed: %v", name, err)
+	}
+	cs := &clusterSegment{
+		Segment: seg,
+		opts:    opts,
+		dnsmasq: &Dnsmasq{Segments: []*Segment{seg}},
+		lc:      lc,
+	}
+	if err := cs.dnsmasq.start(); err != nil {
+		cs.delLinks()
+		lc.flight.freeSegment(s)
+		return nil, err
+	}
+
+	if lc.segments == nil {
+		lc.segments = make(map[string]*clusterSegment)
+	}
+	lc.segments[name] = cs
+	lc.AddDestructor(cs)
+	return seg, nil
+}
+
+func (cs *clusterSegment) delLinks() {
+	// the VLAN, if any, goes with the bridge
+	br := netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: cs.BridgeName}}
+	if err := netlink.LinkDel(&br); err != nil {
+		plog.Errorf("Error deleting %s: %v", cs.BridgeName, err)
+	}
+}
+
+func (cs *clusterSegment) Destroy() {
+	cs.dnsmasq.Destroy()
+
+	nsExit, err := ns.Enter(cs.lc.flight.nshandle)
+	if err != nil {
+		// the bridge is still there, so its number can't be reused
+		plog.Errorf("Error deleting %s: %v", cs.BridgeName, err)
+		return
+	}
+	defer nsExit()
+	cs.delLinks()
+	cs.lc.flight.freeSegment(cs.num)
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"testing"
+)
+
+func TestAllocSegment(t *testing.T) {
+	lf := &LocalFlight{}
+	for i := numSegments; i <= 255; i++ {
+		s, err := lf.allocSegment()
+		if err != nil {
+			t.Fatalf("segment %d: %v", i, err)
+		}
+		if int(s) != i {
+			t.Fatalf("got segment %d, expected %d", s, i)
+		}
+	}
+	if _, err := lf.allocSegment(); err == nil {
+		t.Fatal("allocated more than 255 segments")
+	}
+
+	// numbers of destroyed segments are reused
+	lf.freeSegment(7)
+	lf.freeSegment(42)
+	for _, expected := range []byte{7, 42} {
+		s, err := lf.allocSegment()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected {
+			t.Errorf("got segment %d, expected %d", s, expected)
+		}
+	}
+}
+
+func TestGetInterface(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opts   SegmentOptions
+		static bool
+		ipv4   string
+		ipv6   string
+		mac    string
+		before int // DHCP interfaces handed out first
+	}{
+		{
+			name: "dhcp",
+			ipv4: "10.3.0.2",
+			ipv6: "fd03::2",
+			mac:  "02:03:00:00:00:02",
+		},
+		{
+			name:   "second dhcp",
+			before: 1,
+			ipv4:   "10.3.0.3",
+			ipv6:   "fd03::3",
+			mac:    "02:03:00:00:00:03",
+		},
+		{
+			name:   "static",
+			static: true,
+			ipv4:   "10.3.0.4",
+			ipv6:   "fd03::4",
+			mac:    "02:03:00:00:00:04",
+		},
+		{
+			name: "ipv6-only",
+			opts: SegmentOptions{IPv6Only: true},
+			ipv6: "fd03::2",
+			mac:  "02:03:00:00:00:02",
+		},
+		{
+			name:   "ipv6-only static",
+			opts:   SegmentOptions{IPv6Only: true},
+			static: true,
+			ipv6:   "fd03::4",
+			mac:    "02:03:00:00:00:04",
+		},
+		{
+			// VLANs only change the host's interface
+			name: "vlan",
+			opts: SegmentOptions{VLAN: 42},
+			ipv4: "10.3.0.2",
+			ipv6: "fd03::2",
+			mac:  "02:03:00:00:00:02",
+		},
+	} {
+		seg := planSegment(3, 2, tt.opts)
+		if tt.opts.IPv6Only && len(seg.BridgeIf.DHCPv4) != 0 {
+			t.Errorf("%s: IPv6-only bridge has IPv4 addresses", tt.name)
+		}
+		for i := 0; i < tt.before; i++ {
+			if _, err := seg.GetInterface(false); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		in, err := seg.GetInterface(tt.static)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if in.HardwareAddr.String() != tt.mac {
+			t.Errorf("%s: got MAC %s, expected %s", tt.name, in.HardwareAddr, tt.mac)
+		}
+		var ipv4, ipv6 string
+		if len(in.DHCPv4) > 0 {
+			ipv4 = in.DHCPv4[0].IP.String()
+		}
+		if len(in.DHCPv6) > 0 {
+			ipv6 = in.DHCPv6[0].IP.String()
+		}
+		if ipv4 != tt.ipv4 || ipv6 != tt.ipv6 {
+			t.Errorf("%s: got addresses %q and %q, expected %q and %q", tt.name, ipv4, ipv6, tt.ipv4, tt.ipv6)
+		}
+	}
+
+	seg := planSegment(3, 1, SegmentOptions{})
+	seg.GetInterface(false)
+	if _, err := seg.GetInterface(false); err == nil {
+		t.Error("handed out more DHCP interfaces than the segment has")
+	}
+	if _, err := seg.GetInterface(true); err != nil {
+		t.Errorf("static interface after DHCP ran out: %v", err)
+	}
+}
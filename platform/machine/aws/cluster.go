@@ -38,12 +38,13 @@ func (ac *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 
 	var keyname string
 	if !ac.RuntimeConf().NoSSHKeyInMetadata {
-		keyname = ac.flight.Name()
+		keyname = ac.flight.keyName
 	}
 	instances, err := ac.flight.api.CreateInstances(ac.Name(), keyname, conf.String(), 1)
 	if err != nil {
 		return nil, err
 	}
+	ac.flight.TrackResource(platform.GCInstance, *instances[0].InstanceId, map[string]string{"region": ac.flight.region})
 
 	mach := &machine{
 		cluster: ac,
@@ -33,8 +33,11 @@ var (
 
 type flight struct {
 	*platform.BaseFlight
-	api      *aws.API
-	keyAdded bool
+	api     *aws.API
+	region  string
+	keyName string // set once the flight's key is added
+	// set if the flight created the security group
+	securityGroup string
 }
 
 // NewFlight creates an instance of a Flight suitable for spawning
@@ -43,13 +46,13 @@ type flight struct {
 // NewFlight will consume the environment variables $AWS_REGION,
 // $AWS_ACCESS_KEY_ID, and $AWS_SECRET_ACCESS_KEY to determine the region to
 // spawn instances in and the credentials to use to authenticate.
-func NewFlight(opts *aws.Options) (platform.Flight, error) {
+func NewFlight(opts *aws.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
 	api, err := aws.New(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, ctplatform.EC2)
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, ctplatform.EC2)
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +60,7 @@ func NewFlight(opts *aws.Options) (platform.Flight, error) {
 	af := &flight{
 		BaseFlight: bf,
 		api:        api,
+		region:     opts.Region,
 	}
 
 	keys, err := af.Keys()
@@ -64,11 +68,25 @@ func NewFlight(opts *aws.Options) (platform.Flight, error) {
 		af.Destroy()
 		return nil, err
 	}
-	if err := api.AddKey(af.Name(), keys[0].String()); err != nil {
+	keyName := platform.GCTimedName(af.Name())
+	if err := api.AddKey(keyName, keys[0].String()); err != nil {
 		af.Destroy()
 		return nil, err
 	}
-	af.keyAdded = true
+	af.keyName = keyName
+	af.TrackResource(platform.GCKey, keyName, map[string]string{"region": af.region})
+
+	// create the security group now, rather than with the first
+	// instance, so it's journaled as the flight's own
+	sgID, created, err := api.EnsureSecurityGroup()
+	if err != nil {
+		af.Destroy()
+		return nil, err
+	}
+	if created {
+		af.securityGroup = sgID
+		af.TrackResource(platform.GCSecurityGroup, sgID, map[string]string{"region": af.region})
+	}
 
 	return af, nil
 }
@@ -92,9 +110,17 @@ func (af *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, e
 }
 
 func (af *flight) Destroy() {
-	if af.keyAdded {
-		if err := af.api.DeleteKey(af.Name()); err != nil {
-			plog.Errorf("Error deleting key %v: %v", af.Name(), err)
+	// Later flights find the security group by name and reuse it, so
+	// a flight which finishes hands it over rather than deleting it.
+	// It stays journaled, for kola cleanup, only if kola dies first.
+	if af.securityGroup != "" {
+		af.ReleaseResource(platform.GCSecurityGroup, af.securityGroup)
+	}
+	if af.keyName != "" {
+		if err := af.api.DeleteKey(af.keyName); err != nil {
+			plog.Errorf("Error deleting key %v: %v", af.keyName, err)
+		} else {
+			af.ReleaseResource(platform.GCKey, af.keyName)
 		}
 	}
 
@@ -76,6 +76,8 @@ func (am *machine) Destroy() {
 
 	if err := am.cluster.flight.api.TerminateInstances([]string{am.ID()}); err != nil {
 		plog.Errorf("Error terminating instance %v: %v", am.ID(), err)
+	} else {
+		am.cluster.flight.ReleaseResource(platform.GCInstance, am.ID())
 	}
 
 	if am.journal != nil {
@@ -87,6 +87,8 @@ func (ac *cluster) Destroy() {
 	ac.BaseCluster.Destroy()
 	if e := ac.flight.api.TerminateResourceGroup(ac.ResourceGroup); e != nil {
 		plog.Errorf("Deleting resource group %v: %v", ac.ResourceGroup, e)
+	} else {
+		ac.flight.ReleaseResource(platform.GCResourceGroup, ac.ResourceGroup)
 	}
 	ac.flight.DelCluster(ac)
 }
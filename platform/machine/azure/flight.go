@@ -41,7 +41,7 @@ type flight struct {
 
 // NewFlight creates an instance of a Flight suitable for spawning
 // instances on the Azure platform.
-func NewFlight(opts *azure.Options) (platform.Flight, error) {
+func NewFlight(opts *azure.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
 	api, err := azure.New(opts)
 	if err != nil {
 		return nil, err
@@ -51,7 +51,7 @@ func NewFlight(opts *azure.Options) (platform.Flight, error) {
 		return nil, fmt.Errorf("setting up clients: %v", err)
 	}
 
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, ctplatform.Azure)
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, ctplatform.Azure)
 	if err != nil {
 		return nil, err
 	}
@@ -98,6 +98,7 @@ func (af *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, e
 	if err != nil {
 		return nil, err
 	}
+	af.TrackResource(platform.GCResourceGroup, ac.ResourceGroup, nil)
 
 	ac.StorageAccount, err = af.api.CreateStorageAccount(ac.ResourceGroup)
 	if err != nil {
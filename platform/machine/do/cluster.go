@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
@@ -44,6 +45,7 @@ func (dc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 	if err != nil {
 		return nil, err
 	}
+	dc.flight.TrackResource(platform.GCInstance, strconv.Itoa(droplet.ID), nil)
 
 	mach := &machine{
 		cluster: dc,
@@ -17,6 +17,7 @@ package do
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/coreos/pkg/capnslog"
 
@@ -40,13 +41,13 @@ type flight struct {
 	fakeSSHKeyID int
 }
 
-func NewFlight(opts *do.Options) (platform.Flight, error) {
+func NewFlight(opts *do.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
 	api, err := do.New(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, ctplatform.DO)
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, ctplatform.DO)
 	if err != nil {
 		return nil, err
 	}
@@ -61,11 +62,12 @@ func NewFlight(opts *do.Options) (platform.Flight, error) {
 		df.Destroy()
 		return nil, err
 	}
-	df.sshKeyID, err = df.api.AddKey(context.TODO(), df.Name(), keys[0].String())
+	df.sshKeyID, err = df.api.AddKey(context.TODO(), platform.GCTimedName(df.Name()), keys[0].String())
 	if err != nil {
 		df.Destroy()
 		return nil, err
 	}
+	df.TrackResource(platform.GCKey, strconv.Itoa(df.sshKeyID), nil)
 
 	// The DO API requires us to provide an SSH key for Container Linux
 	// droplets.  Create one that can never authenticate.
@@ -74,11 +76,12 @@ func NewFlight(opts *do.Options) (platform.Flight, error) {
 		df.Destroy()
 		return nil, err
 	}
-	df.fakeSSHKeyID, err = df.api.AddKey(context.TODO(), df.Name()+"-fake", key)
+	df.fakeSSHKeyID, err = df.api.AddKey(context.TODO(), platform.GCTimedName(df.Name()+"-fake"), key)
 	if err != nil {
 		df.Destroy()
 		return nil, err
 	}
+	df.TrackResource(platform.GCKey, strconv.Itoa(df.fakeSSHKeyID), nil)
 
 	return df, nil
 }
@@ -114,6 +117,8 @@ func (df *flight) Destroy() {
 		}
 		if err := df.api.DeleteKey(context.TODO(), keyID); err != nil {
 			plog.Errorf("Error deleting key %v: %v", keyID, err)
+		} else {
+			df.ReleaseResource(platform.GCKey, strconv.Itoa(keyID))
 		}
 	}
 
@@ -67,6 +67,8 @@ func (dm *machine) Reboot() error {
 func (dm *machine) Destroy() {
 	if err := dm.cluster.flight.api.DeleteDroplet(context.TODO(), dm.droplet.ID); err != nil {
 		plog.Errorf("Error deleting droplet %v: %v", dm.droplet.ID, err)
+	} else {
+		dm.cluster.flight.ReleaseResource(platform.GCInstance, dm.ID())
 	}
 
 	if dm.journal != nil {
@@ -57,6 +57,7 @@ ExecStart=/usr/bin/bash -c 'echo "COREOS_ESX_IPV4_PRIVATE_0=$(ip addr show ens19
 	if err != nil {
 		return nil, err
 	}
+	ec.flight.TrackResource(platform.GCInstance, instance.Name, nil)
 
 	mach := &machine{
 		cluster: ec,
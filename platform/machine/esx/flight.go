@@ -37,13 +37,13 @@ type flight struct {
 
 // NewFlight creates an instance of a Flight suitable for spawning
 // clusters on VMware ESXi vSphere platform.
-func NewFlight(opts *esx.Options) (platform.Flight, error) {
+func NewFlight(opts *esx.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
 	api, err := esx.New(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, "")
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, "")
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +68,8 @@ func (em *machine) Reboot() error {
 func (em *machine) Destroy() {
 	if err := em.cluster.flight.api.TerminateDevice(em.ID()); err != nil {
 		plog.Errorf("Error terminating device %v: %v", em.ID(), err)
+	} else {
+		em.cluster.flight.ReleaseResource(platform.GCInstance, em.ID())
 	}
 
 	if em.journal != nil {
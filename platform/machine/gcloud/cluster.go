@@ -53,6 +53,10 @@ func (gc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 	if err != nil {
 		return nil, err
 	}
+	gc.flight.TrackResource(platform.GCInstance, instance.Name, map[string]string{
+		"project": gc.flight.project,
+		"zone":    gc.flight.zone,
+	})
 
 	intip, extip := gcloud.InstanceIPs(instance)
 
@@ -26,7 +26,9 @@ import (
 
 type flight struct {
 	*platform.BaseFlight
-	api *gcloud.API
+	api     *gcloud.API
+	project string
+	zone    string
 }
 
 const (
@@ -37,13 +39,13 @@ var (
 	plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "platform/machine/gcloud")
 )
 
-func NewFlight(opts *gcloud.Options) (platform.Flight, error) {
+func NewFlight(opts *gcloud.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
 	api, err := gcloud.New(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, ctplatform.GCE)
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, ctplatform.GCE)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +53,8 @@ func NewFlight(opts *gcloud.Options) (platform.Flight, error) {
 	gf := &flight{
 		BaseFlight: bf,
 		api:        api,
+		project:    opts.Project,
+		zone:       opts.Zone,
 	}
 
 	return gf, nil
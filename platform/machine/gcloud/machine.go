@@ -72,6 +72,8 @@ func (gm *machine) Destroy() {
 
 	if err := gm.gc.flight.api.TerminateInstance(gm.name); err != nil {
 		plog.Errorf("Error terminating instance %v: %v", gm.ID(), err)
+	} else {
+		gm.gc.flight.ReleaseResource(platform.GCInstance, gm.ID())
 	}
 
 	if gm.journal != nil {
@@ -0,0 +1,143 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/api/libvirt"
+	"github.com/coreos/mantle/platform/conf"
+	"github.com/coreos/mantle/util"
+)
+
+type cluster struct {
+	*platform.BaseCluster
+	flight *flight
+}
+
+func (lc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	conf, err := lc.RenderUserData(userdata, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	if !conf.IsIgnition() && !conf.IsEmpty() {
+		return nil, fmt.Errorf("libvirt only supports Ignition or empty configs")
+	}
+
+	// libvirt domain names must be unique per connection
+	id := fmt.Sprintf("%s-%s", lc.Name(), uuid.New()[:8])
+	dir := filepath.Join(lc.RuntimeConf().OutputDir, id)
+	if err := os.Mkdir(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	// until the domain exists, clean up the machine dir and journal
+	// on failure; afterwards, lm.Destroy does.
+	var journal *platform.Journal
+	created := false
+	defer func() {
+		if created {
+			return
+		}
+		if journal != nil {
+			journal.Destroy()
+		}
+		os.RemoveAll(dir)
+	}()
+
+	spec := &libvirt.DomainSpec{
+		Name:        id,
+		DiskPath:    filepath.Join(dir, "disk.qcow2"),
+		ConsolePath: filepath.Join(dir, "console.txt"),
+		Network:     lc.flight.network,
+	}
+	if spec.Network != "" {
+		spec.MAC = libvirt.RandomMAC()
+	} else if spec.SSHPort, err = libvirt.FreePort(); err != nil {
+		return nil, fmt.Errorf("allocating SSH port: %v", err)
+	}
+	// without Ignition there's no way to configure the segment's
+	// static address, so the machine only gets user networking
+	if segment := lc.flight.segment; segment != nil && conf.IsIgnition() {
+		if spec.SegmentIP, err = segment.Address(); err != nil {
+			return nil, err
+		}
+		spec.Segment = segment
+		spec.SegmentMAC = libvirt.RandomMAC()
+		for path, contents := range libvirt.SegmentConfig(spec.SegmentMAC, spec.SegmentIP) {
+			conf.AddFile(path, "root", contents, 0600)
+		}
+	}
+	if conf.IsIgnition() {
+		spec.IgnitionPath = filepath.Join(dir, "ignition.json")
+		if err := conf.WriteFile(spec.IgnitionPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := lc.flight.api.CloneDisk(spec.DiskPath); err != nil {
+		return nil, err
+	}
+
+	journal, err = platform.NewJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	lm := &machine{
+		cluster: lc,
+		spec:    spec,
+		journal: journal,
+	}
+
+	if err := lc.flight.api.CreateDomain(spec); err != nil {
+		return nil, err
+	}
+	created = true
+	lc.flight.TrackResource(platform.GCInstance, id, nil)
+
+	if spec.Network != "" {
+		err = util.Retry(24, 5*time.Second, func() error {
+			lm.ip, err = lc.flight.api.LeaseIP(spec.Network, spec.MAC)
+			return err
+		})
+		if err != nil {
+			lm.Destroy()
+			return nil, err
+		}
+	} else {
+		lm.ip = fmt.Sprintf("127.0.0.1:%d", spec.SSHPort)
+	}
+
+	if err := platform.StartMachine(lm, lm.journal); err != nil {
+		lm.Destroy()
+		return nil, err
+	}
+
+	lc.AddMach(lm)
+
+	return lm, nil
+}
+
+func (lc *cluster) Destroy() {
+	lc.BaseCluster.Destroy()
+	lc.flight.DelCluster(lc)
+}
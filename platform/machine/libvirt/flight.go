@@ -0,0 +1,101 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"github.com/coreos/pkg/capnslog"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/api/libvirt"
+)
+
+const (
+	Platform platform.Name = "libvirt"
+)
+
+var (
+	plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "platform/machine/libvirt")
+)
+
+type flight struct {
+	*platform.BaseFlight
+	api *libvirt.API
+
+	// network is the flight's NAT network, if any
+	network string
+	// segment is the flight's private network when machines use
+	// user networking
+	segment *libvirt.Segment
+}
+
+func NewFlight(opts *libvirt.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
+	api, err := libvirt.New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, "")
+	if err != nil {
+		return nil, err
+	}
+
+	lf := &flight{
+		BaseFlight: bf,
+		api:        api,
+	}
+
+	switch api.NetworkMode() {
+	case libvirt.NetworkNAT:
+		network := platform.GCTimedName(lf.Name())
+		if err := api.CreateNetwork(network); err != nil {
+			lf.Destroy()
+			return nil, err
+		}
+		lf.network = network
+		lf.TrackResource(platform.GCNetwork, lf.network, nil)
+	case libvirt.NetworkUser:
+		lf.segment = libvirt.NewSegment()
+	}
+
+	return lf, nil
+}
+
+func (lf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
+	bc, err := platform.NewBaseCluster(lf.BaseFlight, rconf)
+	if err != nil {
+		return nil, err
+	}
+
+	lc := &cluster{
+		BaseCluster: bc,
+		flight:      lf,
+	}
+
+	lf.AddCluster(lc)
+
+	return lc, nil
+}
+
+func (lf *flight) Destroy() {
+	lf.BaseFlight.Destroy()
+
+	if lf.network != "" {
+		if err := lf.api.DeleteNetwork(lf.network); err != nil {
+			plog.Errorf("Error deleting network %v: %v", lf.network, err)
+		} else {
+			lf.ReleaseResource(platform.GCNetwork, lf.network)
+		}
+	}
+}
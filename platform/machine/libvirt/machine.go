@@ -0,0 +1,109 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libvirt
+
+import (
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/api/libvirt"
+)
+
+type machine struct {
+	cluster *cluster
+	spec    *libvirt.DomainSpec
+	ip      string
+	journal *platform.Journal
+	console string
+}
+
+func (lm *machine) ID() string {
+	return lm.spec.Name
+}
+
+func (lm *machine) IP() string {
+	return lm.ip
+}
+
+func (lm *machine) PrivateIP() string {
+	if lm.spec.SegmentIP != "" {
+		return lm.spec.SegmentIP
+	}
+	return lm.ip
+}
+
+func (lm *machine) RuntimeConf() platform.RuntimeConfig {
+	return lm.cluster.RuntimeConf()
+}
+
+func (lm *machine) SSHClient() (*ssh.Client, error) {
+	return lm.cluster.SSHClient(lm.IP())
+}
+
+func (lm *machine) PasswordSSHClient(user string, password string) (*ssh.Client, error) {
+	return lm.cluster.PasswordSSHClient(lm.IP(), user, password)
+}
+
+func (lm *machine) SSH(cmd string) ([]byte, []byte, error) {
+	return lm.cluster.SSH(lm, cmd)
+}
+
+func (lm *machine) Reboot() error {
+	return platform.RebootMachine(lm, lm.journal)
+}
+
+func (lm *machine) Destroy() {
+	if err := lm.cluster.flight.api.DestroyDomain(lm.ID()); err != nil {
+		plog.Errorf("Error destroying domain %v: %v", lm.ID(), err)
+	} else {
+		lm.cluster.flight.ReleaseResource(platform.GCInstance, lm.ID())
+	}
+
+	if lm.journal != nil {
+		lm.journal.Destroy()
+	}
+
+	if buf, err := ioutil.ReadFile(lm.spec.ConsolePath); err == nil {
+		lm.console = string(buf)
+	} else {
+		plog.Errorf("Error reading console for domain %v: %v", lm.ID(), err)
+	}
+
+	// the overlay is only useful while the domain exists
+	if err := os.Remove(lm.spec.DiskPath); err != nil {
+		plog.Errorf("Error removing disk for domain %v: %v", lm.ID(), err)
+	}
+
+	lm.cluster.DelMach(lm)
+}
+
+func (lm *machine) ConsoleOutput() string {
+	return lm.console
+}
+
+func (lm *machine) JournalOutput() string {
+	if lm.journal == nil {
+		return ""
+	}
+
+	data, err := lm.journal.Read()
+	if err != nil {
+		plog.Errorf("Reading journal for domain %v: %v", lm.ID(), err)
+	}
+	return string(data)
+}
@@ -40,12 +40,13 @@ func (oc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 
 	var keyname string
 	if !oc.RuntimeConf().NoSSHKeyInMetadata {
-		keyname = oc.flight.Name()
+		keyname = oc.flight.keyName
 	}
 	instance, err := oc.flight.api.CreateServer(oc.vmname(), keyname, conf.String())
 	if err != nil {
 		return nil, err
 	}
+	oc.flight.TrackResource(platform.GCInstance, instance.Server.ID, nil)
 
 	mach := &machine{
 		cluster: oc,
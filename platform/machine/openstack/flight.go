@@ -32,19 +32,19 @@ var (
 
 type flight struct {
 	*platform.BaseFlight
-	api      *openstack.API
-	keyAdded bool
+	api     *openstack.API
+	keyName string // set once the flight's key is added
 }
 
 // NewFlight creates an instance of a Flight suitable for spawning
 // instances on the OpenStack platform.
-func NewFlight(opts *openstack.Options) (platform.Flight, error) {
+func NewFlight(opts *openstack.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
 	api, err := openstack.New(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, ctplatform.OpenStackMetadata)
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, ctplatform.OpenStackMetadata)
 	if err != nil {
 		return nil, err
 	}
@@ -60,11 +60,13 @@ func NewFlight(opts *openstack.Options) (platform.Flight, error) {
 		return nil, err
 	}
 
-	if err := api.AddKey(of.Name(), keys[0].String()); err != nil {
+	keyName := platform.GCTimedName(of.Name())
+	if err := api.AddKey(keyName, keys[0].String()); err != nil {
 		of.Destroy()
 		return nil, err
 	}
-	of.keyAdded = true
+	of.keyName = keyName
+	of.TrackResource(platform.GCKey, keyName, nil)
 
 	return of, nil
 }
@@ -88,9 +90,11 @@ func (of *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, e
 }
 
 func (of *flight) Destroy() {
-	if of.keyAdded {
-		if err := of.api.DeleteKey(of.Name()); err != nil {
-			plog.Errorf("Error deleting key %v: %v", of.Name(), err)
+	if of.keyName != "" {
+		if err := of.api.DeleteKey(of.keyName); err != nil {
+			plog.Errorf("Error deleting key %v: %v", of.keyName, err)
+		} else {
+			of.ReleaseResource(platform.GCKey, of.keyName)
 		}
 	}
 
@@ -93,6 +93,8 @@ func (om *machine) Destroy() {
 
 	if err := om.cluster.flight.api.DeleteServer(om.ID()); err != nil {
 		plog.Errorf("deleting server %v: %v", om.ID(), err)
+	} else {
+		om.cluster.flight.ReleaseResource(platform.GCInstance, om.ID())
 	}
 
 	if om.journal != nil {
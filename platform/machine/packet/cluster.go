@@ -64,6 +64,7 @@ func (pc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 	if err != nil {
 		return nil, err
 	}
+	pc.flight.TrackResource(platform.GCInstance, device.ID, nil)
 
 	mach := &machine{
 		cluster: pc,
@@ -37,13 +37,13 @@ type flight struct {
 	sshKeyID string
 }
 
-func NewFlight(opts *packet.Options) (platform.Flight, error) {
+func NewFlight(opts *packet.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
 	api, err := packet.New(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, ctplatform.Packet)
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, ctplatform.Packet)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +63,7 @@ func NewFlight(opts *packet.Options) (platform.Flight, error) {
 		pf.Destroy()
 		return nil, err
 	}
+	pf.TrackResource(platform.GCKey, pf.sshKeyID, nil)
 
 	return pf, nil
 }
@@ -90,6 +91,8 @@ func (pf *flight) Destroy() {
 	if pf.sshKeyID != "" {
 		if err := pf.api.DeleteKey(pf.sshKeyID); err != nil {
 			plog.Errorf("Error deleting key %v: %v", pf.sshKeyID, err)
+		} else {
+			pf.ReleaseResource(platform.GCKey, pf.sshKeyID)
 		}
 	}
 
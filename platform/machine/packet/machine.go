@@ -67,6 +67,8 @@ func (pm *machine) Reboot() error {
 func (pm *machine) Destroy() {
 	if err := pm.cluster.flight.api.DeleteDevice(pm.ID()); err != nil {
 		plog.Errorf("Error terminating device %v: %v", pm.ID(), err)
+	} else {
+		pm.cluster.flight.ReleaseResource(platform.GCInstance, pm.ID())
 	}
 
 	if pm.journal != nil {
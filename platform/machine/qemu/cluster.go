@@ -16,6 +16,7 @@ package qemu
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 	"github.com/coreos/mantle/platform/local"
+	"github.com/coreos/mantle/platform/qmp"
 	"github.com/coreos/mantle/system/ns"
 )
 
@@ -45,6 +47,18 @@ func (qc *Cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 }
 
 func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	image := qc.flight.diskImagePath
+	if qc.flight.warm != nil && options.Boot == platform.BootDisk && qc.flight.opts.Boot == platform.BootDisk && !qc.RuntimeConf().NoSSHKeyInUserData {
+		image = qc.flight.warm.Image(qc.flight.opts.DiskImage, qc.flight.specialization(), image, qc.flight.opts.Distribution, func(image string) (platform.QEMUMachine, error) {
+			return qc.newMachine(nil, platform.MachineOptions{NoShutdown: true}, image)
+		})
+	}
+	return qc.newMachine(userdata, options, image)
+}
+
+// newMachine boots a machine from an overlay of the disk image at
+// diskImagePath.
+func (qc *Cluster) newMachine(userdata *conf.UserData, options platform.MachineOptions, diskImagePath string) (*machine, error) {
 	id := uuid.New()
 
 	dir := filepath.Join(qc.RuntimeConf().OutputDir, id)
@@ -57,44 +71,89 @@ func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platfo
 	qc.mu.Lock()
 	netif := qc.flight.Dnsmasq.GetInterface("br0")
 	ip := strings.Split(netif.DHCPv4[0].String(), "/")[0]
-
-	conf, err := qc.RenderUserData(userdata, map[string]string{
+	vars := map[string]string{
 		"$public_ipv4":  ip,
 		"$private_ipv4": ip,
-	})
+	}
+
+	nics, err := qc.getNICs(options.NICs, vars)
+	if err != nil {
+		qc.mu.Unlock()
+		return nil, err
+	}
+
+	conf, err := qc.RenderUserData(userdata, vars)
 	if err != nil {
 		qc.mu.Unlock()
 		return nil, err
 	}
 	qc.mu.Unlock()
 
+	qm := &machine{
+		qc:          qc,
+		id:          id,
+		netif:       netif,
+		consolePath: filepath.Join(dir, "console.txt"),
+	}
+
+	// qm.Destroy releases whatever has been set up so far, so it
+	// cleans up if any step fails before the machine is added.
+	var qmpClient *os.File
+	added := false
+	defer func() {
+		if added {
+			return
+		}
+		if qmpClient != nil {
+			qmpClient.Close()
+		}
+		qm.Destroy()
+	}()
+
+	if options.Boot == platform.BootDisk {
+		options.Boot = qc.flight.opts.Boot
+	}
 	var confPath string
-	if conf.IsIgnition() {
-		confPath = filepath.Join(dir, "ignition.json")
-		if err := conf.WriteFile(confPath); err != nil {
+	if options.Boot == platform.BootDisk {
+		if options.ConfigDelivery == platform.ConfigDeliveryDefault {
+			options.ConfigDelivery = qc.RuntimeConf().ConfigDelivery
+		}
+		options.ConfigDelivery, confPath, err = local.WriteQEMUConfig(conf, dir, id, options.ConfigDelivery, qc.ConfigServer)
+		if err != nil {
 			return nil, err
 		}
 	} else {
-		confPath, err = local.MakeConfigDrive(conf, dir)
-		if err != nil {
+		// network-booted machines get their config from the kernel
+		// command line instead
+		if err := qc.setupPXE(conf, dir, id, netif.HardwareAddr, options.KernelArgs); err != nil {
 			return nil, err
 		}
+		options.ConfigDelivery = platform.ConfigDeliveryDefault
 	}
 
-	journal, err := platform.NewJournal(dir)
+	qm.journal, err = platform.NewJournal(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	qm := &machine{
-		qc:          qc,
-		id:          id,
-		netif:       netif,
-		journal:     journal,
-		consolePath: filepath.Join(dir, "console.txt"),
+	opts := qc.flight.opts
+	if options.Firmware == "" {
+		options.Firmware = opts.Firmware
+	}
+	options.Emulate = options.Emulate || opts.Emulate
+	qm.emulated = platform.QEMUEmulated(opts.Board, options.Emulate)
+	firmware, err := platform.SetupQEMUFirmware(&options, opts.Board, opts.BIOSImage, opts.OVMFCode, opts.OVMFVars, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	qm.virtiofsd = platform.SetupVirtiofsd(&options, qm.id)
+	qm.serial, err = platform.SetupQEMUConsole(&options)
+	if err != nil {
+		return nil, err
 	}
 
-	qmCmd, extraFiles, err := platform.CreateQEMUCommand(qc.flight.opts.Board, qm.id, qc.flight.opts.BIOSImage, qm.consolePath, confPath, qc.flight.diskImagePath, conf.IsIgnition(), options)
+	qmCmd, extraFiles, err := platform.CreateQEMUCommand(opts.Board, qm.id, firmware, qm.consolePath, confPath, diskImagePath, options)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +161,28 @@ func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platfo
 	for _, file := range extraFiles {
 		defer file.Close()
 	}
+
+	qmpFile, qmpClient, err := qmp.NewSocketPair()
+	if err != nil {
+		return nil, err
+	}
+	defer qmpFile.Close()
+	qmCmd = append(qmCmd, qmp.Args(3+len(extraFiles))...)
+	extraFiles = append(extraFiles, qmpFile)
+
+	if options.SWTPM || opts.SWTPM {
+		if qm.swtpm, err = platform.StartSWTPM(dir); err != nil {
+			return nil, err
+		}
+		defer qm.swtpm.File.Close()
+		tpmArgs, err := qm.swtpm.QEMUArgs(opts.Board, 3+len(extraFiles))
+		if err != nil {
+			return nil, err
+		}
+		qmCmd = append(qmCmd, tpmArgs...)
+		extraFiles = append(extraFiles, qm.swtpm.File)
+	}
+
 	qmMac := qm.netif.HardwareAddr.String()
 
 	qc.mu.Lock()
@@ -112,37 +193,138 @@ func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platfo
 		return nil, err
 	}
 	defer tap.Close()
+	qc.AddMachineLink(qm.id, tap, qm.netif.HardwareAddr)
 	fdnum := 3 + len(extraFiles)
 	qmCmd = append(qmCmd, "-netdev", fmt.Sprintf("tap,id=tap,fd=%d", fdnum),
 		"-device", platform.Virtio(qc.flight.opts.Board, "net", "netdev=tap,mac="+qmMac))
 	fdnum += 1
 	extraFiles = append(extraFiles, tap.File)
 
+	for i, nic := range nics {
+		tap, err := qc.NewTap(nic.bridge)
+		if err != nil {
+			qc.mu.Unlock()
+			return nil, err
+		}
+		defer tap.Close()
+		id := fmt.Sprintf("nic%d", i+1)
+		qmCmd = append(qmCmd, "-netdev", fmt.Sprintf("tap,id=%s,fd=%d", id, fdnum),
+			"-device", platform.Virtio(qc.flight.opts.Board, "net", fmt.Sprintf("netdev=%s,mac=%s", id, nic.netif.HardwareAddr)))
+		fdnum += 1
+		extraFiles = append(extraFiles, tap.File)
+	}
+
 	plog.Debugf("NewMachine: %q", qmCmd)
 
-	qm.qemu = qm.qc.NewCommand(qmCmd[0], qmCmd[1:]...)
+	qemu := qm.qc.NewCommand(qmCmd[0], qmCmd[1:]...)
 
 	qc.mu.Unlock()
 
-	cmd := qm.qemu.(*ns.Cmd)
+	cmd := qemu.(*ns.Cmd)
 	cmd.Stderr = os.Stderr
 
 	cmd.ExtraFiles = append(cmd.ExtraFiles, extraFiles...)
 
-	if err = qm.qemu.Start(); err != nil {
+	if err = qm.virtiofsd.Start(); err != nil {
+		return nil, err
+	}
+
+	if err = qemu.Start(); err != nil {
+		return nil, err
+	}
+	qm.qemu = qemu
+	qm.serial.Started()
+
+	qm.QEMUControl, err = platform.NewQEMUControl(qmpClient, qc.flight.opts.Board, dir)
+	if err != nil {
 		return nil, err
 	}
 
 	if err := platform.StartMachine(qm, qm.journal); err != nil {
-		qm.Destroy()
+		return nil, err
+	}
+
+	if err := platform.MountSharedDirs(qm, options.SharedDirs); err != nil {
 		return nil, err
 	}
 
 	qc.AddMach(qm)
+	added = true
 
 	return qm, nil
 }
 
+// machineNIC is an additional interface of a machine.
+type machineNIC struct {
+	bridge string
+	netif  *local.Interface
+}
+
+// getNICs reserves interfaces for the NICs and adds their addresses to
+// vars.
+func (qc *Cluster) getNICs(nics []platform.NIC, vars map[string]string) ([]machineNIC, error) {
+	var result []machineNIC
+	for i, nic := range nics {
+		seg, err := qc.GetSegment(nic.Segment, local.SegmentOptions{
+			IPv6Only: nic.IPv6Only,
+			VLAN:     nic.VLAN,
+		})
+		if err != nil {
+			return nil, err
+		}
+		netif, err := seg.GetInterface(nic.Static)
+		if err != nil {
+			return nil, err
+		}
+		prefix := fmt.Sprintf("$nic%d_", i+1)
+		vars[prefix+"mac"] = netif.HardwareAddr.String()
+		if len(netif.DHCPv4) > 0 {
+			vars[prefix+"ipv4"] = netif.DHCPv4[0].IP.String()
+		}
+		if len(netif.DHCPv6) > 0 {
+			vars[prefix+"ipv6"] = netif.DHCPv6[0].IP.String()
+		}
+		result = append(result, machineNIC{seg.BridgeName, netif})
+	}
+	return result, nil
+}
+
+// PXEAvailable reports whether machines can boot from the network.
+func (qc *Cluster) PXEAvailable() bool {
+	return qc.flight.PXEServer != nil && qc.flight.PXEServer.Available()
+}
+
+// setupPXE serves the kernel command line for a network-booted machine,
+// which points it at conf on the config server.
+func (qc *Cluster) setupPXE(conf *conf.Conf, dir, id string, mac net.HardwareAddr, extraArgs []string) error {
+	server := qc.flight.PXEServer
+	if !qc.PXEAvailable() {
+		return fmt.Errorf("booting from the network needs a PXE kernel and initramfs")
+	}
+
+	args := append([]string{"console=ttyS0,115200n8"}, server.KernelArgs()...)
+	if !conf.IsEmpty() {
+		if err := conf.WriteFile(filepath.Join(dir, "ignition.json")); err != nil {
+			return err
+		}
+		url := qc.ConfigServer.Add(id, conf.Bytes())
+		switch {
+		case conf.IsIgnition() && qc.flight.opts.Distribution == "cl":
+			args = append(args, "coreos.first_boot=1", "coreos.config.url="+url)
+		case conf.IsIgnition():
+			args = append(args, "ignition.firstboot", "ignition.platform.id=metal", "ignition.config.url="+url)
+		case qc.flight.opts.Distribution == "cl":
+			args = append(args, "cloud-config-url="+url)
+		default:
+			return fmt.Errorf("booting from the network needs an Ignition config")
+		}
+	}
+	args = append(args, strings.Fields(qc.flight.opts.PXEAppend)...)
+	args = append(args, extraArgs...)
+	server.AddMachine(mac, args)
+	return nil
+}
+
 func (qc *Cluster) Destroy() {
 	qc.LocalCluster.Destroy()
 	qc.flight.DelCluster(qc)
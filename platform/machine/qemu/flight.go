@@ -40,6 +40,38 @@ type Options struct {
 	// It can be a plain name, or a full path.
 	BIOSImage string
 
+	// Firmware is the firmware type, one of the platform.Firmware*
+	// constants.
+	Firmware string
+	// OVMFCode and OVMFVars are the UEFI firmware image and the
+	// template for its variable store.  If unset, OVMF is searched for
+	// in the usual places.
+	OVMFCode string
+	OVMFVars string
+
+	// SWTPM gives each machine a TPM 2.0 emulated by swtpm.
+	SWTPM bool
+
+	// Emulate runs machines with TCG emulation even if KVM could run
+	// them.  Boards of other architectures are always emulated.
+	Emulate bool
+
+	// Boot is the default boot mode, one of the platform.Boot*
+	// constants.
+	Boot string
+	// PXEKernel, PXEInitrd and PXERootfs are served to machines
+	// booting from the network.  PXERootfs is optional.  PXEAppend is
+	// added to their kernel command line.
+	PXEKernel string
+	PXEInitrd string
+	PXERootfs string
+	PXEAppend string
+
+	// WarmBootCache is a directory of disk images of machines which
+	// have completed their first boot.  If set, machines boot from
+	// overlays of such an image, which is created if needed.
+	WarmBootCache string
+
 	// Don't modify CL disk images to add console logging
 	UseVanillaImage bool
 
@@ -52,14 +84,17 @@ type flight struct {
 
 	diskImagePath string
 	diskImageFile *os.File
+	warm          *platform.WarmBootCache
 }
 
 var (
 	plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "platform/machine/qemu")
 )
 
-func NewFlight(opts *Options) (platform.Flight, error) {
-	lf, err := local.NewLocalFlight(opts.Options, Platform)
+func NewFlight(opts *Options, journal *platform.ResourceJournal) (platform.Flight, error) {
+	// only serve network boots if there is something to boot
+	pxe := opts.PXEKernel != "" && opts.PXEInitrd != ""
+	lf, err := local.NewLocalFlight(opts.Options, journal, Platform, pxe)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +104,14 @@ func NewFlight(opts *Options) (platform.Flight, error) {
 		opts:          opts,
 		diskImagePath: opts.DiskImage,
 	}
+	if pxe {
+		lf.PXEServer.Kernel = opts.PXEKernel
+		lf.PXEServer.Initrd = opts.PXEInitrd
+		lf.PXEServer.Rootfs = opts.PXERootfs
+	}
+	if opts.WarmBootCache != "" {
+		qf.warm = &platform.WarmBootCache{Dir: opts.WarmBootCache}
+	}
 
 	if opts.Distribution != "cl" {
 		// don't apply CL-specific mangling
@@ -104,6 +147,15 @@ func NewFlight(opts *Options) (platform.Flight, error) {
 	return qf, nil
 }
 
+// specialization describes the changes made to the disk image for the
+// flight, for the warm-boot cache.
+func (qf *flight) specialization() string {
+	if qf.diskImageFile != nil {
+		return "console"
+	}
+	return ""
+}
+
 // NewCluster creates a Cluster instance, suitable for running virtual
 // machines in QEMU.
 func (qf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
@@ -25,13 +25,19 @@ import (
 )
 
 type machine struct {
+	*platform.QEMUControl
+
 	qc          *Cluster
 	id          string
 	qemu        exec.Cmd
+	swtpm       *platform.SWTPM
 	netif       *local.Interface
 	journal     *platform.Journal
 	consolePath string
 	console     string
+	serial      *platform.Console
+	virtiofsd   *platform.Virtiofsd
+	emulated    bool
 }
 
 func (m *machine) ID() string {
@@ -47,6 +53,10 @@ func (m *machine) PrivateIP() string {
 }
 
 func (m *machine) RuntimeConf() platform.RuntimeConfig {
+	if m.emulated {
+		// TCG is much slower than KVM
+		return platform.EmulatedRuntimeConf(m.qc.RuntimeConf())
+	}
 	return m.qc.RuntimeConf()
 }
 
@@ -66,22 +76,51 @@ func (m *machine) Reboot() error {
 	return platform.RebootMachine(m, m.journal)
 }
 
+// Destroy stops the machine and releases everything set up for it.  It
+// also cleans up after newMachine fails partway, so any of the machine's
+// fields may be unset.
 func (m *machine) Destroy() {
-	if err := m.qemu.Kill(); err != nil {
-		plog.Errorf("Error killing instance %v: %v", m.ID(), err)
+	if m.qemu != nil {
+		if err := m.qemu.Kill(); err != nil {
+			plog.Errorf("Error killing instance %v: %v", m.ID(), err)
+		}
+	}
+	if m.QEMUControl != nil {
+		m.QEMUControl.Close()
+	}
+	if m.serial != nil {
+		m.serial.Close()
+	}
+	if m.virtiofsd != nil {
+		m.virtiofsd.Destroy()
+	}
+	if m.qc.flight.PXEServer != nil {
+		m.qc.flight.PXEServer.RemoveMachine(m.netif.HardwareAddr)
+	}
+	m.qc.ConfigServer.Remove(m.id)
+	if m.swtpm != nil {
+		m.swtpm.Destroy()
 	}
 
-	m.journal.Destroy()
+	if m.journal != nil {
+		m.journal.Destroy()
+	}
 
-	if buf, err := ioutil.ReadFile(m.consolePath); err == nil {
-		m.console = string(buf)
-	} else {
-		plog.Errorf("Error reading console for instance %v: %v", m.ID(), err)
+	if m.qemu != nil {
+		if buf, err := ioutil.ReadFile(m.consolePath); err == nil {
+			m.console = string(buf)
+		} else {
+			plog.Errorf("Error reading console for instance %v: %v", m.ID(), err)
+		}
 	}
 
 	m.qc.DelMach(m)
 }
 
+func (m *machine) Console() *platform.Console {
+	return m.serial
+}
+
 func (m *machine) ConsoleOutput() string {
 	return m.console
 }
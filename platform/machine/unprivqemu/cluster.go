@@ -29,6 +29,8 @@ import (
 
 	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
+	"github.com/coreos/mantle/platform/local"
+	"github.com/coreos/mantle/platform/qmp"
 	"github.com/coreos/mantle/system/exec"
 	"github.com/coreos/mantle/util"
 )
@@ -41,7 +43,8 @@ type Cluster struct {
 	*platform.BaseCluster
 	flight *flight
 
-	mu sync.Mutex
+	mu     sync.Mutex
+	server *local.ConfigServer
 }
 
 func (qc *Cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
@@ -49,6 +52,30 @@ func (qc *Cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 }
 
 func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	image := qc.flight.diskImagePath
+	if qc.flight.warm != nil && options.Boot == platform.BootDisk && qc.flight.opts.Boot == platform.BootDisk && !qc.RuntimeConf().NoSSHKeyInUserData {
+		image = qc.flight.warm.Image(qc.flight.opts.DiskImage, "", image, qc.flight.opts.Distribution, func(image string) (platform.QEMUMachine, error) {
+			return qc.newMachine(nil, platform.MachineOptions{NoShutdown: true}, image)
+		})
+	}
+	return qc.newMachine(userdata, options, image)
+}
+
+// newMachine boots a machine from an overlay of the disk image at
+// diskImagePath.
+func (qc *Cluster) newMachine(userdata *conf.UserData, options platform.MachineOptions, diskImagePath string) (*machine, error) {
+	if options.Boot == platform.BootDisk {
+		options.Boot = qc.flight.opts.Boot
+	}
+	if options.Boot != platform.BootDisk {
+		// there is no DHCP server to direct machines to iPXE
+		return nil, fmt.Errorf("booting from the network is only supported on the qemu platform")
+	}
+	if len(options.NICs) > 0 {
+		// user networking has no segments to attach them to
+		return nil, fmt.Errorf("additional NICs are only supported on the qemu platform")
+	}
+
 	id := uuid.New()
 
 	dir := filepath.Join(qc.RuntimeConf().OutputDir, id)
@@ -67,30 +94,67 @@ func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platfo
 	}
 	qc.mu.Unlock()
 
+	qm := &machine{
+		qc:          qc,
+		id:          id,
+		consolePath: filepath.Join(dir, "console.txt"),
+	}
+
+	// qm.Destroy releases whatever has been set up so far, so it
+	// cleans up if any step fails before the machine is added.
+	var qmpClient *os.File
+	added := false
+	defer func() {
+		if added {
+			return
+		}
+		if qmpClient != nil {
+			qmpClient.Close()
+		}
+		qm.Destroy()
+	}()
+
+	if options.ConfigDelivery == platform.ConfigDeliveryDefault {
+		options.ConfigDelivery = qc.RuntimeConf().ConfigDelivery
+	}
+	var server *local.ConfigServer
+	if options.ConfigDelivery == platform.ConfigDeliveryHTTP {
+		if server, err = qc.configServer(); err != nil {
+			return nil, err
+		}
+	}
 	var confPath string
-	if conf.IsIgnition() {
-		confPath = filepath.Join(dir, "ignition.json")
-		if err := conf.WriteFile(confPath); err != nil {
+	// by default, an empty config isn't passed at all
+	if !conf.IsEmpty() || options.ConfigDelivery != platform.ConfigDeliveryDefault {
+		options.ConfigDelivery, confPath, err = local.WriteQEMUConfig(conf, dir, id, options.ConfigDelivery, server)
+		if err != nil {
 			return nil, err
 		}
-	} else if conf.IsEmpty() {
-	} else {
-		return nil, fmt.Errorf("unprivileged qemu only supports Ignition or empty configs")
 	}
 
-	journal, err := platform.NewJournal(dir)
+	qm.journal, err = platform.NewJournal(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	qm := &machine{
-		qc:          qc,
-		id:          id,
-		journal:     journal,
-		consolePath: filepath.Join(dir, "console.txt"),
+	opts := qc.flight.opts
+	if options.Firmware == "" {
+		options.Firmware = opts.Firmware
+	}
+	options.Emulate = options.Emulate || opts.Emulate
+	qm.emulated = platform.QEMUEmulated(opts.Board, options.Emulate)
+	firmware, err := platform.SetupQEMUFirmware(&options, opts.Board, opts.BIOSImage, opts.OVMFCode, opts.OVMFVars, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	qm.virtiofsd = platform.SetupVirtiofsd(&options, qm.id)
+	qm.serial, err = platform.SetupQEMUConsole(&options)
+	if err != nil {
+		return nil, err
 	}
 
-	qmCmd, extraFiles, err := platform.CreateQEMUCommand(qc.flight.opts.Board, qm.id, qc.flight.opts.BIOSImage, qm.consolePath, confPath, qc.flight.diskImagePath, conf.IsIgnition(), options)
+	qmCmd, extraFiles, err := platform.CreateQEMUCommand(opts.Board, qm.id, firmware, qm.consolePath, confPath, diskImagePath, options)
 	if err != nil {
 		return nil, err
 	}
@@ -99,22 +163,62 @@ func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platfo
 		defer file.Close()
 	}
 
+	qmpFile, qmpClient, err := qmp.NewSocketPair()
+	if err != nil {
+		return nil, err
+	}
+	defer qmpFile.Close()
+	qmCmd = append(qmCmd, qmp.Args(3+len(extraFiles))...)
+	extraFiles = append(extraFiles, qmpFile)
+
+	if options.SWTPM || opts.SWTPM {
+		if qm.swtpm, err = platform.StartSWTPM(dir); err != nil {
+			return nil, err
+		}
+		defer qm.swtpm.File.Close()
+		tpmArgs, err := qm.swtpm.QEMUArgs(opts.Board, 3+len(extraFiles))
+		if err != nil {
+			return nil, err
+		}
+		qmCmd = append(qmCmd, tpmArgs...)
+		extraFiles = append(extraFiles, qm.swtpm.File)
+	}
+
 	qc.mu.Lock()
 
-	qmCmd = append(qmCmd, "-netdev", "user,id=eth0,restrict=yes,hostfwd=tcp:127.0.0.1:0-:22", "-device", platform.Virtio(qc.flight.opts.Board, "net", "netdev=eth0"))
+	// The guest is isolated from the host and the Internet.  If it
+	// fetches its config over HTTP, it can reach only the config
+	// server, through a forward which is exempt from restrict.  QEMU
+	// runs nc for each connection to the forward, so the guest can
+	// fetch as many times as it likes.
+	netdev := "user,id=eth0,restrict=yes,hostfwd=tcp:127.0.0.1:0-:22"
+	if server != nil {
+		netdev += fmt.Sprintf(",guestfwd=tcp:%s:%d-cmd:nc 127.0.0.1 %d", server.Host, server.Port(), server.Port())
+	}
+	qmCmd = append(qmCmd, "-netdev", netdev, "-device", platform.Virtio(qc.flight.opts.Board, "net", "netdev=eth0"))
 
 	plog.Debugf("NewMachine: %q", qmCmd)
 
-	qm.qemu = exec.Command(qmCmd[0], qmCmd[1:]...)
+	qemu := exec.Command(qmCmd[0], qmCmd[1:]...)
 
 	qc.mu.Unlock()
 
-	cmd := qm.qemu.(*exec.ExecCmd)
-	cmd.Stderr = os.Stderr
+	qemu.Stderr = os.Stderr
+
+	qemu.ExtraFiles = append(qemu.ExtraFiles, extraFiles...)
+
+	if err = qm.virtiofsd.Start(); err != nil {
+		return nil, err
+	}
 
-	cmd.ExtraFiles = append(cmd.ExtraFiles, extraFiles...)
+	if err = qemu.Start(); err != nil {
+		return nil, err
+	}
+	qm.qemu = qemu
+	qm.serial.Started()
 
-	if err = qm.qemu.Start(); err != nil {
+	qm.QEMUControl, err = platform.NewQEMUControl(qmpClient, qc.flight.opts.Board, dir)
+	if err != nil {
 		return nil, err
 	}
 
@@ -132,17 +236,49 @@ func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platfo
 	}
 
 	if err := platform.StartMachine(qm, qm.journal); err != nil {
-		qm.Destroy()
+		return nil, err
+	}
+
+	if err := platform.MountSharedDirs(qm, options.SharedDirs); err != nil {
 		return nil, err
 	}
 
 	qc.AddMach(qm)
+	added = true
 
 	return qm, nil
 }
 
+// configServerHost is the address guests reach the config server at.
+// It is only forwarded to the server, unlike 10.0.2.2 which would be
+// the whole host.
+const configServerHost = "10.0.2.100"
+
+// configServer returns the cluster's config server, starting it if
+// needed.  It listens on localhost, and guests reach it through a
+// guestfwd as configServerHost.
+func (qc *Cluster) configServer() (*local.ConfigServer, error) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	if qc.server == nil {
+		if _, err := exec.LookPath("nc"); err != nil {
+			return nil, fmt.Errorf("forwarding guests to the config server requires nc: %v", err)
+		}
+		server, err := local.NewConfigServer("127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("starting config server: %v", err)
+		}
+		server.Host = configServerHost
+		qc.server = server
+	}
+	return qc.server, nil
+}
+
 func (qc *Cluster) Destroy() {
 	qc.BaseCluster.Destroy()
+	if qc.server != nil {
+		qc.server.Destroy()
+	}
 	qc.flight.DelCluster(qc)
 }
 
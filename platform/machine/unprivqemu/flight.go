@@ -33,14 +33,15 @@ type flight struct {
 
 	diskImagePath string
 	diskImageFile *os.File
+	warm          *platform.WarmBootCache
 }
 
 var (
 	plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "platform/machine/qemu")
 )
 
-func NewFlight(opts *qemu.Options) (platform.Flight, error) {
-	bf, err := platform.NewBaseFlight(opts.Options, Platform, "")
+func NewFlight(opts *qemu.Options, journal *platform.ResourceJournal) (platform.Flight, error) {
+	bf, err := platform.NewBaseFlight(opts.Options, journal, Platform, "")
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +51,9 @@ func NewFlight(opts *qemu.Options) (platform.Flight, error) {
 		opts:          opts,
 		diskImagePath: opts.DiskImage,
 	}
+	if opts.WarmBootCache != "" {
+		qf.warm = &platform.WarmBootCache{Dir: opts.WarmBootCache}
+	}
 
 	return qf, nil
 }
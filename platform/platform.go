@@ -168,8 +168,46 @@ type RuntimeConfig struct {
 	NoSSHKeyInMetadata bool // don't add SSH key to platform metadata
 	NoEnableSelinux    bool // don't enable selinux when starting or rebooting a machine
 	AllowFailedUnits   bool // don't fail CheckMachine if a systemd unit has failed
+
+	// ConfigDelivery selects how local platforms pass configs to
+	// machines; other platforms ignore it.
+	ConfigDelivery ConfigDelivery
+
+	// TimeoutMultiplier scales the time machines are given to become
+	// reachable, for platforms that are slower than usual.  Zero
+	// means 1.
+	TimeoutMultiplier int
+}
+
+// sshRetriesFor returns how many times to try to reach m over SSH.
+func sshRetriesFor(m Machine) int {
+	if n := m.RuntimeConf().TimeoutMultiplier; n > 1 {
+		return sshRetries * n
+	}
+	return sshRetries
 }
 
+// ConfigDelivery is a way of passing a config to a local machine.
+type ConfigDelivery string
+
+const (
+	// ConfigDeliveryDefault uses fw_cfg for Ignition configs and a 9p
+	// config drive for everything else.
+	ConfigDeliveryDefault ConfigDelivery = ""
+	// ConfigDeliveryFwCfg passes an Ignition config with QEMU fw_cfg.
+	ConfigDeliveryFwCfg ConfigDelivery = "fw_cfg"
+	// ConfigDeliveryHTTP serves an Ignition config from a per-cluster
+	// HTTP server and passes a pointer config with fw_cfg, so that
+	// Ignition fetches the real config remotely.
+	ConfigDeliveryHTTP ConfigDelivery = "http"
+	// ConfigDelivery9p shares an OpenStack config drive tree with 9p.
+	ConfigDelivery9p ConfigDelivery = "9p"
+	// ConfigDeliveryConfigDrive attaches an OpenStack config drive ISO.
+	ConfigDeliveryConfigDrive ConfigDelivery = "config-drive"
+	// ConfigDeliveryNoCloud attaches a cloud-init NoCloud seed ISO.
+	ConfigDeliveryNoCloud ConfigDelivery = "nocloud"
+)
+
 // Wrap a StdoutPipe as a io.ReadCloser
 type sshPipe struct {
 	s   *ssh.Session
@@ -337,7 +375,7 @@ func CheckMachine(ctx context.Context, m Machine) error {
 		return nil
 	}
 
-	if err := util.Retry(sshRetries, sshTimeout, sshChecker); err != nil {
+	if err := util.Retry(sshRetriesFor(m), sshTimeout, sshChecker); err != nil {
 		return fmt.Errorf("ssh unreachable: %v", err)
 	}
 
@@ -33,15 +33,97 @@ import (
 
 type MachineOptions struct {
 	AdditionalDisks []Disk
+	// ConfigDelivery overrides RuntimeConfig.ConfigDelivery for the
+	// machine.  CreateQEMUCommand expects it to be resolved to a
+	// concrete method, or left empty if there is no config.
+	ConfigDelivery ConfigDelivery
+	// Firmware overrides the flight's firmware type, one of the
+	// Firmware* constants.
+	Firmware string
+	// SWTPM gives the machine a software TPM, even if the flight
+	// doesn't.
+	SWTPM bool
+	// Boot selects how the machine boots, one of the Boot* constants.
+	Boot string
+	// KernelArgs are added to the kernel command line when booting
+	// from the network.
+	KernelArgs []string
+	// InstallDiskSize is the size of the blank disk attached for
+	// BootPXEInstall.  The default is 8G.
+	InstallDiskSize string
+	// Emulate runs the machine with TCG emulation even if KVM could
+	// run it.
+	Emulate bool
+	// NoShutdown keeps QEMU running after the machine powers off, so
+	// that its disks can still be reached through QMP.
+	NoShutdown bool
+	// SharedDirs are host directories exported to the machine.
+	SharedDirs []SharedDir
+	// NICs are network interfaces in addition to the primary one.
+	// Only the qemu platform supports them.
+	NICs []NIC
+
+	// firmwareVars is the machine's UEFI variable store, set up by
+	// SetupQEMUFirmware.
+	firmwareVars string
+	// console is QEMU's end of the serial console, set up by
+	// SetupQEMUConsole.
+	console *os.File
+	// virtiofsSockets are the virtiofsd sockets for SharedDirs, set
+	// up by SetupVirtiofsd.
+	virtiofsSockets []string
 }
 
+// QEMU boot modes.
+const (
+	// BootDisk boots the flight's disk image.
+	BootDisk = ""
+	// BootPXE boots from the network without a disk.
+	BootPXE = "pxe"
+	// BootPXEInstall boots from the network once, with a blank disk
+	// for the OS to install itself to, and from the disk afterward.
+	BootPXEInstall = "pxe-install"
+)
+
 type Disk struct {
 	Size        string   // disk image size in bytes, optional suffixes "K", "M", "G", "T" allowed. Incompatible with BackingFile
 	BackingFile string   // raw disk image to use. Incompatible with Size.
 	DeviceOpts  []string // extra options to pass to qemu. "serial=XXXX" makes disks show up as /dev/disk/by-id/virtio-<serial>
 	ConfPath    string   // path to ignition to be able to use it with guestfs for temporary qcow2 images
+
+	// ID names the disk's drive and device, so that QEMUMachine methods
+	// can refer to it.  Optional.
+	ID string
+	// Fault makes I/O to the disk fail.  Optional.
+	Fault *DiskFault
+	// ThrottleBPS and ThrottleIOPS limit the disk's total bandwidth in
+	// bytes and operations per second.  Zero is unlimited.
+	ThrottleBPS  int64
+	ThrottleIOPS int64
 }
 
+// NIC is an additional network interface for a QEMU machine.  Its
+// addresses are substituted for $nicN_mac, $nicN_ipv4 and $nicN_ipv6 in
+// the machine's config, where N counts the machine's NICs from 1.
+type NIC struct {
+	// Segment names the network segment the NIC is attached to.
+	// Machines in a cluster naming the same segment share it, and it
+	// is created when first used.  "" is the flight's network.
+	Segment string
+	// IPv6Only gives a new segment only IPv6 addresses.
+	IPv6Only bool
+	// VLAN is the 802.1Q tag of a new segment's traffic, so machines
+	// must configure a VLAN interface with this ID to use it.  Zero is
+	// untagged.
+	VLAN int
+	// Static keeps the NIC out of DHCP, so the machine's config must
+	// assign its addresses.
+	Static bool
+}
+
+// PrimaryDiskID is the Disk.ID of a machine's boot disk.
+const PrimaryDiskID = "primary-disk"
+
 var (
 	ErrNeedSizeOrFile  = errors.New("Disks need either Size or BackingFile specified")
 	ErrBothSizeAndFile = errors.New("Only one of Size and BackingFile can be specified")
@@ -228,82 +310,137 @@ func mkpath(basedir string) (string, error) {
 	return f.Name(), nil
 }
 
-func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImagePath string, isIgnition bool, options MachineOptions) ([]string, []*os.File, error) {
+// qemuBoard describes how to run a board in QEMU, with KVM on a host
+// of the same architecture and with TCG emulation otherwise.
+type qemuBoard struct {
+	binary string
+	memory string
+	kvm    []string
+	tcg    []string
+}
+
+// As we expand this list of supported boards we should coordinate
+// with the coreos-assembler folks as they utilize something similar in
+// cosa run
+var qemuBoards = map[string]qemuBoard{
+	"amd64-usr": {
+		binary: "qemu-system-x86_64",
+		memory: "1024",
+		kvm:    []string{"-machine", "accel=kvm", "-cpu", "host"},
+		tcg:    []string{"-machine", "pc-q35-2.8", "-cpu", "kvm64"},
+	},
+	"arm64-usr": {
+		binary: "qemu-system-aarch64",
+		memory: "2048",
+		kvm:    []string{"-machine", "virt,accel=kvm,gic-version=3", "-cpu", "host"},
+		tcg:    []string{"-machine", "virt", "-cpu", "cortex-a57"},
+	},
+	"s390x-usr": {
+		binary: "qemu-system-s390x",
+		memory: "2048",
+		kvm:    []string{"-machine", "s390-ccw-virtio,accel=kvm", "-cpu", "host"},
+		tcg:    []string{"-machine", "s390-ccw-virtio", "-cpu", "qemu"},
+	},
+}
+
+// EmulationTimeoutMultiplier is how much longer machines emulated with
+// TCG are given to boot and respond.
+const EmulationTimeoutMultiplier = 5
+
+// EmulatedRuntimeConf returns rconf for a machine emulated with TCG,
+// which is given longer to become reachable.
+func EmulatedRuntimeConf(rconf RuntimeConfig) RuntimeConfig {
+	if rconf.TimeoutMultiplier < EmulationTimeoutMultiplier {
+		rconf.TimeoutMultiplier = EmulationTimeoutMultiplier
+	}
+	return rconf
+}
+
+// hostArch is the architecture KVM can run, replaced in tests.
+var hostArch = runtime.GOARCH
+
+// QEMUEmulated reports whether a QEMU machine for board runs under TCG
+// emulation rather than KVM, either because the board's architecture
+// isn't the host's or because force is set.
+func QEMUEmulated(board string, force bool) bool {
+	return force || strings.SplitN(board, "-", 2)[0] != hostArch
+}
+
+// CreateQEMUCommand returns the QEMU command line for a machine.
+// confPath is the config file, config drive directory or ISO image,
+// according to options.ConfigDelivery.
+func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImagePath string, options MachineOptions) ([]string, []*os.File, error) {
 	var qmCmd []string
 
-	// As we expand this list of supported native + board
-	// archs combos we should coordinate with the
-	// coreos-assembler folks as they utilize something
-	// similar in cosa run
-	var qmBinary string
-	combo := runtime.GOARCH + "--" + board
-	switch combo {
-	case "amd64--amd64-usr":
-		qmBinary = "qemu-system-x86_64"
-		qmCmd = []string{
-			"qemu-system-x86_64",
-			"-machine", "accel=kvm",
-			"-cpu", "host",
-			"-m", "1024",
-		}
-	case "amd64--arm64-usr":
-		qmBinary = "qemu-system-aarch64"
-		qmCmd = []string{
-			"qemu-system-aarch64",
-			"-machine", "virt",
-			"-cpu", "cortex-a57",
-			"-m", "2048",
-		}
-	case "arm64--amd64-usr":
-		qmBinary = "qemu-system-x86_64"
-		qmCmd = []string{
-			"qemu-system-x86_64",
-			"-machine", "pc-q35-2.8",
-			"-cpu", "kvm64",
-			"-m", "1024",
-		}
-	case "arm64--arm64-usr":
-		qmBinary = "qemu-system-aarch64"
-		qmCmd = []string{
-			"qemu-system-aarch64",
-			"-machine", "virt,accel=kvm,gic-version=3",
-			"-cpu", "host",
-			"-m", "2048",
-		}
-	case "s390x--s390x-usr":
-		qmBinary = "qemu-system-s390x"
-		qmCmd = []string{
-			"qemu-system-s390x",
-			"-machine", "s390-ccw-virtio,accel=kvm",
-			"-cpu", "host",
-			"-m", "2048",
-		}
-	default:
-		panic("host-guest combo not supported: " + combo)
+	qb, ok := qemuBoards[board]
+	if !ok {
+		panic("board not supported: " + board)
+	}
+	qmBinary := qb.binary
+	qmCmd = []string{qmBinary}
+	if QEMUEmulated(board, options.Emulate) {
+		qmCmd = append(qmCmd, qb.tcg...)
+	} else {
+		qmCmd = append(qmCmd, qb.kvm...)
 	}
+	qmCmd = append(qmCmd, "-m", qb.memory)
 
 	qmCmd = append(qmCmd,
 		"-smp", "1",
 		"-uuid", uuid,
 		"-display", "none",
-		"-chardev", "file,id=log,path="+consolePath,
-		"-serial", "chardev:log",
 	)
+	if options.NoShutdown {
+		qmCmd = append(qmCmd, "-no-shutdown")
+	}
 
-	if board != "s390x-usr" {
+	shareArgs, sharedMem, err := qemuShareArgs(board, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	qmCmd = append(qmCmd, shareArgs...)
+	if sharedMem {
+		// virtiofsd maps the machine's memory
+		qmCmd = append(qmCmd,
+			"-object", "memory-backend-memfd,id=mem,share=on,size="+qb.memory+"M",
+			"-numa", "node,memdev=mem")
+	}
+
+	switch {
+	case options.firmwareVars != "":
+		qmCmd = append(qmCmd,
+			"-drive", "if=pflash,format=raw,unit=0,readonly=on,file="+biosImage,
+			"-drive", "if=pflash,format=raw,unit=1,file="+options.firmwareVars)
+		if options.Firmware == FirmwareUEFISecure {
+			// Secure Boot needs SMM to protect the variable store
+			qmCmd = append(qmCmd,
+				"-machine", "q35,smm=on",
+				"-global", "driver=cfi.pflash01,property=secure,value=on")
+		}
+	case board != "s390x-usr":
 		qmCmd = append(qmCmd, "-bios", biosImage)
 	}
 
-	if isIgnition {
+	isIgnition := false
+	switch options.ConfigDelivery {
+	case ConfigDeliveryDefault:
+	case ConfigDeliveryFwCfg, ConfigDeliveryHTTP:
+		isIgnition = true
 		// -fw_cfg is not supported for s390x, instead guestfs utility is used
 		if board != "s390x-usr" {
 			qmCmd = append(qmCmd,
 				"-fw_cfg", "name=opt/com.coreos/config,file="+confPath)
 		}
-	} else {
+	case ConfigDelivery9p:
 		qmCmd = append(qmCmd,
 			"-fsdev", "local,id=cfg,security_model=none,readonly,path="+confPath,
 			"-device", Virtio(board, "9p", "fsdev=cfg,mount_tag=config-2"))
+	case ConfigDeliveryConfigDrive, ConfigDeliveryNoCloud:
+		qmCmd = append(qmCmd,
+			"-drive", "if=none,id=cfg,format=raw,readonly=on,file="+confPath,
+			"-device", Virtio(board, "blk", "drive=cfg"))
+	default:
+		return nil, nil, fmt.Errorf("unsupported config delivery %q", options.ConfigDelivery)
 	}
 
 	// auto-read-only is only available in 3.1.0 & greater versions of QEMU
@@ -330,14 +467,35 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 		BackingFile: diskImagePath,
 		DeviceOpts:  primaryDiskOptions,
 		ConfPath:    "",
+		ID:          PrimaryDiskID,
 	}
 
-	if board == "s390x-usr" {
+	if board == "s390x-usr" && isIgnition {
 		primaryDisk.ConfPath = confPath
 	}
 
 	allDisks := append([]Disk{primaryDisk}, options.AdditionalDisks...)
 
+	switch options.Boot {
+	case BootDisk:
+	case BootPXE:
+		allDisks = options.AdditionalDisks
+		qmCmd = append(qmCmd, "-boot", "order=n")
+	case BootPXEInstall:
+		size := options.InstallDiskSize
+		if size == "" {
+			size = "8G"
+		}
+		allDisks[0] = Disk{
+			Size:       size,
+			DeviceOpts: primaryDiskOptions,
+			ID:         PrimaryDiskID,
+		}
+		qmCmd = append(qmCmd, "-boot", "once=n")
+	default:
+		return nil, nil, fmt.Errorf("unknown boot mode %q", options.Boot)
+	}
+
 	var extraFiles []*os.File
 	fdnum := 3 // first additional file starts at position 3
 	fdset := 1
@@ -351,16 +509,64 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 		extraFiles = append(extraFiles, optionsDiskFile)
 
 		id := fmt.Sprintf("d%d", fdnum)
-		qmCmd = append(qmCmd, "-add-fd", fmt.Sprintf("fd=%d,set=%d", fdnum, fdset),
-			"-drive", fmt.Sprintf("if=none,id=%s,format=qcow2,file=/dev/fdset/%d%s", id, fdset, autoReadOnly),
-			"-device", Virtio(board, "blk", fmt.Sprintf("drive=%s%s", id, disk.getOpts())))
+		if disk.ID != "" {
+			id = disk.ID
+		}
+		qmCmd = append(qmCmd, "-add-fd", fmt.Sprintf("fd=%d,set=%d", fdnum, fdset))
 		fdnum += 1
+
+		drive := fmt.Sprintf("if=none,id=%s,format=qcow2,file=/dev/fdset/%d", id, fdset)
+		if disk.Fault != nil {
+			configFile, err := disk.Fault.setupBlkdebugConfig()
+			if err != nil {
+				return nil, nil, err
+			}
+			extraFiles = append(extraFiles, configFile)
+			// blkdebug sits between a raw node and the qcow2 image,
+			// so that it sees guest sector numbers and the raw
+			// node's read and write events
+			drive = fmt.Sprintf("if=none,id=%s,driver=raw,file.driver=blkdebug,file.config=/proc/self/fd/%d,"+
+				"file.image.driver=qcow2,file.image.file.driver=file,file.image.file.filename=/dev/fdset/%d",
+				id, fdnum, fdset)
+			fdnum += 1
+		}
+		drive += autoReadOnly
+		if disk.ThrottleBPS > 0 {
+			drive += fmt.Sprintf(",throttling.bps-total=%d", disk.ThrottleBPS)
+		}
+		if disk.ThrottleIOPS > 0 {
+			drive += fmt.Sprintf(",throttling.iops-total=%d", disk.ThrottleIOPS)
+		}
+
+		deviceOpts := disk.getOpts()
+		if disk.ID != "" {
+			deviceOpts += ",id=" + disk.ID
+		}
+		qmCmd = append(qmCmd, "-drive", drive,
+			"-device", Virtio(board, "blk", fmt.Sprintf("drive=%s%s", id, deviceOpts)))
 		fdset += 1
 	}
 
+	qmCmd = append(qmCmd, qemuConsoleArgs(consolePath, fdnum, options.console != nil)...)
+	if options.console != nil {
+		extraFiles = append(extraFiles, options.console)
+		fdnum += 1
+	}
+
 	return qmCmd, extraFiles, nil
 }
 
+// qemuConsoleArgs returns the arguments which log the serial console to
+// consolePath, and if stream is set also stream it over the socket
+// passed as fd fdnum.
+func qemuConsoleArgs(consolePath string, fdnum int, stream bool) []string {
+	chardev := "file,id=log,path=" + qemuEscape(consolePath)
+	if stream {
+		chardev = fmt.Sprintf("socket,id=log,fd=%d,logfile=%s", fdnum, qemuEscape(consolePath))
+	}
+	return []string{"-chardev", chardev, "-serial", "chardev:log"}
+}
+
 // The virtio device name differs between machine types but otherwise
 // configuration is the same. Use this to help construct device args.
 func Virtio(board, device, args string) string {
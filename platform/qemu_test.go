@@ -0,0 +1,115 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQEMUEmulated(t *testing.T) {
+	defer func(arch string) {
+		hostArch = arch
+	}(hostArch)
+	hostArch = "amd64"
+
+	for _, tt := range []struct {
+		board    string
+		force    bool
+		emulated bool
+	}{
+		{"amd64-usr", false, false},
+		{"amd64-usr", true, true},
+		{"arm64-usr", false, true},
+		{"arm64-usr", true, true},
+		{"s390x-usr", false, true},
+	} {
+		if _, ok := qemuBoards[tt.board]; !ok {
+			t.Errorf("%s: board not supported", tt.board)
+		}
+		if emulated := QEMUEmulated(tt.board, tt.force); emulated != tt.emulated {
+			t.Errorf("%s, forced %v: got emulated %v, expected %v", tt.board, tt.force, emulated, tt.emulated)
+		}
+	}
+
+	hostArch = "arm64"
+	if QEMUEmulated("arm64-usr", false) {
+		t.Error("arm64-usr emulated on arm64")
+	}
+	if !QEMUEmulated("amd64-usr", false) {
+		t.Error("amd64-usr not emulated on arm64")
+	}
+}
+
+func TestQEMUBoards(t *testing.T) {
+	binaries := map[string]string{
+		"amd64-usr": "qemu-system-x86_64",
+		"arm64-usr": "qemu-system-aarch64",
+		"s390x-usr": "qemu-system-s390x",
+	}
+	for board, qb := range qemuBoards {
+		if qb.binary != binaries[board] {
+			t.Errorf("%s: got binary %q, expected %q", board, qb.binary, binaries[board])
+		}
+		if qb.memory == "" {
+			t.Errorf("%s: no memory size", board)
+		}
+		// KVM passes through the host's CPU, which TCG can't emulate
+		if !contains(qb.kvm, "host") || contains(qb.tcg, "host") {
+			t.Errorf("%s: kvm arguments %q, tcg arguments %q", board, qb.kvm, qb.tcg)
+		}
+	}
+}
+
+func TestEmulatedRuntimeConf(t *testing.T) {
+	for _, tt := range []struct {
+		multiplier int
+		expected   int
+	}{
+		{0, EmulationTimeoutMultiplier},
+		{1, EmulationTimeoutMultiplier},
+		{EmulationTimeoutMultiplier + 1, EmulationTimeoutMultiplier + 1},
+	} {
+		rconf := EmulatedRuntimeConf(RuntimeConfig{OutputDir: "out", TimeoutMultiplier: tt.multiplier})
+		if rconf.TimeoutMultiplier != tt.expected || rconf.OutputDir != "out" {
+			t.Errorf("multiplier %d: got %+v, expected multiplier %d", tt.multiplier, rconf, tt.expected)
+		}
+	}
+}
+
+func TestQEMUConsoleArgs(t *testing.T) {
+	for _, tt := range []struct {
+		stream   bool
+		expected string
+	}{
+		{false, "file,id=log,path=/out/a,,b/console.txt"},
+		{true, "socket,id=log,fd=4,logfile=/out/a,,b/console.txt"},
+	} {
+		args := qemuConsoleArgs("/out/a,b/console.txt", 4, tt.stream)
+		expected := []string{"-chardev", tt.expected, "-serial", "chardev:log"}
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("stream %v: got %q, expected %q", tt.stream, args, expected)
+		}
+	}
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/platform/qmp"
+	"github.com/coreos/mantle/system/exec"
+)
+
+// unplugTimeout bounds how long the guest takes to release an unplugged
+// device.
+const unplugTimeout = time.Minute
+
+// QEMUMachine is a Machine running in a local QEMU process, which tests
+// can control through the QEMU monitor.  Tests should type-assert a
+// Machine to QEMUMachine and skip if it isn't one.
+type QEMUMachine interface {
+	Machine
+
+	// Pause stops the machine's CPUs, and Resume restarts them.
+	Pause() error
+	Resume() error
+
+	// PowerButton presses the ACPI power button.
+	PowerButton() error
+
+	// HardReset resets the machine without shutting down the OS.
+	HardReset() error
+
+	// HotplugDisk adds an empty disk of the given size, which appears
+	// in the machine as /dev/disk/by-id/virtio-<id>.  UnplugDisk
+	// removes it.
+	HotplugDisk(id, size string) error
+	UnplugDisk(id string) error
+
+	// SurpriseRemoveDisk pulls a disk out from under the guest, so
+	// that I/O to it fails.  id is a Disk.ID.
+	SurpriseRemoveDisk(id string) error
+
+	// ThrottleDisk limits a disk's total bandwidth in bytes and
+	// operations per second, or removes the limits if both are zero.
+	// id is a Disk.ID or hot-plugged disk.
+	ThrottleDisk(id string, bps, iops int64) error
+
+	// CommitDisk writes the changes to a disk into its backing file.
+	// id is a Disk.ID, such as PrimaryDiskID.
+	CommitDisk(id string) error
+
+	// HotplugNIC adds a NIC with user networking, and UnplugNIC
+	// removes it.
+	HotplugNIC(id string) error
+	UnplugNIC(id string) error
+
+	// SaveSnapshot saves the running machine's RAM and disks, and
+	// LoadSnapshot restores them.
+	SaveSnapshot(name string) error
+	LoadSnapshot(name string) error
+
+	// WaitEvent waits for a QMP event such as SHUTDOWN, RESET or
+	// POWERDOWN.
+	WaitEvent(name string, timeout time.Duration) (qmp.Event, error)
+
+	// Console returns the machine's serial console, which can be used
+	// while the machine runs.
+	Console() *Console
+}
+
+// QEMUControl implements the QEMUMachine control methods for QEMU-based
+// platforms.
+type QEMUControl struct {
+	*qmp.Client
+
+	board string
+	dir   string
+}
+
+// NewQEMUControl connects to the QMP socket f of a QEMU process
+// running board.  Hot-plugged disks are created in dir.
+func NewQEMUControl(f *os.File, board, dir string) (*QEMUControl, error) {
+	client, err := qmp.Connect(f)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to QEMU monitor: %v", err)
+	}
+	return &QEMUControl{
+		Client: client,
+		board:  board,
+		dir:    dir,
+	}, nil
+}
+
+func (c *QEMUControl) diskPath(id string) string {
+	return filepath.Join(c.dir, "hotplug-"+id+".qcow2")
+}
+
+// virtioDriver returns the virtio device driver name for the board.
+func (c *QEMUControl) virtioDriver(device string) string {
+	return strings.SplitN(Virtio(c.board, device, ""), ",", 2)[0]
+}
+
+func (c *QEMUControl) HotplugDisk(id, size string) error {
+	path := c.diskPath(id)
+	out, err := exec.Command("qemu-img", "create", "-f", "qcow2", path, size).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("creating disk %s: %v: %s", id, err, strings.TrimSpace(string(out)))
+	}
+	if err := c.AddDisk(id, path, c.virtioDriver("blk")); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+func (c *QEMUControl) UnplugDisk(id string) error {
+	if err := c.RemoveDisk(id, unplugTimeout); err != nil {
+		return err
+	}
+	return os.Remove(c.diskPath(id))
+}
+
+func (c *QEMUControl) HotplugNIC(id string) error {
+	return c.AddNIC(id, c.virtioDriver("net"))
+}
+
+func (c *QEMUControl) UnplugNIC(id string) error {
+	return c.RemoveNIC(id, unplugTimeout)
+}
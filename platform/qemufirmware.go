@@ -0,0 +1,204 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/coreos/mantle/system/exec"
+)
+
+// QEMU firmware types.
+const (
+	// FirmwareBIOS boots with the BIOS image, which is SeaBIOS on
+	// amd64 and UEFI elsewhere.
+	FirmwareBIOS = "bios"
+	// FirmwareUEFI boots with OVMF.
+	FirmwareUEFI = "uefi"
+	// FirmwareUEFISecure boots with OVMF with Secure Boot enforced.
+	FirmwareUEFISecure = "uefi-secure"
+)
+
+// ovmfPaths are the locations of OVMF in common distros.  The Secure
+// Boot variable stores have the Microsoft keys enrolled.
+var ovmfPaths = []struct {
+	code, vars string
+	secure     bool
+}{
+	// Fedora
+	{"/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd", "/usr/share/edk2/ovmf/OVMF_VARS.secboot.fd", true},
+	{"/usr/share/edk2/ovmf/OVMF_CODE.fd", "/usr/share/edk2/ovmf/OVMF_VARS.fd", false},
+	// Debian and Ubuntu
+	{"/usr/share/OVMF/OVMF_CODE.secboot.fd", "/usr/share/OVMF/OVMF_VARS.ms.fd", true},
+	{"/usr/share/OVMF/OVMF_CODE.fd", "/usr/share/OVMF/OVMF_VARS.fd", false},
+}
+
+func findOVMF(secure bool) (string, string, error) {
+	for _, p := range ovmfPaths {
+		if p.secure != secure {
+			continue
+		}
+		if _, err := os.Stat(p.code); err != nil {
+			continue
+		}
+		if _, err := os.Stat(p.vars); err != nil {
+			continue
+		}
+		return p.code, p.vars, nil
+	}
+	return "", "", fmt.Errorf("couldn't find OVMF; specify the firmware images")
+}
+
+// SetupQEMUFirmware prepares the firmware for a machine and returns the
+// firmware image to pass to CreateQEMUCommand.  options.Firmware
+// selects the firmware type.  UEFI firmware writes to its variable
+// store, so each machine gets a copy of varsTemplate in dir.  OVMF is
+// searched for if code or varsTemplate are empty.
+func SetupQEMUFirmware(options *MachineOptions, board, biosImage, code, varsTemplate, dir string) (string, error) {
+	switch options.Firmware {
+	case "", FirmwareBIOS:
+		options.Firmware = FirmwareBIOS
+		return biosImage, nil
+	case FirmwareUEFI, FirmwareUEFISecure:
+	default:
+		return "", fmt.Errorf("unknown firmware type %q", options.Firmware)
+	}
+	secure := options.Firmware == FirmwareUEFISecure
+	if board != "amd64-usr" {
+		if secure {
+			return "", fmt.Errorf("Secure Boot is only supported on amd64-usr")
+		}
+		// the BIOS image is already UEFI
+		return biosImage, nil
+	}
+
+	if code == "" || varsTemplate == "" {
+		foundCode, foundVars, err := findOVMF(secure)
+		if err != nil {
+			return "", err
+		}
+		if code == "" {
+			code = foundCode
+		}
+		if varsTemplate == "" {
+			varsTemplate = foundVars
+		}
+	}
+
+	vars := filepath.Join(dir, "ovmf-vars.fd")
+	if err := copyFile(varsTemplate, vars); err != nil {
+		return "", fmt.Errorf("copying OVMF variables: %v", err)
+	}
+	options.firmwareVars = vars
+	return code, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// SWTPM is a software TPM 2.0 for a QEMU machine, emulated by swtpm.
+type SWTPM struct {
+	// File is the socket to pass to QEMU with the arguments from
+	// QEMUArgs.  The caller should close it once QEMU has started, so
+	// that swtpm sees QEMU exit.
+	File *os.File
+
+	swtpm *exec.ExecCmd
+}
+
+// StartSWTPM starts swtpm with its state in dir.  swtpm exits when
+// QEMU does.
+func StartSWTPM(dir string) (*SWTPM, error) {
+	stateDir := filepath.Join(dir, "tpm")
+	if err := os.Mkdir(stateDir, 0700); err != nil {
+		return nil, err
+	}
+
+	// a socket pair avoids the length limit on socket paths
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("creating TPM socket: %v", err)
+	}
+	qemuEnd := os.NewFile(uintptr(fds[0]), "tpm-qemu")
+	swtpmEnd := os.NewFile(uintptr(fds[1]), "tpm-swtpm")
+	defer swtpmEnd.Close()
+
+	cmd := exec.Command("swtpm", "socket", "--tpm2",
+		"--tpmstate", "dir="+stateDir,
+		"--ctrl", "type=unixio,clientfd=3",
+		"--log", "file="+filepath.Join(dir, "swtpm.log"),
+		"--terminate")
+	cmd.ExtraFiles = []*os.File{swtpmEnd}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		qemuEnd.Close()
+		return nil, fmt.Errorf("starting swtpm: %v", err)
+	}
+	return &SWTPM{
+		File:  qemuEnd,
+		swtpm: cmd,
+	}, nil
+}
+
+// QEMUArgs returns the QEMU arguments for a TPM backed by the socket
+// passed to QEMU as file descriptor fd.
+func (t *SWTPM) QEMUArgs(board string, fd int) ([]string, error) {
+	var device string
+	switch board {
+	case "amd64-usr":
+		device = "tpm-tis"
+	case "arm64-usr":
+		device = "tpm-tis-device"
+	default:
+		return nil, fmt.Errorf("TPM is not supported on %s", board)
+	}
+	return []string{
+		"-chardev", fmt.Sprintf("socket,id=tpm,fd=%d", fd),
+		"-tpmdev", "emulator,id=tpm0,chardev=tpm",
+		"-device", device + ",tpmdev=tpm0",
+	}, nil
+}
+
+// Destroy stops swtpm.
+func (t *SWTPM) Destroy() {
+	err := t.swtpm.Kill()
+	// --terminate ends swtpm with whatever status once QEMU is gone,
+	// which isn't an error
+	if err != nil && t.swtpm.ProcessState != nil && t.swtpm.ProcessState.Exited() {
+		plog.Debugf("swtpm exited: %v", err)
+		return
+	}
+	if err != nil {
+		plog.Errorf("Error stopping swtpm: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/system/exec"
+	"github.com/coreos/mantle/util"
+)
+
+// SharedDir is a host directory exported to a QEMU machine.  The nth
+// directory has the mount tag "kola<n>", counting from 0.
+type SharedDir struct {
+	// HostPath is the directory to share.
+	HostPath string
+	// GuestPath is where the directory is mounted once the machine
+	// has booted.  If empty, it isn't mounted.
+	GuestPath string
+	// ReadOnly stops the machine from writing to the directory.
+	ReadOnly bool
+	// VirtioFS exports the directory with virtio-fs instead of 9p.
+	// It needs virtiofsd on the host, and can't be combined with
+	// ReadOnly since virtiofsd can't enforce it.
+	VirtioFS bool
+}
+
+// ParseSharedDir parses a share in the form host:guest[:ro|:virtiofs].
+func ParseSharedDir(spec string) (SharedDir, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return SharedDir{}, fmt.Errorf("shared directory %q isn't host:guest[:ro|:virtiofs]", spec)
+	}
+	share := SharedDir{
+		HostPath:  parts[0],
+		GuestPath: parts[1],
+	}
+	for _, opt := range parts[2:] {
+		switch opt {
+		case "ro":
+			share.ReadOnly = true
+		case "virtiofs":
+			share.VirtioFS = true
+		default:
+			return SharedDir{}, fmt.Errorf("unknown shared directory option %q", opt)
+		}
+	}
+	if share.ReadOnly && share.VirtioFS {
+		return SharedDir{}, fmt.Errorf("shared directory %q: virtio-fs shares can't be read-only", spec)
+	}
+	return share, nil
+}
+
+// qemuEscape escapes the commas in a QEMU option value.
+func qemuEscape(value string) string {
+	return strings.Replace(value, ",", ",,", -1)
+}
+
+func sharedDirTag(i int) string {
+	return fmt.Sprintf("kola%d", i)
+}
+
+// virtiofsdPaths are the locations of virtiofsd in common distros,
+// which don't put it in $PATH.
+var virtiofsdPaths = []string{
+	"/usr/libexec/virtiofsd",
+	"/usr/lib/qemu/virtiofsd",
+	"/usr/lib/virtiofsd",
+}
+
+func findVirtiofsd() string {
+	for _, path := range virtiofsdPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return "virtiofsd"
+}
+
+// Virtiofsd serves a machine's virtio-fs shared directories.
+type Virtiofsd struct {
+	dir     string
+	shares  []SharedDir
+	sockets []string
+	cmds    []*exec.ExecCmd
+}
+
+// SetupVirtiofsd records the sockets of virtiofsd for the machine id in
+// options for CreateQEMUCommand.  Start starts virtiofsd, and must be
+// called before QEMU starts.
+func SetupVirtiofsd(options *MachineOptions, id string) *Virtiofsd {
+	// socket paths have a length limit, so keep them short
+	v := &Virtiofsd{dir: filepath.Join(os.TempDir(), "kola-virtiofs-"+id)}
+	options.virtiofsSockets = nil
+	for i, share := range options.SharedDirs {
+		var sock string
+		if share.VirtioFS {
+			sock = filepath.Join(v.dir, fmt.Sprint(i))
+			v.shares = append(v.shares, share)
+			v.sockets = append(v.sockets, sock)
+		}
+		options.virtiofsSockets = append(options.virtiofsSockets, sock)
+	}
+	return v
+}
+
+// Start starts virtiofsd for each share and waits for it to listen.
+func (v *Virtiofsd) Start() error {
+	if len(v.shares) == 0 {
+		return nil
+	}
+	if err := os.Mkdir(v.dir, 0700); err != nil {
+		return err
+	}
+	for i, share := range v.shares {
+		cmd := exec.Command(findVirtiofsd(),
+			"--socket-path="+v.sockets[i],
+			"--shared-dir="+share.HostPath)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			v.Destroy()
+			return fmt.Errorf("starting virtiofsd: %v", err)
+		}
+		v.cmds = append(v.cmds, cmd)
+
+		listening := func() error {
+			_, err := os.Stat(v.sockets[i])
+			return err
+		}
+		if err := util.Retry(20, 500*time.Millisecond, listening); err != nil {
+			v.Destroy()
+			return fmt.Errorf("waiting for virtiofsd: %v", err)
+		}
+	}
+	return nil
+}
+
+// Destroy stops virtiofsd.
+func (v *Virtiofsd) Destroy() {
+	for _, cmd := range v.cmds {
+		if err := cmd.Kill(); err != nil {
+			plog.Errorf("Error stopping virtiofsd: %v", err)
+		}
+	}
+	v.cmds = nil
+	if len(v.shares) > 0 {
+		os.RemoveAll(v.dir)
+	}
+}
+
+// qemuShareArgs returns the QEMU arguments for the shared directories in
+// options, and whether the machine's memory must be shared with
+// virtiofsd.
+func qemuShareArgs(board string, options MachineOptions) ([]string, bool, error) {
+	var args []string
+	sharedMem := false
+	for i, share := range options.SharedDirs {
+		tag := sharedDirTag(i)
+		if !share.VirtioFS {
+			fsdev := fmt.Sprintf("local,id=share%d,security_model=none,path=%s", i, qemuEscape(share.HostPath))
+			if share.ReadOnly {
+				fsdev += ",readonly"
+			}
+			args = append(args, "-fsdev", fsdev,
+				"-device", Virtio(board, "9p", fmt.Sprintf("fsdev=share%d,mount_tag=%s", i, tag)))
+			continue
+		}
+
+		if share.ReadOnly {
+			return nil, false, fmt.Errorf("virtio-fs share %s can't be read-only", share.HostPath)
+		}
+		if i >= len(options.virtiofsSockets) || options.virtiofsSockets[i] == "" {
+			return nil, false, fmt.Errorf("virtio-fs shares need SetupVirtiofsd")
+		}
+		device := "vhost-user-fs-pci"
+		if board == "s390x-usr" {
+			device = "vhost-user-fs-ccw"
+		}
+		args = append(args,
+			"-chardev", fmt.Sprintf("socket,id=vfs%d,path=%s", i, qemuEscape(options.virtiofsSockets[i])),
+			"-device", fmt.Sprintf("%s,chardev=vfs%d,tag=%s", device, i, tag))
+		sharedMem = true
+	}
+	return args, sharedMem, nil
+}
+
+// MountSharedDirs mounts the shared directories which have a GuestPath
+// in a booted machine.
+func MountSharedDirs(m Machine, shares []SharedDir) error {
+	for i, share := range shares {
+		if share.GuestPath == "" {
+			continue
+		}
+		fstype, opts := "9p", "trans=virtio,version=9p2000.L"
+		if share.VirtioFS {
+			fstype, opts = "virtiofs", "defaults"
+		}
+		if share.ReadOnly {
+			opts += ",ro"
+		}
+		cmd := fmt.Sprintf("sudo mkdir -p %q && sudo mount -t %s -o %s %s %q",
+			share.GuestPath, fstype, opts, sharedDirTag(i), share.GuestPath)
+		if out, stderr, err := m.SSH(cmd); err != nil {
+			return fmt.Errorf("mounting %s: %s: %v: %s", share.GuestPath, out, err, stderr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"testing"
+)
+
+func TestParseSharedDir(t *testing.T) {
+	for _, tt := range []struct {
+		spec  string
+		share SharedDir
+		ok    bool
+	}{
+		{"/src:/mnt", SharedDir{HostPath: "/src", GuestPath: "/mnt"}, true},
+		{"/src:/mnt:ro", SharedDir{HostPath: "/src", GuestPath: "/mnt", ReadOnly: true}, true},
+		{"/src:/mnt:virtiofs", SharedDir{HostPath: "/src", GuestPath: "/mnt", VirtioFS: true}, true},
+		{"/src:/mnt:virtiofs:ro", SharedDir{}, false},
+		{"/src", SharedDir{}, false},
+		{":/mnt", SharedDir{}, false},
+		{"/src:/mnt:rw", SharedDir{}, false},
+	} {
+		share, err := ParseSharedDir(tt.spec)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: unexpected error %v", tt.spec, err)
+			continue
+		}
+		if share != tt.share {
+			t.Errorf("%q: got %+v, expected %+v", tt.spec, share, tt.share)
+		}
+	}
+}
+
+func TestQEMUShareArgs(t *testing.T) {
+	options := MachineOptions{
+		SharedDirs: []SharedDir{
+			{HostPath: "/src,1", ReadOnly: true},
+			{HostPath: "/build", VirtioFS: true},
+		},
+	}
+	if _, _, err := qemuShareArgs("amd64-usr", options); err == nil {
+		t.Fatal("virtio-fs share without virtiofsd accepted")
+	}
+
+	SetupVirtiofsd(&options, "id")
+	args, sharedMem, err := qemuShareArgs("amd64-usr", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sharedMem {
+		t.Error("virtio-fs share doesn't share memory")
+	}
+	expected := []string{
+		"-fsdev", "local,id=share0,security_model=none,path=/src,,1,readonly",
+		"-device", "virtio-9p-pci,fsdev=share0,mount_tag=kola0",
+		"-chardev", "socket,id=vfs1,path=" + options.virtiofsSockets[1],
+		"-device", "vhost-user-fs-pci,chardev=vfs1,tag=kola1",
+	}
+	if len(args) != len(expected) {
+		t.Fatalf("got %q, expected %q", args, expected)
+	}
+	for i := range args {
+		if args[i] != expected[i] {
+			t.Errorf("argument %d: got %q, expected %q", i, args[i], expected[i])
+		}
+	}
+
+	options.SharedDirs[1].ReadOnly = true
+	if _, _, err := qemuShareArgs("amd64-usr", options); err == nil {
+		t.Error("read-only virtio-fs share accepted")
+	}
+}
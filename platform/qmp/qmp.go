@@ -0,0 +1,403 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qmp is a client for the QEMU Machine Protocol, QEMU's JSON
+// control interface.
+package qmp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+var plog = capnslog.NewPackageLogger("github.com/coreos/mantle", "platform/qmp")
+
+const (
+	// greetingTimeout bounds how long QEMU takes to start the monitor.
+	greetingTimeout = 30 * time.Second
+	// maxEvents bounds the number of unclaimed events kept.
+	maxEvents = 1000
+)
+
+// ErrClosed is returned for commands issued after the connection closed.
+var ErrClosed = errors.New("QMP connection closed")
+
+// Event is an asynchronous notification from QEMU, such as SHUTDOWN or
+// DEVICE_DELETED.
+type Event struct {
+	Event     string                 `json:"event"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp struct {
+		Seconds      int64 `json:"seconds"`
+		Microseconds int64 `json:"microseconds"`
+	} `json:"timestamp"`
+}
+
+// Error is an error reply to a command.
+type Error struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Class, e.Desc)
+}
+
+type message struct {
+	Event
+	Return json.RawMessage `json:"return,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// Client is a connection to a QEMU monitor.  It is safe for concurrent
+// use; commands are issued one at a time.
+type Client struct {
+	conn net.Conn
+
+	cmdLock   sync.Mutex
+	responses chan message
+
+	eventLock   sync.Mutex
+	events      []Event
+	eventNotify chan struct{}
+
+	done chan struct{}
+}
+
+// NewSocketPair returns a connected pair of sockets.  qemu should be
+// passed to QEMU with the arguments from Args, and client to Connect.
+func NewSocketPair() (qemu, client *os.File, err error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating QMP socket: %v", err)
+	}
+	return os.NewFile(uintptr(fds[0]), "qmp-qemu"), os.NewFile(uintptr(fds[1]), "qmp-client"), nil
+}
+
+// Args returns the QEMU arguments for a monitor on the socket passed to
+// QEMU as file descriptor fd.
+func Args(fd int) []string {
+	return []string{
+		"-chardev", fmt.Sprintf("socket,id=qmp,fd=%d", fd),
+		"-mon", "chardev=qmp,mode=control",
+	}
+}
+
+// Connect negotiates a QMP session on f, which is closed when the
+// client is.
+func Connect(f *os.File) (*Client, error) {
+	conn, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn)
+}
+
+// NewClient negotiates a QMP session on conn.
+func NewClient(conn net.Conn) (*Client, error) {
+	c := &Client{
+		conn:        conn,
+		responses:   make(chan message),
+		eventNotify: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	decoder := json.NewDecoder(conn)
+	conn.SetReadDeadline(time.Now().Add(greetingTimeout))
+	var greeting struct {
+		QMP *json.RawMessage `json:"QMP"`
+	}
+	if err := decoder.Decode(&greeting); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading QMP greeting: %v", err)
+	}
+	if greeting.QMP == nil {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected QMP greeting")
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	go c.read(decoder)
+
+	if _, err := c.Execute("qmp_capabilities", nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("negotiating QMP capabilities: %v", err)
+	}
+	return c, nil
+}
+
+func (c *Client) read(decoder *json.Decoder) {
+	defer close(c.done)
+	for {
+		var msg message
+		if err := decoder.Decode(&msg); err != nil {
+			plog.Debugf("QMP connection closed: %v", err)
+			return
+		}
+		if msg.Event.Event != "" {
+			plog.Debugf("QMP event %s %v", msg.Event.Event, msg.Event.Data)
+			c.eventLock.Lock()
+			c.events = append(c.events, msg.Event)
+			if len(c.events) > maxEvents {
+				c.events = c.events[len(c.events)-maxEvents:]
+			}
+			close(c.eventNotify)
+			c.eventNotify = make(chan struct{})
+			c.eventLock.Unlock()
+			continue
+		}
+		select {
+		case c.responses <- msg:
+		case <-time.After(time.Minute):
+			plog.Warningf("Dropping unexpected QMP reply")
+		}
+	}
+}
+
+// Execute runs a command and returns its result.  args may be nil.
+func (c *Client) Execute(command string, args interface{}) (json.RawMessage, error) {
+	c.cmdLock.Lock()
+	defer c.cmdLock.Unlock()
+
+	req := map[string]interface{}{"execute": command}
+	if args != nil {
+		req["arguments"] = args
+	}
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	plog.Debugf("QMP command %s", buf)
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("sending %s: %v", command, err)
+	}
+
+	select {
+	case msg := <-c.responses:
+		if msg.Error != nil {
+			return nil, fmt.Errorf("%s: %v", command, msg.Error)
+		}
+		return msg.Return, nil
+	case <-c.done:
+		return nil, ErrClosed
+	}
+}
+
+// WaitEvent waits for an event with the given name and returns it.
+// Events are queued as they arrive, so an event which arrived before
+// the call is returned immediately.  Each event is returned once.
+func (c *Client) WaitEvent(name string, timeout time.Duration) (Event, error) {
+	return c.waitEvent(func(e Event) bool { return e.Event == name }, name, timeout)
+}
+
+func (c *Client) waitEvent(match func(Event) bool, desc string, timeout time.Duration) (Event, error) {
+	deadline := time.After(timeout)
+	for {
+		c.eventLock.Lock()
+		for i, e := range c.events {
+			if match(e) {
+				c.events = append(c.events[:i], c.events[i+1:]...)
+				c.eventLock.Unlock()
+				return e, nil
+			}
+		}
+		notify := c.eventNotify
+		c.eventLock.Unlock()
+
+		select {
+		case <-notify:
+		case <-c.done:
+			return Event{}, fmt.Errorf("waiting for %s: %v", desc, ErrClosed)
+		case <-deadline:
+			return Event{}, fmt.Errorf("timed out waiting for %s", desc)
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Pause stops the guest CPUs.
+func (c *Client) Pause() error {
+	_, err := c.Execute("stop", nil)
+	return err
+}
+
+// Resume restarts the guest CPUs.
+func (c *Client) Resume() error {
+	_, err := c.Execute("cont", nil)
+	return err
+}
+
+// PowerButton presses the ACPI power button, asking the guest to shut
+// down cleanly.
+func (c *Client) PowerButton() error {
+	_, err := c.Execute("system_powerdown", nil)
+	return err
+}
+
+// HardReset resets the machine without involving the guest.
+func (c *Client) HardReset() error {
+	_, err := c.Execute("system_reset", nil)
+	return err
+}
+
+// humanCommand runs a monitor command which has no QMP equivalent.
+// Such commands report failure only as output text.
+func (c *Client) humanCommand(line string) error {
+	ret, err := c.Execute("human-monitor-command", map[string]string{
+		"command-line": line,
+	})
+	if err != nil {
+		return err
+	}
+	var out string
+	if err := json.Unmarshal(ret, &out); err != nil {
+		return err
+	}
+	if out = strings.TrimSpace(out); out != "" {
+		return fmt.Errorf("%s: %s", line, out)
+	}
+	return nil
+}
+
+// SaveSnapshot saves the machine state, including RAM and disks, as a
+// named internal snapshot.
+func (c *Client) SaveSnapshot(name string) error {
+	return c.humanCommand("savevm " + name)
+}
+
+// LoadSnapshot restores a snapshot created by SaveSnapshot.
+func (c *Client) LoadSnapshot(name string) error {
+	return c.humanCommand("loadvm " + name)
+}
+
+// AddDisk hot-plugs the qcow2 image at path as device id, using the
+// given device driver, such as virtio-blk-pci.  The disk's serial
+// number is also id.
+func (c *Client) AddDisk(id, path, driver string) error {
+	_, err := c.Execute("blockdev-add", map[string]interface{}{
+		"driver":    "qcow2",
+		"node-name": id,
+		"file": map[string]string{
+			"driver":   "file",
+			"filename": path,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.Execute("device_add", map[string]string{
+		"driver": driver,
+		"id":     id,
+		"drive":  id,
+		"serial": id,
+	})
+	if err != nil {
+		c.Execute("blockdev-del", map[string]string{"node-name": id})
+		return err
+	}
+	return nil
+}
+
+// RemoveDisk unplugs a disk added by AddDisk.  The guest must
+// acknowledge the removal.
+func (c *Client) RemoveDisk(id string, timeout time.Duration) error {
+	if err := c.removeDevice(id, timeout); err != nil {
+		return err
+	}
+	_, err := c.Execute("blockdev-del", map[string]string{"node-name": id})
+	return err
+}
+
+// SurpriseRemoveDisk detaches the drive named id from its device
+// without notifying the guest, so that further I/O to it fails.
+func (c *Client) SurpriseRemoveDisk(id string) error {
+	return c.humanCommand("drive_del " + id)
+}
+
+// CommitDisk writes the changes to the drive named id into its backing
+// file.
+func (c *Client) CommitDisk(id string) error {
+	return c.humanCommand("commit " + id)
+}
+
+// ThrottleDisk limits the total bandwidth in bytes and operations per
+// second of the disk device id.  Zero is unlimited.
+func (c *Client) ThrottleDisk(id string, bps, iops int64) error {
+	_, err := c.Execute("block_set_io_throttle", map[string]interface{}{
+		"id":      id,
+		"bps":     bps,
+		"bps_rd":  0,
+		"bps_wr":  0,
+		"iops":    iops,
+		"iops_rd": 0,
+		"iops_wr": 0,
+	})
+	return err
+}
+
+// AddNIC hot-plugs a NIC with user-mode networking as device id, using
+// the given device driver, such as virtio-net-pci.
+func (c *Client) AddNIC(id, driver string) error {
+	_, err := c.Execute("netdev_add", map[string]string{
+		"type": "user",
+		"id":   id,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.Execute("device_add", map[string]string{
+		"driver": driver,
+		"id":     id,
+		"netdev": id,
+	})
+	if err != nil {
+		c.Execute("netdev_del", map[string]string{"id": id})
+		return err
+	}
+	return nil
+}
+
+// RemoveNIC unplugs a NIC added by AddNIC.  The guest must acknowledge
+// the removal.
+func (c *Client) RemoveNIC(id string, timeout time.Duration) error {
+	if err := c.removeDevice(id, timeout); err != nil {
+		return err
+	}
+	_, err := c.Execute("netdev_del", map[string]string{"id": id})
+	return err
+}
+
+func (c *Client) removeDevice(id string, timeout time.Duration) error {
+	if _, err := c.Execute("device_del", map[string]string{"id": id}); err != nil {
+		return err
+	}
+	_, err := c.waitEvent(func(e Event) bool {
+		return e.Event == "DEVICE_DELETED" && e.Data["device"] == id
+	}, "deletion of "+id, timeout)
+	return err
+}
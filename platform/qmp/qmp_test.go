@@ -0,0 +1,158 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qmp
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeMonitor answers commands with handler, which returns the reply
+// and any events to send before it.
+func fakeMonitor(t *testing.T, handler func(cmd string, args map[string]interface{}) (reply string, events []string)) *Client {
+	server, conn := net.Pipe()
+	go func() {
+		defer server.Close()
+		server.Write([]byte(`{"QMP": {"version": {}, "capabilities": []}}` + "\n"))
+		decoder := json.NewDecoder(server)
+		for {
+			var req struct {
+				Execute   string                 `json:"execute"`
+				Arguments map[string]interface{} `json:"arguments"`
+			}
+			if err := decoder.Decode(&req); err != nil {
+				return
+			}
+			reply, events := `{"return": {}}`, []string(nil)
+			if req.Execute != "qmp_capabilities" {
+				reply, events = handler(req.Execute, req.Arguments)
+			}
+			for _, e := range events {
+				server.Write([]byte(e + "\n"))
+			}
+			server.Write([]byte(reply + "\n"))
+		}
+	}()
+
+	client, err := NewClient(conn)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	return client
+}
+
+func TestExecute(t *testing.T) {
+	client := fakeMonitor(t, func(cmd string, args map[string]interface{}) (string, []string) {
+		switch cmd {
+		case "query-status":
+			return `{"return": {"status": "running", "running": true}}`, nil
+		default:
+			return `{"error": {"class": "CommandNotFound", "desc": "The command ` + cmd + ` has not been found"}}`, nil
+		}
+	})
+	defer client.Close()
+
+	ret, err := client.Execute("query-status", nil)
+	if err != nil {
+		t.Fatalf("query-status: %v", err)
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(ret, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "running" {
+		t.Errorf("got status %q, expected running", status.Status)
+	}
+
+	if _, err := client.Execute("bogus", nil); err == nil {
+		t.Errorf("bogus command succeeded")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	client := fakeMonitor(t, func(cmd string, args map[string]interface{}) (string, []string) {
+		switch cmd {
+		case "system_powerdown":
+			return `{"return": {}}`, []string{
+				`{"event": "POWERDOWN", "timestamp": {"seconds": 1, "microseconds": 0}}`,
+			}
+		case "device_del":
+			return `{"return": {}}`, []string{
+				`{"event": "DEVICE_DELETED", "data": {"device": "other"}}`,
+				`{"event": "DEVICE_DELETED", "data": {"device": "` + args["id"].(string) + `"}}`,
+			}
+		default:
+			return `{"return": {}}`, nil
+		}
+	})
+	defer client.Close()
+
+	if err := client.PowerButton(); err != nil {
+		t.Fatalf("power button: %v", err)
+	}
+	// the event arrived before the reply, so it must be queued
+	e, err := client.WaitEvent("POWERDOWN", time.Second)
+	if err != nil {
+		t.Fatalf("waiting for POWERDOWN: %v", err)
+	}
+	if e.Timestamp.Seconds != 1 {
+		t.Errorf("got timestamp %v, expected 1", e.Timestamp.Seconds)
+	}
+	// each event is returned once
+	if _, err := client.WaitEvent("POWERDOWN", 10*time.Millisecond); err == nil {
+		t.Errorf("POWERDOWN event returned twice")
+	}
+
+	if err := client.RemoveNIC("nic0", time.Second); err != nil {
+		t.Fatalf("removing NIC: %v", err)
+	}
+	// the unrelated deletion is still queued
+	e, err = client.WaitEvent("DEVICE_DELETED", time.Second)
+	if err != nil {
+		t.Fatalf("waiting for DEVICE_DELETED: %v", err)
+	}
+	if e.Data["device"] != "other" {
+		t.Errorf("got deletion of %v, expected other", e.Data["device"])
+	}
+}
+
+func TestHumanCommandError(t *testing.T) {
+	client := fakeMonitor(t, func(cmd string, args map[string]interface{}) (string, []string) {
+		return `{"return": "Error: Device 'd3' is writable but does not support snapshots\r\n"}`, nil
+	})
+	defer client.Close()
+
+	if err := client.SaveSnapshot("snap"); err == nil {
+		t.Errorf("savevm failure not reported")
+	}
+}
+
+func TestClosed(t *testing.T) {
+	client := fakeMonitor(t, func(cmd string, args map[string]interface{}) (string, []string) {
+		return `{"return": {}}`, nil
+	})
+	client.Close()
+
+	if _, err := client.WaitEvent("SHUTDOWN", time.Minute); err == nil {
+		t.Errorf("waiting on closed connection succeeded")
+	}
+	if err := client.Pause(); err == nil {
+		t.Errorf("command on closed connection succeeded")
+	}
+}
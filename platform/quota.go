@@ -0,0 +1,43 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+)
+
+// InstanceQuota is the number of instances an account may run, as
+// reported by the provider.
+type InstanceQuota struct {
+	// Limit is the maximum number of instances.
+	Limit int
+	// Used is the number of instances already running.
+	Used int
+	// Description explains where the limit comes from, e.g. the name
+	// of the provider's quota metric.
+	Description string
+}
+
+// Available returns the number of instances which can still be created.
+func (q InstanceQuota) Available() int {
+	if q.Used >= q.Limit {
+		return 0
+	}
+	return q.Limit - q.Used
+}
+
+func (q InstanceQuota) String() string {
+	return fmt.Sprintf("%d of %d %s in use", q.Used, q.Limit, q.Description)
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ResourceJournalName is the name of the resource journal within a kola
+// output directory.
+const ResourceJournalName = "resources.json"
+
+// Resource is a platform resource created by a Flight which must be
+// deleted when the Flight is destroyed.
+type Resource struct {
+	Platform Name   `json:"platform"`
+	Type     string `json:"type"` // one of the GC resource types
+	ID       string `json:"id"`
+	// Context holds platform-specific details needed to delete the
+	// resource, such as the region or resource group.
+	Context map[string]string `json:"context,omitempty"`
+	Created time.Time         `json:"created"`
+}
+
+// ResourceJournal records the resources a Flight has created but not yet
+// deleted.  The journal file is rewritten after every change, so if
+// the process dies it lists exactly the resources that leaked.  Several
+// journals, in this process or others, may share a file: each change is
+// made under a lock on the file and merged with what the others wrote.
+// If the file is deleted, for example when the output directory is
+// emptied, the next change writes back the resources this journal added.
+// A nil *ResourceJournal discards everything.
+type ResourceJournal struct {
+	lock  sync.Mutex
+	path  string
+	added []Resource // added by this journal and not yet removed
+}
+
+// OpenResourceJournal opens the journal at path, creating it if it
+// doesn't exist.
+func OpenResourceJournal(path string) (*ResourceJournal, error) {
+	j := &ResourceJournal{path: path}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j, j.update(nil, nil)
+}
+
+// ReadResourceJournal returns the resources recorded in the journal at
+// path.
+func ReadResourceJournal(path string) ([]Resource, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var resources []Resource
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return nil, fmt.Errorf("parsing resource journal %s: %v", path, err)
+	}
+	return resources, nil
+}
+
+// Add records a newly created resource.
+func (j *ResourceJournal) Add(r Resource) error {
+	if j == nil {
+		return nil
+	}
+	if r.Created.IsZero() {
+		r.Created = time.Now().UTC()
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.update(&r, nil)
+}
+
+// Remove marks a resource as cleaned up, whichever journal added it.
+func (j *ResourceJournal) Remove(platform Name, typ, id string) error {
+	if j == nil {
+		return nil
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.update(nil, func(r Resource) bool {
+		return r.Platform == platform && r.Type == typ && r.ID == id
+	})
+}
+
+// Resources returns the resources which have not been cleaned up.
+func (j *ResourceJournal) Resources() ([]Resource, error) {
+	if j == nil {
+		return nil, nil
+	}
+	resources, err := ReadResourceJournal(j.path)
+	if os.IsNotExist(err) {
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		return append([]Resource{}, j.added...), nil
+	}
+	return resources, err
+}
+
+// update rereads the journal file under the file lock, applies add and
+// remove, and atomically replaces the file.  If the file is missing, it
+// starts from the resources this journal added; otherwise the file is
+// authoritative, and any of them that another journal removed are
+// forgotten.  The caller must hold j.lock.
+func (j *ResourceJournal) update(add *Resource, remove func(Resource) bool) error {
+	lock, err := lockFile(j.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("locking resource journal: %v", err)
+	}
+	defer lock.Close()
+
+	resources, err := ReadResourceJournal(j.path)
+	if os.IsNotExist(err) {
+		resources = append([]Resource{}, j.added...)
+	} else if err != nil {
+		return err
+	} else {
+		j.added = filterResources(j.added, func(r Resource) bool {
+			return !containsResource(resources, r)
+		})
+	}
+	if add != nil {
+		resources = append(resources, *add)
+		j.added = append(j.added, *add)
+	}
+	if remove != nil {
+		resources = filterResources(resources, remove)
+		j.added = filterResources(j.added, remove)
+	}
+	if resources == nil {
+		resources = []Resource{}
+	}
+	return writeResourceJournal(j.path, resources)
+}
+
+// filterResources returns the resources for which remove is false.
+func filterResources(resources []Resource, remove func(Resource) bool) []Resource {
+	var kept []Resource
+	for _, r := range resources {
+		if !remove(r) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// containsResource reports whether resources includes r.
+func containsResource(resources []Resource, r Resource) bool {
+	for _, res := range resources {
+		if res.Platform == r.Platform && res.Type == r.Type && res.ID == r.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResourceJournal atomically replaces the journal file at path.
+func writeResourceJournal(path string, resources []Resource) error {
+	data, err := json.MarshalIndent(resources, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".resources-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
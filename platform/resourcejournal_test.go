@@ -0,0 +1,158 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestResourceJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resourcejournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ResourceJournalName)
+
+	j, err := OpenResourceJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Add(Resource{Platform: "aws", Type: GCKey, ID: "kola-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Add(Resource{Platform: "aws", Type: GCInstance, ID: "i-1", Context: map[string]string{"region": "us-west-2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crashed process leaves both entries behind
+	resources, err := ReadResourceJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 || resources[1].Context["region"] != "us-west-2" {
+		t.Fatalf("unexpected resources: %+v", resources)
+	}
+
+	// reopening keeps existing entries
+	j, err = OpenResourceJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Remove("aws", GCInstance, "i-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Remove("aws", GCKey, "kola-1"); err != nil {
+		t.Fatal(err)
+	}
+	resources, err = ReadResourceJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 0 {
+		t.Fatalf("journal not empty: %+v", resources)
+	}
+
+	// a nil journal discards everything
+	var nj *ResourceJournal
+	if err := nj.Add(Resource{ID: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if resources, err := nj.Resources(); err != nil || len(resources) != 0 {
+		t.Fatalf("nil journal returned %v, %v", resources, err)
+	}
+}
+
+func TestResourceJournalShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resourcejournal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ResourceJournalName)
+
+	// two flights writing to the same journal keep each other's entries
+	journals := make([]*ResourceJournal, 2)
+	for i := range journals {
+		if journals[i], err = OpenResourceJournal(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*10)
+	for i, j := range journals {
+		wg.Add(1)
+		go func(i int, j *ResourceJournal) {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				errs <- j.Add(Resource{Platform: "aws", Type: GCInstance, ID: fmt.Sprintf("i-%d-%d", i, n)})
+			}
+		}(i, j)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	resources, err := ReadResourceJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 20 {
+		t.Fatalf("expected 20 resources, got %d: %+v", len(resources), resources)
+	}
+
+	// one journal can remove another's entries
+	if err := journals[0].Remove("aws", GCInstance, "i-1-0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := journals[1].Remove("aws", GCInstance, "i-1-1"); err != nil {
+		t.Fatal(err)
+	}
+	resources, err = journals[0].Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 18 {
+		t.Fatalf("expected 18 resources, got %d: %+v", len(resources), resources)
+	}
+
+	// a journal writes back its own entries if the file is deleted
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := journals[1].Add(Resource{Platform: "aws", Type: GCKey, ID: "kola-key"}); err != nil {
+		t.Fatal(err)
+	}
+	resources, err = ReadResourceJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 9 {
+		t.Fatalf("expected journal 1's 9 resources, got %d: %+v", len(resources), resources)
+	}
+	for _, r := range resources {
+		if r.ID == "i-1-0" || r.ID == "i-1-1" {
+			t.Errorf("removed resource %s came back", r.ID)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coreos/mantle/system/exec"
+	"github.com/coreos/mantle/util"
+)
+
+// warmBootTimeout bounds how long a template machine takes to power off.
+const warmBootTimeout = 2 * time.Minute
+
+// warmBootScripts make a booted machine's disk into a warm-boot image.
+// They remove the machine's identity and arm first boot, so that
+// machines booted from the image run Ignition with their own config,
+// and then power off.
+var warmBootScripts = map[string]string{
+	"cl": `set -e
+rm -f /etc/machine-id /etc/ssh/ssh_host_*
+rm -rf /home/core/.ssh /var/log/journal/*
+touch /boot/coreos/first_boot
+systemctl poweroff --no-block`,
+	"fcos": `set -e
+rm -f /etc/machine-id /etc/ssh/ssh_host_*
+rm -rf /home/core/.ssh /var/log/journal/*
+mount -o remount,rw /boot
+touch /boot/ignition.firstboot
+systemctl poweroff --no-block`,
+}
+
+func init() {
+	warmBootScripts["rhcos"] = warmBootScripts["fcos"]
+}
+
+// WarmBootCache keeps disk images of machines which have completed
+// their first boot, so that machines booted from qcow2 overlays of them
+// skip the work of a first boot other than running Ignition.  Only the
+// newest image of each source image is kept.
+type WarmBootCache struct {
+	// Dir holds the images, which are reused across flights.  Processes
+	// sharing it take turns creating and pruning images.
+	Dir string
+
+	lock   sync.Mutex
+	images map[string]string
+}
+
+// Image returns a warm-boot image for the disk image base, creating it
+// if it isn't cached, or base itself if one can't be created.  base may
+// be a temporary specialization of the image at source, such as a CL
+// image with console logging enabled, described by specialization;
+// source and specialization identify the image across flights.
+// newMachine boots a template machine from an image, passing NoShutdown
+// in its options; the machine must accept SSH from the flight's keys.
+func (w *WarmBootCache) Image(source, specialization, base, distribution string, newMachine func(image string) (QEMUMachine, error)) string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	id, version, err := warmBootKey(source, specialization, distribution)
+	if err != nil {
+		plog.Warningf("Booting machines without a warm-boot image: %v", err)
+		return base
+	}
+	key := id + "-" + version
+	if image, ok := w.images[key]; ok {
+		// another process pruned it after the source image changed
+		if image != base {
+			if _, err := os.Stat(image); err != nil {
+				plog.Warningf("Booting machines without a warm-boot image: %v", err)
+				image = base
+				w.images[key] = image
+			}
+		}
+		return image
+	}
+	if w.images == nil {
+		w.images = make(map[string]string)
+	}
+
+	image, err := w.image(id, version, base, distribution, newMachine)
+	if err != nil {
+		plog.Warningf("Booting machines without a warm-boot image: %v", err)
+		image = base
+	}
+	w.images[key] = image
+	return image
+}
+
+// warmBootKey identifies the warm-boot images of a source image, and
+// the version of the source image they were made from.
+func warmBootKey(source, specialization, distribution string) (id, version string, err error) {
+	source, err = filepath.Abs(source)
+	if err != nil {
+		return "", "", err
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", "", err
+	}
+	idSum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s", source, specialization, distribution)))
+	versionSum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%d", info.Size(), info.ModTime().UnixNano())))
+	return fmt.Sprintf("%x", idSum[:8]), fmt.Sprintf("%x", versionSum[:8]), nil
+}
+
+func (w *WarmBootCache) image(id, version, base, distribution string, newMachine func(image string) (QEMUMachine, error)) (string, error) {
+	script, ok := warmBootScripts[distribution]
+	if !ok {
+		return "", fmt.Errorf("warm boot isn't supported on %s", distribution)
+	}
+
+	if err := os.MkdirAll(w.Dir, 0777); err != nil {
+		return "", err
+	}
+	lock, err := lockFile(filepath.Join(w.Dir, ".lock"))
+	if err != nil {
+		return "", fmt.Errorf("locking %s: %v", w.Dir, err)
+	}
+	defer lock.Close()
+
+	// the image is an overlay of a copy of base, since base may not
+	// outlive the flight
+	baseCopy := filepath.Join(w.Dir, fmt.Sprintf("base-%s-%s.img", id, version))
+	image := filepath.Join(w.Dir, fmt.Sprintf("warm-%s-%s.qcow2", id, version))
+
+	if _, err := os.Stat(image); err == nil {
+		err := checkBackingChain(image)
+		if err == nil {
+			return image, nil
+		}
+		plog.Warningf("Recreating warm-boot image %s: %v", image, err)
+	}
+	pruneWarmBootImages(w.Dir, id)
+	plog.Infof("Creating warm-boot image %s", image)
+	if err := copyWarmBootBase(base, baseCopy); err != nil {
+		return "", fmt.Errorf("copying %s: %v", base, err)
+	}
+	if err := createWarmBootImage(baseCopy, image, script, newMachine); err != nil {
+		os.Remove(baseCopy)
+		return "", fmt.Errorf("creating %s: %v", image, err)
+	}
+	return image, nil
+}
+
+// pruneWarmBootImages removes every image in dir made from earlier
+// versions of the source image identified by id, and the temporary
+// files of creations which didn't finish.  The caller must hold the
+// lock on dir.  Running machines keep their open images; flights which
+// cached a removed image fall back to cold boots.
+func pruneWarmBootImages(dir, id string) {
+	var stale []string
+	for _, pattern := range []string{"base-" + id + "-*", "warm-" + id + "-*", "*.tmp"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			plog.Warningf("Pruning warm-boot images: %v", err)
+			return
+		}
+		stale = append(stale, matches...)
+	}
+	for _, path := range stale {
+		plog.Infof("Removing stale warm-boot image %s", path)
+		if err := os.Remove(path); err != nil {
+			plog.Warningf("Pruning warm-boot images: %v", err)
+		}
+	}
+}
+
+// checkBackingChain checks that every image in the backing chain of an
+// image can be opened.  It's a variable so tests can run without
+// qemu-img.
+var checkBackingChain = func(image string) error {
+	if out, err := exec.Command("qemu-img", "info", "--backing-chain", image).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// createOverlay creates a qcow2 image backed by base.  It's a variable
+// so tests can run without qemu-img.
+var createOverlay = func(base, overlay string) error {
+	info, err := util.GetImageInfo(base)
+	if err != nil {
+		return err
+	}
+	qemuImg := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", info.Format, "-b", base, overlay)
+	qemuImg.Stderr = os.Stderr
+	return qemuImg.Run()
+}
+
+// copyWarmBootBase copies base to dest, replacing any earlier copy only
+// once the new one is complete.
+func copyWarmBootBase(base, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0777); err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	// cp is used since it supports sparse and reflink.
+	cp := exec.Command("cp", "--force", "--sparse=always", "--reflink=auto", base, tmp)
+	cp.Stderr = os.Stderr
+	if err := cp.Run(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+func createWarmBootImage(base, image, script string, newMachine func(image string) (QEMUMachine, error)) error {
+	if err := os.MkdirAll(filepath.Dir(image), 0777); err != nil {
+		return err
+	}
+	// the template's changes are committed to a temporary overlay,
+	// which only replaces the image once it is complete
+	tmp := image + ".tmp"
+	if err := createOverlay(base, tmp); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	m, err := newMachine(tmp)
+	if err != nil {
+		return err
+	}
+	defer m.Destroy()
+
+	if out, stderr, err := m.SSH("sudo sh -c '" + script + "'"); err != nil {
+		return fmt.Errorf("preparing template machine: %s: %v: %s", out, err, stderr)
+	}
+	if _, err := m.WaitEvent("SHUTDOWN", warmBootTimeout); err != nil {
+		return fmt.Errorf("waiting for template machine to power off: %v", err)
+	}
+	if err := m.CommitDisk(PrimaryDiskID); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, image)
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Red Hat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/mantle/platform/qmp"
+)
+
+func TestWarmBootKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "warmboot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "image.bin")
+	if err := ioutil.WriteFile(source, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := func(specialization, distribution string) (string, string) {
+		id, version, err := warmBootKey(source, specialization, distribution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id, version
+	}
+	console, version := key("console", "cl")
+	if again, againVersion := key("console", "cl"); again != console || againVersion != version {
+		t.Errorf("key changed from %s-%s to %s-%s", console, version, again, againVersion)
+	}
+	if id, _ := key("", "cl"); id == console {
+		t.Error("specializations share an id")
+	}
+	if id, _ := key("console", "fcos"); id == console {
+		t.Error("distributions share an id")
+	}
+
+	if err := ioutil.WriteFile(source, []byte("new image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if id, newVersion := key("console", "cl"); id != console || newVersion == version {
+		t.Errorf("source image change gave %s-%s, expected %s with a version other than %s", id, newVersion, console, version)
+	}
+}
+
+// fakeTemplateMachine pretends to prepare a warm-boot image and power off.
+type fakeTemplateMachine struct {
+	QEMUMachine
+}
+
+func (m *fakeTemplateMachine) SSH(cmd string) ([]byte, []byte, error) {
+	return nil, nil, nil
+}
+
+func (m *fakeTemplateMachine) WaitEvent(name string, timeout time.Duration) (qmp.Event, error) {
+	return qmp.Event{Event: name}, nil
+}
+
+func (m *fakeTemplateMachine) CommitDisk(id string) error {
+	return nil
+}
+
+func (m *fakeTemplateMachine) Destroy() {}
+
+func TestWarmBootImage(t *testing.T) {
+	// overlays record the path of their backing file
+	defer func(create func(string, string) error, check func(string) error) {
+		createOverlay, checkBackingChain = create, check
+	}(createOverlay, checkBackingChain)
+	createOverlay = func(base, overlay string) error {
+		return ioutil.WriteFile(overlay, []byte(base), 0644)
+	}
+	checkBackingChain = func(image string) error {
+		base, err := ioutil.ReadFile(image)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stat(string(base))
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "warmboot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cacheDir := filepath.Join(dir, "cache")
+	source := filepath.Join(dir, "image.bin")
+	if err := ioutil.WriteFile(source, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var boots int
+	newMachine := func(image string) (QEMUMachine, error) {
+		boots++
+		return &fakeTemplateMachine{}, nil
+	}
+	failMachine := func(image string) (QEMUMachine, error) {
+		boots++
+		return nil, fmt.Errorf("no machine")
+	}
+	listCache := func() []string {
+		names, err := filepath.Glob(filepath.Join(cacheDir, "*-*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range names {
+			names[i] = filepath.Base(names[i])
+		}
+		return names
+	}
+
+	// a miss boots a template machine
+	w := &WarmBootCache{Dir: cacheDir}
+	image := w.Image(source, "", source, "cl", newMachine)
+	if image == source || boots != 1 {
+		t.Fatalf("miss returned %s after %d boots", image, boots)
+	}
+	if cached := listCache(); len(cached) != 2 {
+		t.Fatalf("expected a base copy and a warm image, found %v", cached)
+	}
+
+	// hits in the same flight and in later ones don't
+	if again := w.Image(source, "", source, "cl", newMachine); again != image || boots != 1 {
+		t.Errorf("hit returned %s after %d boots", again, boots)
+	}
+	if again := (&WarmBootCache{Dir: cacheDir}).Image(source, "", source, "cl", newMachine); again != image || boots != 1 {
+		t.Errorf("hit in a new cache returned %s after %d boots", again, boots)
+	}
+
+	// a broken image is recreated
+	if err := os.Remove(string(mustReadFile(t, image))); err != nil {
+		t.Fatal(err)
+	}
+	if again := (&WarmBootCache{Dir: cacheDir}).Image(source, "", source, "cl", newMachine); again != image || boots != 2 {
+		t.Errorf("broken image returned %s after %d boots", again, boots)
+	}
+
+	// a changed source replaces the old images
+	if err := ioutil.WriteFile(source, []byte("new image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w2 := &WarmBootCache{Dir: cacheDir}
+	newImage := w2.Image(source, "", source, "cl", newMachine)
+	if newImage == image || newImage == source || boots != 3 {
+		t.Fatalf("changed source returned %s after %d boots", newImage, boots)
+	}
+	if cached := listCache(); len(cached) != 2 {
+		t.Errorf("expected only the new images, found %v", cached)
+	}
+	if again := w.Image(source, "", source, "cl", newMachine); again != newImage || boots != 3 {
+		t.Errorf("first cache returned %s after %d boots", again, boots)
+	}
+
+	// a flight whose cached image was removed falls back to cold boots
+	if err := os.Remove(newImage); err != nil {
+		t.Fatal(err)
+	}
+	if again := w2.Image(source, "", source, "cl", newMachine); again != source || boots != 3 {
+		t.Errorf("removed image returned %s after %d boots", again, boots)
+	}
+
+	// failures fall back to the base image, once per flight
+	other := filepath.Join(dir, "other.bin")
+	if err := ioutil.WriteFile(other, []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := listCache()
+	w = &WarmBootCache{Dir: cacheDir}
+	for i := 0; i < 2; i++ {
+		if again := w.Image(other, "", other, "cl", failMachine); again != other || boots != 4 {
+			t.Errorf("failed creation returned %s after %d boots", again, boots)
+		}
+	}
+	if again := w.Image(other, "", other, "unknown", newMachine); again != other || boots != 4 {
+		t.Errorf("unsupported distribution returned %s after %d boots", again, boots)
+	}
+	if again := w.Image(filepath.Join(dir, "missing.bin"), "", other, "cl", newMachine); again != other || boots != 4 {
+		t.Errorf("missing source returned %s after %d boots", again, boots)
+	}
+	if cached := listCache(); len(cached) != len(before) {
+		t.Errorf("failed creations left files behind: %v", cached)
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}